@@ -1,89 +1,270 @@
 package appconfig
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
+	"strings"
+	"time"
 
-	"github.com/adrg/xdg"
-	"github.com/andreaswachs/lazyworkflows/meta"
-	"github.com/gookit/config/v2"
-	"github.com/gookit/config/v2/yamlv3"
+	"github.com/andreaswachs/lazyworkflows/checkout"
+	"github.com/gookit/config/v2/toml"
 	"gopkg.in/yaml.v3"
 )
 
+// ErrNoConfig is returned by Load when there is no config file yet
+var ErrNoConfig = errors.New("no config file found")
+
+// The kinds of response caches that can be configured
+const (
+	CacheMemory = "memory"
+	CacheDisk   = "disk"
+	CacheNone   = "none"
+)
+
 type Repo struct {
 	Token string
-	Repo  string
-	Owner string
+	// Read the token from a file or from what a command prints, e.g. pass show gh
+	TokenFile    string `yaml:"token_file"`
+	TokenCommand string `yaml:"token_command"`
+	// Where the token was resolved from, for diagnostics. It is never read from the config
+	TokenSource string `yaml:"-"`
+	Repo        string
+	Owner       string
+	// The API of a GitHub Enterprise Server instance, e.g. https://github.example.com/api/v3
+	ApiUrl string `yaml:"api_url"`
+	// A PEM bundle of CAs to trust, for instances with self-signed certificates
+	CaFile string `yaml:"ca_file"`
+	// When Repo is a glob like "*", the repos of the owner matching it are discovered.
+	// Discovered repos must also match one of the Include globs, none of the Exclude globs
+	// and have one of the Topics, for the filters that are given
+	Include []string
+	Exclude []string
+	Topics  []string
+	// The working tree and branch, when the tool was started in a git checkout of the repo.
+	// They are never read from the config
+	Checkout string `yaml:"-"`
+	Branch   string `yaml:"-"`
 }
 
 type AppConfig struct {
 	Repos []Repo
+	// The token of repos that don't give their own. Without any, $GITHUB_TOKEN or $GH_TOKEN is used
+	Token        string
+	TokenFile    string `yaml:"token_file"`
+	TokenCommand string `yaml:"token_command"`
+	// Defaults for repos that don't set their own API URL or CA bundle
+	ApiUrl string `yaml:"api_url"`
+	CaFile string `yaml:"ca_file"`
+	// The page size used when listing workflows and runs. Zero uses the API maximum
+	PerPage int `yaml:"per_page"`
+	// Where responses are cached for conditional requests: memory (default), disk or none
+	Cache string
+	// How long a single request to the API may take, e.g. 10s. Zero means no timeout
+	Timeout time.Duration
+	// How many repos are loaded at the same time. Zero uses a sensible default
+	Concurrency int
+	// How often repos given by a pattern are discovered again, e.g. 30m. Zero uses a sensible default
+	DiscoveryInterval time.Duration `yaml:"discovery_interval"`
+	// Named sets of repos with their own token and API, picked with --profile or in the UI
+	Profiles       map[string]Profile
+	DefaultProfile string `yaml:"default_profile"`
+	// The profile in use, empty when the repos at the top of the config are used. It is never read from the config
+	Profile string `yaml:"-"`
+	// The remote of the git checkout the tool is started in whose repo is shown as well, origin by default.
+	// none turns this off
+	GitRemote string `yaml:"git_remote"`
+
+	// Whether the config is read again while the UI runs, which has the terminal
+	reloaded bool
+	// The tokens token commands gave, by command, for when they fail to run again on a reload
+	commandTokens map[string]string
+	// The git checkout the tool was started in, nil when it wasn't. It is looked for once and kept on reloads
+	checkoutDetected bool
+	gitCheckout      *checkout.Checkout
 }
 
+// Load reads the config files like Read does, reporting what is wrong with them on stderr
 func (c *AppConfig) Load() error {
-	// Do some initial configuration to enable reading the config file
-	config.AddDriver(yamlv3.Driver)
+	err := c.Read()
+	if err != nil && !errors.Is(err, ErrNoConfig) {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+	return err
+}
 
-	// Ensures that the directory path before the config file exists
-	configPath := filepath.Join(xdg.DataHome, meta.AppName)
-	if err := ensureCreated(configPath); err != nil {
-		fmt.Fprintf(os.Stderr, "Could not create config dir: %v\n", err)
-		return err
+// Read reads the global config file, merges the one of the project over it, checks them, applies the
+// profile in use, adds the repo of the git checkout it runs in and resolves the tokens of the repos. Nothing is printed, such that it can be used while the UI is running
+func (c *AppConfig) Read() error {
+	return c.ReadProfile("")
+}
+
+// ReadProfile reads the config like Read, with the named profile rather than the one picked by
+// --profile, $LAZYWORKFLOWS_PROFILE or the config. An empty name picks the profile as Read does
+func (c *AppConfig) ReadProfile(profile string) error {
+	paths := Paths()
+	if len(paths) == 0 || (isExplicit() && !exists(Path())) {
+		// Not a failure, the caller offers to set up a config instead
+		return fmt.Errorf("%w at %s", ErrNoConfig, Path())
 	}
 
-	// Cread the config file or create it. If it didn't exist, it will be created
-	// and error out to encourage the user to fill it out
-	configFilePath := filepath.Join(configPath, meta.ConfigFileName)
-	contents, err := readConfig(configFilePath)
+	documents, err := readDocuments(paths)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Could not read config file: %v\n", err)
-		return err
+		return fmt.Errorf("could not read config file: %w", err)
+	}
+
+	// Every problem of every file is reported at once, rather than failing on the first request that runs into one
+	if invalid := checkDocuments(documents); len(invalid) > 0 {
+		return invalid
+	}
+
+	// Deserialize the merged config files, turning them into our app config struct
+	if err = merge(documents).Decode(c); err != nil {
+		return fmt.Errorf("while reading the config files %s, an error occurred: %w", strings.Join(paths, ", "), err)
 	}
 
-	// Unmarshal/deserialize the config file, turning it into our app config struct
-	if err = yaml.Unmarshal(contents, c); err != nil {
-		fmt.Fprintf(os.Stderr, "while reading the %s file, an error occurred: %v\n", configFilePath, err)
+	if profile == "" {
+		profile = c.pickProfile()
+	}
+	if err = c.useProfile(profile); err != nil {
 		return err
 	}
 
-	return nil
+	c.applyDefaults()
+	c.addCheckoutRepo()
+
+	return c.resolveTokens()
+}
+
+// Lets repos inherit the global settings they don't override
+func (c *AppConfig) applyDefaults() {
+	for i := range c.Repos {
+		if c.Repos[i].ApiUrl == "" {
+			c.Repos[i].ApiUrl = c.ApiUrl
+		}
+		if c.Repos[i].CaFile == "" {
+			c.Repos[i].CaFile = c.CaFile
+		}
+	}
+}
+
+// Reload reads the config again like ReadProfile, while the UI runs. Token commands run again without the
+// terminal, as a prompt would fight the UI for the screen. A command that fails then gives the token it gave
+// for this config
+func (c *AppConfig) Reload(profile string) (*AppConfig, error) {
+	reloaded := &AppConfig{
+		reloaded:         true,
+		commandTokens:    map[string]string{},
+		checkoutDetected: c.checkoutDetected,
+		gitCheckout:      c.gitCheckout,
+	}
+	for command, token := range c.commandTokens {
+		reloaded.commandTokens[command] = token
+	}
+
+	err := reloaded.ReadProfile(profile)
+	return reloaded, err
 }
 
 func New() *AppConfig {
 	return &AppConfig{}
 }
 
-// Reads the config file or creates it if it doesn't exist,
-// given the path to the config file
-func readConfig(configFilePath string) ([]byte, error) {
-	contents, err := os.ReadFile(configFilePath)
-	if err != nil {
-		file, err := os.Create(configFilePath)
+// A config file as read from disk, turned into YAML whatever format it is in
+type document struct {
+	path string
+	// The mapping of settings, nil for an empty file
+	root *yaml.Node
+	// Whether it is the config of the project being worked in, which may only give some settings
+	project bool
+}
+
+// Reads the config files at the paths, in their own formats
+func readDocuments(paths []string) ([]document, error) {
+	projectPath, hasProject := ProjectPath()
+
+	documents := []document{}
+	for _, configFilePath := range paths {
+		contents, err := os.ReadFile(configFilePath)
+		if err != nil {
+			return nil, err
+		}
+
+		root, err := parse(FormatOf(configFilePath), contents)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Could not create config file: %v\n", err)
-			return make([]byte, 0), err
+			return nil, fmt.Errorf("%s: %w", configFilePath, err)
 		}
+		project := hasProject && configFilePath == projectPath && configFilePath != Path()
+		documents = append(documents, document{path: configFilePath, root: root, project: project})
+	}
+	return documents, nil
+}
+
+// Parses the contents of a config file into a YAML node. JSON is a subset of YAML and keeps the positions
+// of its settings, TOML is decoded by its own driver and loses them
+func parse(format string, contents []byte) (*yaml.Node, error) {
+	if format == FormatToml {
+		settings := map[string]interface{}{}
+		if err := toml.Decoder(contents, &settings); err != nil {
+			return nil, err
+		}
+		root := yaml.Node{}
+		if err := root.Encode(settings); err != nil {
+			return nil, err
+		}
+		return &root, nil
+	}
 
-		file.Close()
-		fmt.Printf("Config file created at %s. Please fill it out and try again.\n", configFilePath)
-		os.Exit(0) // Exit this is not an error, but the program cannot continue
+	document := yaml.Node{}
+	if err := yaml.Unmarshal(contents, &document); err != nil {
+		return nil, err
 	}
+	// An empty file is an empty config
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+	return document.Content[0], nil
+}
 
-	return contents, nil
+// Merges the settings of config files, those of later files replacing the ones of earlier files.
+// Settings are replaced as a whole, such that the repos of a project replace the global ones
+func merge(documents []document) *yaml.Node {
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, doc := range documents {
+		if doc.root == nil {
+			continue
+		}
+		for i := 0; i+1 < len(doc.root.Content); i += 2 {
+			key, value := doc.root.Content[i], doc.root.Content[i+1]
+			if at := indexOfKey(merged, key.Value); at >= 0 {
+				merged.Content[at+1] = value
+			} else {
+				merged.Content = append(merged.Content, key, value)
+			}
+		}
+	}
+	return merged
+}
+
+// Returns where the key is in the contents of the mapping, or -1 when it isn't set
+func indexOfKey(mapping *yaml.Node, key string) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
 }
 
 // Ensures that the full path given is created or fails
 func ensureCreated(configPath string) error {
-	if _, err := os.Stat(configPath); err != nil {
-		if err = os.MkdirAll(configPath, os.ModePerm); err != nil {
-			fmt.Fprintf(os.Stderr, "Could not create config dir at location: %s. Full error: %v\n", configPath, err)
-			return err
-		}
-		fmt.Fprintf(os.Stderr, "could not query file system for existence of config dir: %s. Full error message: %v\n", configPath, err)
-		return err
+	if _, err := os.Stat(configPath); err == nil {
+		return nil
 	}
 
+	if err := os.MkdirAll(configPath, 0700); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not create config dir at location: %s. Full error: %v\n", configPath, err)
+		return err
+	}
 	return nil
 }
@@ -0,0 +1,127 @@
+package appconfig
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/andreaswachs/lazyworkflows/checkout"
+)
+
+// The remote whose repo is added when the tool is started in a git checkout and nothing else is configured
+const defaultGitRemote = "origin"
+
+// Turns detecting the repo of the git checkout off, when given as git_remote
+const gitRemoteNone = "none"
+
+// Where the repo of a git checkout is looked for. It is a global variable and thus able to get mocked by tests
+var findCheckout = checkout.Find
+
+// Adds the repo of the git checkout the tool was started in, if it is on a GitHub host, and notes the
+// branch checked out. A repo that is configured already keeps its place and settings, a repo that isn't
+// is shown first and borrows the token of a repo on the same host. Checkouts of repos on hosts that
+// nothing is configured for are left alone, as they are unlikely to be GitHub at all
+func (c *AppConfig) addCheckoutRepo() {
+	remoteName := c.GitRemote
+	if remoteName == "" {
+		remoteName = defaultGitRemote
+	}
+	if remoteName == gitRemoteNone {
+		return
+	}
+
+	found := c.detectCheckout()
+	if found == nil {
+		return
+	}
+	remote, err := checkout.ParseUrl(found.Remotes[remoteName])
+	if err != nil {
+		return
+	}
+	// An HTTPS remote may give the port of the server, which the API URL may give or leave out
+	host := withoutPort(remote.Host)
+
+	for i, repo := range c.Repos {
+		if strings.EqualFold(repo.Owner, remote.Owner) && strings.EqualFold(repo.Repo, remote.Repo) && apiHost(repo.ApiUrl) == host {
+			c.Repos[i].Checkout = found.Root
+			c.Repos[i].Branch = found.Branch
+			return
+		}
+	}
+
+	repo := Repo{
+		Owner:    remote.Owner,
+		Repo:     remote.Repo,
+		ApiUrl:   "https://" + remote.Host,
+		Checkout: found.Root,
+		Branch:   found.Branch,
+	}
+	if remote.Host == "github.com" {
+		repo.ApiUrl = "https://api.github.com"
+	}
+
+	if !c.borrowToken(&repo, host) {
+		// Without a repo on the same host, the defaults at the top of the config are used if they are for the host
+		if apiHost(c.ApiUrl) != host && host != "github.com" {
+			return
+		}
+		if c.ApiUrl != "" {
+			repo.ApiUrl = c.ApiUrl
+		}
+		repo.CaFile = c.CaFile
+	}
+
+	c.Repos = append([]Repo{repo}, c.Repos...)
+}
+
+// Looks for the git checkout the tool was started in, once. Reloads of the config keep what was found
+func (c *AppConfig) detectCheckout() *checkout.Checkout {
+	if c.checkoutDetected {
+		return c.gitCheckout
+	}
+	c.checkoutDetected = true
+
+	dir, err := workingDir()
+	if err != nil {
+		return nil
+	}
+	if found, err := findCheckout(dir); err == nil {
+		c.gitCheckout = &found
+	}
+	return c.gitCheckout
+}
+
+// Gives the repo the token settings and API of the first configured repo on the same host, if there is one
+func (c *AppConfig) borrowToken(repo *Repo, host string) bool {
+	for _, configured := range c.Repos {
+		if apiHost(configured.ApiUrl) == host {
+			repo.Token, repo.TokenFile, repo.TokenCommand = configured.Token, configured.TokenFile, configured.TokenCommand
+			repo.CaFile = configured.CaFile
+			if configured.ApiUrl != "" {
+				repo.ApiUrl = configured.ApiUrl
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// The host an API URL belongs to, without its port. The public API belongs to github.com
+func apiHost(apiUrl string) string {
+	if apiUrl == "" {
+		return "github.com"
+	}
+	parsed, err := url.Parse(apiUrl)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if host == "api.github.com" {
+		return "github.com"
+	}
+	return host
+}
+
+// The host of a remote without its port, if it gives one
+func withoutPort(host string) string {
+	return (&url.URL{Host: host}).Hostname()
+}
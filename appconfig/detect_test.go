@@ -0,0 +1,106 @@
+package appconfig
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andreaswachs/lazyworkflows/checkout"
+)
+
+func TestRepoOfTheCheckoutIsAdded(t *testing.T) {
+	dir := t.TempDir()
+	setupLocation(t, dir)
+
+	remotes := map[string]string{}
+	findCheckout = func(string) (checkout.Checkout, error) {
+		return checkout.Checkout{Root: "/src/app", Branch: "feature/login", Remotes: remotes}, nil
+	}
+	t.Cleanup(func() { findCheckout = checkout.Find })
+
+	path := filepath.Join(dir, "config.yml")
+	SetPath(path)
+	writeFile(t, path, `token: public-token
+repos:
+  - owner: octo-org
+    repo: octo-repo
+  - owner: corp
+    repo: service
+    token: ghes-token
+    api_url: https://github.example.com/api/v3
+`)
+
+	describe := func() string {
+		conf := New()
+		if err := conf.Read(); err != nil {
+			return err.Error()
+		}
+		described := []string{}
+		for _, repo := range conf.Repos {
+			described = append(described, fmt.Sprintf("%s/%s %s %s %s", repo.Owner, repo.Repo, repo.Token, repo.ApiUrl, repo.Branch))
+		}
+		return strings.Join(described, ", ")
+	}
+
+	remotes["origin"] = "git@github.example.com:corp/app.git"
+	expected := "corp/app ghes-token https://github.example.com/api/v3 feature/login, octo-org/octo-repo public-token  , corp/service ghes-token https://github.example.com/api/v3 "
+	if actual := describe(); actual != expected {
+		t.Errorf("Expected the repo of the checkout first, with the token of its host\n%v\nbut got\n%v", expected, actual)
+	}
+
+	remotes["origin"] = "https://github.com/Octo-Org/octo-repo.git"
+	expected = "octo-org/octo-repo public-token  feature/login, corp/service ghes-token https://github.example.com/api/v3 "
+	if actual := describe(); actual != expected {
+		t.Errorf("Expected the configured repo to get the branch\n%v\nbut got\n%v", expected, actual)
+	}
+
+	remotes["origin"] = "git@gitlab.com:octo-org/app.git"
+	expected = "octo-org/octo-repo public-token  , corp/service ghes-token https://github.example.com/api/v3 "
+	if actual := describe(); actual != expected {
+		t.Errorf("Expected a repo on an unknown host to be left out\n%v\nbut got\n%v", expected, actual)
+	}
+}
+
+func TestCheckoutOnAServerWithAPortIsMatchedAndFoundOnce(t *testing.T) {
+	dir := t.TempDir()
+	setupLocation(t, dir)
+
+	finds := 0
+	findCheckout = func(string) (checkout.Checkout, error) {
+		finds++
+		return checkout.Checkout{Root: "/src/app", Branch: "main", Remotes: map[string]string{"origin": "https://ghe.example.com:8443/corp/app.git"}}, nil
+	}
+	t.Cleanup(func() { findCheckout = checkout.Find })
+
+	path := filepath.Join(dir, "config.yml")
+	SetPath(path)
+	writeFile(t, path, `repos:
+  - owner: corp
+    repo: service
+    token: ghes-token
+    api_url: https://ghe.example.com:8443/api/v3
+`)
+
+	conf := New()
+	if err := conf.Read(); err != nil {
+		t.Fatalf("Expected the config to load, but got %v", err)
+	}
+	reloaded, err := conf.Reload("")
+	if err != nil {
+		t.Fatalf("Expected the config to reload, but got %v", err)
+	}
+
+	for _, read := range []*AppConfig{conf, reloaded} {
+		if len(read.Repos) != 2 {
+			t.Fatalf("Expected the repo of the checkout to be added, but got %+v", read.Repos)
+		}
+		repo := read.Repos[0]
+		if repo.Repo != "app" || repo.Token != "ghes-token" || repo.ApiUrl != "https://ghe.example.com:8443/api/v3" {
+			t.Errorf("Expected the repo of the checkout with the token and API of its host, but got %+v", read.Repos)
+		}
+	}
+	if finds != 1 {
+		t.Errorf("Expected the checkout to be looked for once, but it was looked for %v times", finds)
+	}
+}
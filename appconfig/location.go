@@ -0,0 +1,127 @@
+package appconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adrg/xdg"
+	"github.com/andreaswachs/lazyworkflows/meta"
+)
+
+// EnvConfig names the environment variable pointing at the config file, when --config isn't given
+const EnvConfig = "LAZYWORKFLOWS_CONFIG"
+
+// The name of the config file of a project, looked for in the working directory and its parents
+const projectConfigName = ".lazyworkflows"
+
+// The formats a config file can be written in, told apart by its extension
+const (
+	FormatYaml = "yaml"
+	FormatJson = "json"
+	FormatToml = "toml"
+)
+
+// The extensions that are looked for, in order, when the config file isn't named explicitly
+var extensions = []string{".yml", ".yaml", ".json", ".toml"}
+
+// The config file given by the --config flag, which wins over everything else
+var explicitPath string
+
+// Where the working directory is found. It is a global variable and thus able to get mocked by tests
+var workingDir = os.Getwd
+
+// SetPath makes the config be read from the path, as given by the --config flag. An empty path
+// goes back to looking for the config file
+func SetPath(configFilePath string) {
+	explicitPath = configFilePath
+}
+
+// Path returns where the global config file is read from: the --config flag, $LAZYWORKFLOWS_CONFIG
+// or the config file under $XDG_CONFIG_HOME. Configs created by earlier versions under $XDG_DATA_HOME
+// are still read from there. The path is returned even when there is no file yet
+func Path() string {
+	if explicitPath != "" {
+		return explicitPath
+	}
+	if fromEnv := os.Getenv(EnvConfig); fromEnv != "" {
+		return fromEnv
+	}
+
+	dir := filepath.Join(xdg.ConfigHome, meta.AppName)
+	base := strings.TrimSuffix(meta.ConfigFileName, filepath.Ext(meta.ConfigFileName))
+	for _, extension := range extensions {
+		if candidate := filepath.Join(dir, base+extension); exists(candidate) {
+			return candidate
+		}
+	}
+
+	if legacy := filepath.Join(xdg.DataHome, meta.AppName, meta.ConfigFileName); exists(legacy) {
+		return legacy
+	}
+
+	return filepath.Join(dir, meta.ConfigFileName)
+}
+
+// Whether the global config file was named by the --config flag or $LAZYWORKFLOWS_CONFIG,
+// rather than looked for
+func isExplicit() bool {
+	return explicitPath != "" || os.Getenv(EnvConfig) != ""
+}
+
+// ProjectPath returns the config file of the project being worked in, found by walking up from the
+// working directory. It reports whether there is one
+func ProjectPath() (string, bool) {
+	dir, err := workingDir()
+	if err != nil {
+		return "", false
+	}
+	return findProjectConfig(dir)
+}
+
+// Paths returns the config files that are read, in the order they are merged: the global one,
+// then the one of the project. Files that don't exist are left out
+func Paths() []string {
+	paths := []string{}
+	if global := Path(); exists(global) {
+		paths = append(paths, global)
+	}
+	if project, ok := ProjectPath(); ok {
+		paths = append(paths, project)
+	}
+	return paths
+}
+
+// FormatOf tells the format of a config file by its extension. Anything that isn't JSON or TOML is YAML
+func FormatOf(configFilePath string) string {
+	switch strings.ToLower(filepath.Ext(configFilePath)) {
+	case ".json":
+		return FormatJson
+	case ".toml":
+		return FormatToml
+	default:
+		return FormatYaml
+	}
+}
+
+func findProjectConfig(dir string) (string, bool) {
+	for {
+		for _, extension := range extensions {
+			if candidate := filepath.Join(dir, projectConfigName+extension); exists(candidate) {
+				return candidate, true
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// Whether there is a file at the path. Directories don't count
+func exists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,182 @@
+package appconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adrg/xdg"
+)
+
+func setupLocation(t *testing.T, dir string) {
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("XDG_DATA_HOME", filepath.Join(dir, "data"))
+	t.Setenv(EnvConfig, "")
+	xdg.Reload()
+
+	workingDir = func() (string, error) { return dir, nil }
+
+	t.Cleanup(func() {
+		SetPath("")
+		workingDir = os.Getwd
+		xdg.Reload()
+	})
+}
+
+func writeFile(t *testing.T, path string, contents string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPathPrefersTheFlagThenTheEnvironmentThenTheConfigHome(t *testing.T) {
+	dir := t.TempDir()
+	setupLocation(t, dir)
+
+	fresh := filepath.Join(dir, "config", "lazyworkflows", "config.yml")
+	if Path() != fresh {
+		t.Errorf("Expected a fresh config to go to %v, but got %v", fresh, Path())
+	}
+
+	legacy := filepath.Join(dir, "data", "lazyworkflows", "config.yml")
+	writeFile(t, legacy, "repos:\n")
+	if Path() != legacy {
+		t.Errorf("Expected the config of earlier versions to be read from %v, but got %v", legacy, Path())
+	}
+
+	toml := filepath.Join(dir, "config", "lazyworkflows", "config.toml")
+	writeFile(t, toml, "")
+	if Path() != toml {
+		t.Errorf("Expected the config in the config home to win, but got %v", Path())
+	}
+
+	t.Setenv(EnvConfig, "/from/env.json")
+	if Path() != "/from/env.json" {
+		t.Errorf("Expected $%s to win, but got %v", EnvConfig, Path())
+	}
+
+	SetPath("/from/flag.yml")
+	if Path() != "/from/flag.yml" {
+		t.Errorf("Expected --config to win, but got %v", Path())
+	}
+}
+
+func TestProjectConfigIsMergedOverTheGlobalOne(t *testing.T) {
+	dir := t.TempDir()
+	setupLocation(t, dir)
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GH_TOKEN", "")
+
+	global := filepath.Join(dir, "config", "lazyworkflows", "config.json")
+	writeFile(t, global, `{
+	"token": "global-token",
+	"cache": "none",
+	"repos": [{"owner": "octo", "repo": "global"}]
+}`)
+	project := filepath.Join(dir, "project", ".lazyworkflows.toml")
+	writeFile(t, project, "timeout = \"10s\"\n\n[[repos]]\nowner = \"octo\"\nrepo = \"project\"\n")
+
+	// Found from a directory deep inside the project
+	workingDir = func() (string, error) { return filepath.Join(dir, "project", "src", "cmd"), nil }
+
+	conf := New()
+	if err := conf.Load(); err != nil {
+		t.Fatalf("Expected the config to load, but got %v", err)
+	}
+
+	if len(conf.Repos) != 1 || conf.Repos[0].Repo != "project" {
+		t.Errorf("Expected the repos of the project to replace the global ones, but got %v", conf.Repos)
+	}
+	if conf.Repos[0].Token != "global-token" || conf.Cache != CacheNone || conf.Timeout.String() != "10s" {
+		t.Errorf("Expected the settings of both files, but got %+v", conf)
+	}
+}
+
+func TestTomlProblemsAreReportedWithoutPositions(t *testing.T) {
+	dir := t.TempDir()
+	setupLocation(t, dir)
+
+	path := filepath.Join(dir, "config.toml")
+	writeFile(t, path, "token = \"secret\"\ncache = \"redis\"\n")
+
+	invalid, err := ValidateFiles(path)
+	if err != nil || len(invalid) != 1 {
+		t.Fatalf("Expected a single invalid file, but got %v %v", invalid, err)
+	}
+
+	expected := path + `: cache must be one of memory, disk or none, got "redis"`
+	if invalid[0].Error() != expected {
+		t.Errorf("Expected %v but got %v", expected, invalid[0].Error())
+	}
+}
+
+func TestProblemsOfEveryFileAreReported(t *testing.T) {
+	dir := t.TempDir()
+	setupLocation(t, dir)
+
+	global := filepath.Join(dir, "config", "lazyworkflows", "config.yml")
+	writeFile(t, global, "token: ghp_secret\ncache: redis\n")
+	project := filepath.Join(dir, ".lazyworkflows.yml")
+	writeFile(t, project, "timeout: soon\n")
+
+	err := New().Read()
+	invalid, ok := err.(ValidationErrors)
+	if !ok || len(invalid) != 2 {
+		t.Fatalf("Expected the problems of both files, but got %v", err)
+	}
+
+	expected := global + `:2:8: cache must be one of memory, disk or none, got "redis"` + "\n" +
+		project + `:1:10: timeout must be a duration like 30s or 5m, got "soon"`
+	if err.Error() != expected {
+		t.Errorf("Expected %v but got %v", expected, err.Error())
+	}
+}
+
+func TestProjectConfigCantRunCommands(t *testing.T) {
+	dir := t.TempDir()
+	setupLocation(t, dir)
+
+	writeFile(t, filepath.Join(dir, "config", "lazyworkflows", "config.yml"), "token: ghp_secret\nrepos:\n  - owner: octo\n    repo: global\n")
+	marker := filepath.Join(dir, "marker")
+	writeFile(t, filepath.Join(dir, ".lazyworkflows.yml"), "repos:\n  - owner: octo\n    repo: project\n    token_command: touch "+marker+"\n")
+
+	err := New().Read()
+	if err == nil || !strings.Contains(err.Error(), "token_command can't be set in a project config") {
+		t.Errorf("Expected the token_command of the project to be rejected, but got %v", err)
+	}
+	if _, statErr := os.Stat(marker); statErr == nil {
+		t.Errorf("Expected the token_command of the project not to run")
+	}
+}
+
+func TestProjectConfigCantRedirectTheGlobalToken(t *testing.T) {
+	dir := t.TempDir()
+	setupLocation(t, dir)
+
+	writeFile(t, filepath.Join(dir, "config", "lazyworkflows", "config.yml"), "token: ghp_secret\nrepos:\n  - owner: octo\n    repo: global\n")
+
+	projects := []string{
+		"api_url: https://evil.example\n",
+		"repos:\n  - owner: octo\n    repo: project\n    api_url: https://evil.example\n",
+		"ca_file: /tmp/evil.pem\n",
+		"profiles:\n  evil:\n    api_url: https://evil.example\n    repos:\n      - owner: octo\n        repo: project\n",
+	}
+	for _, project := range projects {
+		writeFile(t, filepath.Join(dir, ".lazyworkflows.yml"), project)
+
+		conf := New()
+		err := conf.Read()
+		if err == nil || !strings.Contains(err.Error(), "can't be set in a project config") {
+			t.Errorf("Expected %q to be rejected, but got %v", project, err)
+		}
+		for _, repo := range conf.Repos {
+			if repo.ApiUrl != "" || repo.Token != "" {
+				t.Errorf("Expected the global token to stay with the global API, but got %+v", repo)
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package appconfig
+
+import (
+	"path"
+	"strings"
+)
+
+// IsPattern reports whether the repo stands for every repo of its owner matching it, rather than a single repo
+func (r Repo) IsPattern() bool {
+	return strings.ContainsAny(r.Repo, "*?[")
+}
+
+// Matches reports whether a repo of the owner, with the given topics, is picked by the pattern
+func (r Repo) Matches(name string, topics []string) bool {
+	if !r.MatchesName(name) {
+		return false
+	}
+	if len(r.Topics) == 0 {
+		return true
+	}
+
+	for _, wanted := range r.Topics {
+		for _, topic := range topics {
+			if strings.EqualFold(wanted, topic) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MatchesName reports whether the name of a repo is picked by the pattern, disregarding its topics
+func (r Repo) MatchesName(name string) bool {
+	if !matchesAny([]string{r.Repo}, name) {
+		return false
+	}
+	if len(r.Include) > 0 && !matchesAny(r.Include, name) {
+		return false
+	}
+	return !matchesAny(r.Exclude, name)
+}
+
+// ForName returns the repo with the given name, set up like the pattern it was found through
+func (r Repo) ForName(name string) Repo {
+	found := r
+	found.Repo = name
+	found.Include = nil
+	found.Exclude = nil
+	found.Topics = nil
+	return found
+}
+
+// Repo names are case insensitive on GitHub, and so are the globs matching them
+func matchesAny(globs []string, name string) bool {
+	for _, glob := range globs {
+		if matched, _ := path.Match(strings.ToLower(glob), strings.ToLower(name)); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package appconfig
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// EnvProfile names the environment variable picking the profile, when --profile isn't given
+const EnvProfile = "LAZYWORKFLOWS_PROFILE"
+
+// NoProfile picks the repos at the top of the config rather than any profile, even when the config has a default one
+const NoProfile = "-"
+
+// Profile is a named set of repos with their own token and API, e.g. to keep work and open source apart.
+// Its settings replace the ones at the top of the config while it is used
+type Profile struct {
+	Repos        []Repo
+	Token        string
+	TokenFile    string `yaml:"token_file"`
+	TokenCommand string `yaml:"token_command"`
+	ApiUrl       string `yaml:"api_url"`
+	CaFile       string `yaml:"ca_file"`
+}
+
+// The profile given by the --profile flag or picked in the UI, which wins over everything else
+var selectedProfile string
+
+// SetProfile makes the config be read with the named profile, as given by the --profile flag.
+// An empty name goes back to $LAZYWORKFLOWS_PROFILE and the default profile of the config
+func SetProfile(name string) {
+	selectedProfile = name
+}
+
+// ProfileNames returns the names of the profiles in the config, sorted
+func (c *AppConfig) ProfileNames() []string {
+	names := []string{}
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Picks the profile to use: the one set through SetProfile, then $LAZYWORKFLOWS_PROFILE, then the
+// default profile of the config. Without any, the repos at the top of the config are used, or the
+// first profile when there are none
+func (c *AppConfig) pickProfile() string {
+	for _, name := range []string{selectedProfile, os.Getenv(EnvProfile), c.DefaultProfile} {
+		if name != "" {
+			return name
+		}
+	}
+	if len(c.Repos) == 0 && len(c.Profiles) > 0 {
+		return c.ProfileNames()[0]
+	}
+	return ""
+}
+
+// Replaces the repos and defaults at the top of the config with those of the named profile
+func (c *AppConfig) useProfile(name string) error {
+	if name == "" || name == NoProfile {
+		return nil
+	}
+
+	profile, ok := c.Profiles[name]
+	if !ok {
+		if len(c.Profiles) == 0 {
+			return fmt.Errorf("unknown profile %q, the config has no profiles", name)
+		}
+		return fmt.Errorf("unknown profile %q, the config has %s", name, strings.Join(c.ProfileNames(), ", "))
+	}
+
+	c.Profile = name
+	c.Repos = profile.Repos
+	if profile.Token != "" || profile.TokenFile != "" || profile.TokenCommand != "" {
+		c.Token, c.TokenFile, c.TokenCommand = profile.Token, profile.TokenFile, profile.TokenCommand
+	}
+	if profile.ApiUrl != "" {
+		c.ApiUrl = profile.ApiUrl
+	}
+	if profile.CaFile != "" {
+		c.CaFile = profile.CaFile
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package appconfig
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const profilesConfig = `token: top-token
+repos:
+  - owner: octo
+    repo: top
+profiles:
+  work:
+    token: work-token
+    api_url: https://github.example.com/api/v3
+    repos:
+      - owner: corp
+        repo: service
+  oss:
+    repos:
+      - owner: octo
+        repo: library
+`
+
+func TestProfileReplacesTheReposAndDefaults(t *testing.T) {
+	dir := t.TempDir()
+	setupLocation(t, dir)
+	t.Setenv(EnvProfile, "")
+	t.Cleanup(func() { SetProfile("") })
+
+	path := filepath.Join(dir, "config.yml")
+	writeFile(t, path, profilesConfig)
+	SetPath(path)
+
+	describe := func() string {
+		conf := New()
+		if err := conf.Read(); err != nil {
+			return err.Error()
+		}
+		repo := conf.Repos[0]
+		return fmt.Sprintf("%s %s/%s %s %s", conf.Profile, repo.Owner, repo.Repo, repo.Token, repo.ApiUrl)
+	}
+
+	if actual := describe(); actual != " octo/top top-token " {
+		t.Errorf("Expected the repos at the top without a profile, but got %q", actual)
+	}
+
+	t.Setenv(EnvProfile, "oss")
+	if actual := describe(); actual != "oss octo/library top-token " {
+		t.Errorf("Expected the profile of $%s to inherit the token at the top, but got %q", EnvProfile, actual)
+	}
+
+	SetProfile("work")
+	if actual := describe(); actual != "work corp/service work-token https://github.example.com/api/v3" {
+		t.Errorf("Expected the profile of --profile to win with its own token and API, but got %q", actual)
+	}
+
+	SetProfile("home")
+	if actual := describe(); actual != `unknown profile "home", the config has oss, work` {
+		t.Errorf("Expected an unknown profile to be reported, but got %q", actual)
+	}
+}
+
+func TestNoProfileGoesBackToTheTopOfTheConfig(t *testing.T) {
+	dir := t.TempDir()
+	setupLocation(t, dir)
+	t.Setenv(EnvProfile, "work")
+
+	path := filepath.Join(dir, "config.yml")
+	writeFile(t, path, "default_profile: oss\n"+profilesConfig)
+	SetPath(path)
+
+	conf := New()
+	if err := conf.ReadProfile(NoProfile); err != nil {
+		t.Fatalf("Expected the config to be read, but got %v", err)
+	}
+
+	if conf.Profile != "" || len(conf.Repos) != 1 || conf.Repos[0].Repo != "top" || conf.Repos[0].Token != "top-token" {
+		t.Errorf("Expected the repos at the top of the config, but got %q %+v", conf.Profile, conf.Repos)
+	}
+}
+
+func TestValidateChecksProfiles(t *testing.T) {
+	config := `default_profile: home
+profiles:
+  work:
+    tokn: secret
+    repos:
+      - owner: corp
+        repo: service
+  oss: []
+`
+	problems, err := Validate([]byte(config))
+	if err != nil {
+		t.Fatalf("Expected the config to parse, but got %v", err)
+	}
+
+	expected := []string{
+		`1:18: default_profile "home" is not one of the profiles`,
+		`4:5: unknown key "tokn"`,
+		`8:8: profile "oss" must be a mapping of settings`,
+	}
+	actual := []string{}
+	for _, problem := range problems {
+		actual = append(actual, fmt.Sprintf("%d:%d: %s", problem.Line, problem.Column, problem.Message))
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected the problems\n%v\nbut got\n%v", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+}
@@ -0,0 +1,186 @@
+package appconfig
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// How long a token_command may take, which leaves time to unlock a password manager
+const tokenCommandTimeout = time.Minute
+
+// The environment variables a token is read from when the config doesn't give one, in order
+var tokenEnvVars = []string{"GITHUB_TOKEN", "GH_TOKEN"}
+
+// Runs a token_command and returns what it printed. With the terminal it may read from it, e.g. for a password
+// manager to ask for its passphrase. It is a global variable and thus able to get mocked by tests
+var runTokenCommand = func(command string, terminal bool) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+
+	// Without the terminal the command reads no input, and what it reports is kept for the error
+	stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
+	cmd.Stdout = &stdout
+	if terminal {
+		cmd.Stdin = os.Stdin
+		cmd.Stderr = os.Stderr
+	} else {
+		cmd.Stderr = &stderr
+	}
+
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%w: %s", err, message)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// Runs the token command and remembers the token it gave. On a reload it runs without the terminal, and the
+// token it gave before is used when it fails, e.g. as the password manager can't ask for its passphrase
+func (c *AppConfig) commandToken(command string) (string, error) {
+	output, err := runTokenCommand(command, !c.reloaded)
+	if err != nil {
+		if token, ok := c.commandTokens[command]; ok {
+			return token, nil
+		}
+		if c.reloaded {
+			return "", fmt.Errorf("token_command %q failed without access to the terminal: %w", command, err)
+		}
+		return "", fmt.Errorf("token_command %q failed: %w", command, err)
+	}
+
+	if token := firstLine(output); token != "" {
+		if c.commandTokens == nil {
+			c.commandTokens = map[string]string{}
+		}
+		c.commandTokens[command] = token
+	}
+	return output, nil
+}
+
+// The ways a token can be given, at the repo or at the top of the config
+type tokenSettings struct {
+	token   string
+	file    string
+	command string
+}
+
+// Resolves the token of every repo, recording where it came from in TokenSource.
+// A repo's own settings win over the global defaults, which win over the environment, and a repo without
+// any token is an error. Commands and files shared by several repos are only run and read once
+func (c *AppConfig) resolveTokens() error {
+	resolved := map[string]string{}
+
+	for i := range c.Repos {
+		repo := &c.Repos[i]
+
+		token, source, err := c.resolveToken(tokenSettings{repo.Token, repo.TokenFile, repo.TokenCommand}, "", resolved)
+		if err == nil && source == "" {
+			token, source, err = c.resolveToken(tokenSettings{c.Token, c.TokenFile, c.TokenCommand}, "default ", resolved)
+		}
+		if err != nil {
+			return fmt.Errorf("could not resolve the token of %s/%s: %w", repo.Owner, repo.Repo, err)
+		}
+
+		if source == "" {
+			token, source = TokenFromEnv()
+		}
+		if token == "" {
+			return fmt.Errorf("no token is set for %s/%s. Set token, token_file or token_command for the repo or at the top of the config or its profile, or $%s",
+				repo.Owner, repo.Repo, strings.Join(tokenEnvVars, " or $"))
+		}
+
+		repo.Token = token
+		repo.TokenSource = source
+	}
+
+	return nil
+}
+
+// Returns the token given by the settings and a description of where it came from,
+// or an empty source if the settings don't give a token
+func (c *AppConfig) resolveToken(settings tokenSettings, prefix string, resolved map[string]string) (string, string, error) {
+	switch {
+	case settings.token != "":
+		return settings.token, prefix + "token", nil
+	case settings.file != "":
+		token, err := cached(resolved, "token_file:"+settings.file, func() (string, error) {
+			return readTokenFile(settings.file)
+		})
+		return token, prefix + "token_file " + settings.file, err
+	case settings.command != "":
+		token, err := cached(resolved, "token_command:"+settings.command, func() (string, error) {
+			return c.commandToken(settings.command)
+		})
+		return token, prefix + "token_command " + settings.command, err
+	default:
+		return "", "", nil
+	}
+}
+
+func cached(resolved map[string]string, key string, resolve func() (string, error)) (string, error) {
+	if token, ok := resolved[key]; ok {
+		return token, nil
+	}
+
+	token, err := resolve()
+	if err != nil {
+		return "", err
+	}
+
+	token = firstLine(token)
+	if token == "" {
+		return "", fmt.Errorf("%s gave an empty token", strings.Replace(key, ":", " ", 1))
+	}
+
+	resolved[key] = token
+	return token, nil
+}
+
+// Password managers print the secret on the first line, followed by other fields of the entry
+func firstLine(output string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(line)
+}
+
+func readTokenFile(path string) (string, error) {
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, strings.TrimPrefix(path, "~/"))
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("could not read token_file: %w", err)
+	}
+	return string(contents), nil
+}
+
+// TokenFromEnv returns the token from $GITHUB_TOKEN or $GH_TOKEN and the variable it came from,
+// or an empty token and the source "none"
+func TokenFromEnv() (string, string) {
+	for _, name := range tokenEnvVars {
+		if token := strings.TrimSpace(os.Getenv(name)); token != "" {
+			return token, "$" + name
+		}
+	}
+	return "", "none"
+}
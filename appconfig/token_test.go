@@ -0,0 +1,161 @@
+package appconfig
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTokensResolveFromRepoThenDefaultsThenEnvironment(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GH_TOKEN", "from-env")
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	commands := 0
+	previous := runTokenCommand
+	runTokenCommand = func(command string, terminal bool) (string, error) {
+		commands++
+		return "from-command\n", nil
+	}
+	t.Cleanup(func() { runTokenCommand = previous })
+
+	conf := AppConfig{
+		TokenCommand: "pass show gh",
+		Repos: []Repo{
+			{Owner: "octo", Repo: "plain", Token: "from-config"},
+			{Owner: "octo", Repo: "file", TokenFile: tokenFile},
+			{Owner: "octo", Repo: "inherits"},
+			{Owner: "octo", Repo: "inherits-too"},
+		},
+	}
+	if err := conf.resolveTokens(); err != nil {
+		t.Fatalf("Expected the tokens to resolve, but got %v", err)
+	}
+
+	expected := []struct{ token, source string }{
+		{"from-config", "token"},
+		{"from-file", "token_file " + tokenFile},
+		{"from-command", "default token_command pass show gh"},
+		{"from-command", "default token_command pass show gh"},
+	}
+	for i, want := range expected {
+		if conf.Repos[i].Token != want.token || conf.Repos[i].TokenSource != want.source {
+			t.Errorf("Expected %v from %q, but got %v from %q", want.token, want.source, conf.Repos[i].Token, conf.Repos[i].TokenSource)
+		}
+	}
+	if commands != 1 {
+		t.Errorf("Expected the token command to run once, but it ran %v times", commands)
+	}
+
+	conf = AppConfig{Repos: []Repo{{Owner: "octo", Repo: "env"}}}
+	conf.resolveTokens()
+	if conf.Repos[0].Token != "from-env" || conf.Repos[0].TokenSource != "$GH_TOKEN" {
+		t.Errorf("Expected the token from $GH_TOKEN, but got %v from %q", conf.Repos[0].Token, conf.Repos[0].TokenSource)
+	}
+
+	t.Setenv("GH_TOKEN", "")
+	conf = AppConfig{Repos: []Repo{{Owner: "octo", Repo: "none"}}}
+	if err := conf.resolveTokens(); err == nil || !strings.Contains(err.Error(), "no token is set for octo/none") {
+		t.Errorf("Expected a repo without any token to be an error, but got %v", err)
+	}
+}
+
+func TestEmptyTokenCommandOutputIsAnError(t *testing.T) {
+	previous := runTokenCommand
+	runTokenCommand = func(command string, terminal bool) (string, error) { return "\n", nil }
+	t.Cleanup(func() { runTokenCommand = previous })
+
+	conf := AppConfig{Repos: []Repo{{Owner: "octo", Repo: "repo", TokenCommand: "pass show gh"}}}
+	if err := conf.resolveTokens(); err == nil {
+		t.Fatalf("Expected an empty token to be rejected")
+	}
+}
+
+func TestOnlyTheFirstLineIsTheToken(t *testing.T) {
+	previous := runTokenCommand
+	runTokenCommand = func(command string, terminal bool) (string, error) {
+		return "ghp_fromcommand\nlogin: octocat\nurl: github.com\n", nil
+	}
+	t.Cleanup(func() { runTokenCommand = previous })
+
+	path := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(path, []byte("\n  ghp_fromfile\r\nsecond line\n"), 0600)
+
+	conf := AppConfig{Repos: []Repo{
+		{Owner: "octo", Repo: "command", TokenCommand: "pass show gh"},
+		{Owner: "octo", Repo: "file", TokenFile: path},
+	}}
+	if err := conf.resolveTokens(); err != nil {
+		t.Fatalf("Expected the tokens to resolve, but got %v", err)
+	}
+
+	if conf.Repos[0].Token != "ghp_fromcommand" {
+		t.Errorf("Expected the first line of the command output, but got %q", conf.Repos[0].Token)
+	}
+	if conf.Repos[1].Token != "ghp_fromfile" {
+		t.Errorf("Expected the first line of the file, but got %q", conf.Repos[1].Token)
+	}
+}
+
+func TestReloadsRunTokenCommandsAgainWithoutTheTerminal(t *testing.T) {
+	dir := t.TempDir()
+	setupLocation(t, dir)
+	path := filepath.Join(dir, "config", "lazyworkflows", "config.yml")
+	writeFile(t, path, "token_command: pass show gh\nrepos:\n  - owner: octo\n    repo: repo\n")
+
+	// The password manager gives a new token each time, until it is locked
+	runs, locked := 0, false
+	previous := runTokenCommand
+	runTokenCommand = func(command string, terminal bool) (string, error) {
+		if terminal == (runs > 0) {
+			t.Errorf("Expected only the first run to have the terminal, but run %v had it: %v", runs+1, terminal)
+		}
+		if locked {
+			return "", errors.New("exit status 2: gpg: decryption failed: No pinentry")
+		}
+		runs++
+		return "ghp_" + strconv.Itoa(runs), nil
+	}
+	t.Cleanup(func() { runTokenCommand = previous })
+
+	conf := New()
+	if err := conf.Read(); err != nil {
+		t.Fatalf("Expected the config to load, but got %v", err)
+	}
+
+	rotated, err := conf.Reload("")
+	if err != nil || rotated.Repos[0].Token != "ghp_2" {
+		t.Fatalf("Expected the reload to pick up the new token, but got %+v %v", rotated.Repos, err)
+	}
+
+	locked = true
+	kept, err := rotated.Reload("")
+	if err != nil || kept.Repos[0].Token != "ghp_2" {
+		t.Errorf("Expected the token of the last run to be kept when the command fails, but got %+v %v", kept.Repos, err)
+	}
+
+	writeFile(t, path, "token_command: pass show other\nrepos:\n  - owner: octo\n    repo: repo\n")
+	_, err = kept.Reload("")
+	if err == nil || !strings.Contains(err.Error(), "without access to the terminal") || !strings.Contains(err.Error(), "No pinentry") {
+		t.Errorf("Expected a new command that fails to explain why, but got %v", err)
+	}
+}
+
+func TestTokenCommandsWithoutTheTerminalReportWhatTheyPrinted(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("runs a shell command")
+	}
+
+	_, err := runTokenCommand("echo 'gpg: decryption failed: No pinentry' >&2; exit 2", false)
+	if err == nil || !strings.Contains(err.Error(), "No pinentry") {
+		t.Errorf("Expected the error output of the command, but got %v", err)
+	}
+}
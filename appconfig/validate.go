@@ -0,0 +1,428 @@
+package appconfig
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GitHub logins are alphanumeric with single hyphens between, up to 39 characters
+var ownerPattern = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9]|-[A-Za-z0-9]){0,38}$`)
+
+// Repo names are made of letters, digits, dots, hyphens and underscores, up to 100 characters
+var repoPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,100}$`)
+
+// The settings only the global config may give. A project config comes with the code of a project, which
+// anyone may have changed. Through these it could run commands or send the global token to another host
+var globalOnlyKeys = map[string]bool{"token": true, "token_file": true, "token_command": true, "api_url": true, "ca_file": true}
+
+// Problem is a single mistake in the config file, at the line and column it was found
+type Problem struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+// ValidationError lists every problem found in a config file
+type ValidationError struct {
+	Path     string
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		// TOML files are converted before they are checked, which loses the positions
+		if problem.Line == 0 {
+			lines[i] = fmt.Sprintf("%s: %s", e.Path, problem.Message)
+			continue
+		}
+		lines[i] = fmt.Sprintf("%s:%d:%d: %s", e.Path, problem.Line, problem.Column, problem.Message)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ValidationErrors lists the problems of every config file that has any, in the order the files are merged
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// ValidateFiles reads the config files, which are merged in the order given, and checks every one of them.
+// A *ValidationError is returned for each file with problems. An error is only returned when a file
+// can't be read or isn't in its format at all
+func ValidateFiles(paths ...string) (ValidationErrors, error) {
+	documents, err := readDocuments(paths)
+	if err != nil {
+		return nil, err
+	}
+	return checkDocuments(documents), nil
+}
+
+// Checks config files that are merged together
+func checkDocuments(documents []document) ValidationErrors {
+	invalid := ValidationErrors{}
+	for _, doc := range documents {
+		if problems := check(doc.root, doc.project); len(problems) > 0 {
+			invalid = append(invalid, &ValidationError{Path: doc.path, Problems: problems})
+		}
+	}
+	return invalid
+}
+
+// Validate checks the contents of a YAML config file and returns every problem found in it.
+// An error is only returned when the contents aren't YAML at all.
+// Problems never contain the values of tokens
+func Validate(contents []byte) ([]Problem, error) {
+	root, err := parse(FormatYaml, contents)
+	if err != nil {
+		return nil, err
+	}
+	return check(root, false), nil
+}
+
+// Checks the settings of a config file. Whether every repo ends up with a token isn't checked here, as it
+// depends on the other files and the environment. The config of a project may not give the settings that
+// only the global config may give
+func check(root *yaml.Node, project bool) []Problem {
+	// An empty file is an empty config
+	if root == nil {
+		return nil
+	}
+
+	v := validator{}
+	if root.Kind != yaml.MappingNode {
+		v.report(root, "the config must be a mapping of settings")
+		return v.problems
+	}
+
+	settings := v.mapping(root, keysOf(reflect.TypeOf(AppConfig{})))
+	v.settings(settings)
+
+	if repos, ok := settings["repos"]; ok {
+		v.repos(repos)
+	}
+	if profiles, ok := settings["profiles"]; ok {
+		v.profiles(profiles, settings["default_profile"])
+	}
+	if project {
+		v.globalOnly(root)
+	}
+
+	// In the order they appear in the file
+	sort.SliceStable(v.problems, func(i, j int) bool {
+		if v.problems[i].Line != v.problems[j].Line {
+			return v.problems[i].Line < v.problems[j].Line
+		}
+		return v.problems[i].Column < v.problems[j].Column
+	})
+	return v.problems
+}
+
+type validator struct {
+	problems []Problem
+}
+
+func (v *validator) report(node *yaml.Node, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{Line: node.Line, Column: node.Column, Message: fmt.Sprintf(format, args...)})
+}
+
+// Returns the values of a mapping by key, reporting unknown and duplicate keys on the way
+func (v *validator) mapping(node *yaml.Node, known map[string]bool) map[string]*yaml.Node {
+	values := map[string]*yaml.Node{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+
+		switch {
+		case !known[key.Value]:
+			v.report(key, "unknown key %q", key.Value)
+		case values[key.Value] != nil:
+			v.report(key, "%q is already set on line %d", key.Value, values[key.Value].Line)
+		default:
+			values[key.Value] = value
+		}
+	}
+	return values
+}
+
+// Checks the top level settings, except for the repos
+func (v *validator) settings(settings map[string]*yaml.Node) {
+	for _, key := range []string{"token", "token_file", "token_command", "ca_file"} {
+		v.scalar(settings[key], key)
+	}
+	v.apiUrl(settings["api_url"])
+	v.number(settings["per_page"], "per_page", 0, 100)
+	v.number(settings["concurrency"], "concurrency", 0, 0)
+	v.duration(settings["timeout"], "timeout")
+	v.duration(settings["discovery_interval"], "discovery_interval")
+	v.scalar(settings["default_profile"], "default_profile")
+	v.scalar(settings["git_remote"], "git_remote")
+
+	if cache := settings["cache"]; v.scalar(cache, "cache") {
+		switch cache.Value {
+		case CacheMemory, CacheDisk, CacheNone:
+		default:
+			v.report(cache, "cache must be one of %s, %s or %s, got %q", CacheMemory, CacheDisk, CacheNone, cache.Value)
+		}
+	}
+}
+
+func (v *validator) repos(node *yaml.Node) {
+	if node.Tag == "!!null" {
+		return
+	}
+	if node.Kind != yaml.SequenceNode {
+		v.report(node, "repos must be a list")
+		return
+	}
+
+	known := keysOf(reflect.TypeOf(Repo{}))
+	firstSeen := map[string]int{}
+	for _, entry := range node.Content {
+		if entry.Kind != yaml.MappingNode {
+			v.report(entry, "a repo must be a mapping with an owner and a repo")
+			continue
+		}
+
+		settings := v.mapping(entry, known)
+		for _, key := range []string{"token", "token_file", "token_command", "ca_file"} {
+			v.scalar(settings[key], key)
+		}
+		v.apiUrl(settings["api_url"])
+		for _, key := range []string{"include", "exclude"} {
+			v.globs(settings[key], key)
+		}
+		v.list(settings["topics"], "topics")
+
+		owner, repo := settings["owner"], settings["repo"]
+		ownerOk := v.required(entry, owner, "owner")
+		repoOk := v.required(entry, repo, "repo")
+		if ownerOk && !ownerPattern.MatchString(owner.Value) {
+			v.report(owner, "owner %q is not a valid GitHub user or organization name", owner.Value)
+			ownerOk = false
+		}
+		if repoOk {
+			repoOk = v.repoName(repo)
+		}
+
+		if ownerOk && repoOk {
+			key := strings.ToLower(owner.Value + "/" + repo.Value)
+			if line, ok := firstSeen[key]; ok {
+				v.report(entry, "repo %s/%s is already configured on line %d", owner.Value, repo.Value, line)
+			} else {
+				firstSeen[key] = entry.Line
+			}
+		}
+	}
+}
+
+// Checks every profile like the top of the config, and that the default profile is one of them
+func (v *validator) profiles(node *yaml.Node, defaultProfile *yaml.Node) {
+	if node.Tag == "!!null" {
+		return
+	}
+	if node.Kind != yaml.MappingNode {
+		v.report(node, "profiles must be a mapping of names to profiles")
+		return
+	}
+
+	known := keysOf(reflect.TypeOf(Profile{}))
+	firstSeen := map[string]int{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name, profile := node.Content[i], node.Content[i+1]
+		if line, ok := firstSeen[name.Value]; ok {
+			v.report(name, "profile %q is already set on line %d", name.Value, line)
+			continue
+		}
+		firstSeen[name.Value] = name.Line
+
+		if profile.Kind != yaml.MappingNode {
+			v.report(profile, "profile %q must be a mapping of settings", name.Value)
+			continue
+		}
+
+		settings := v.mapping(profile, known)
+		for _, key := range []string{"token", "token_file", "token_command", "ca_file"} {
+			v.scalar(settings[key], key)
+		}
+		v.apiUrl(settings["api_url"])
+		if repos, ok := settings["repos"]; ok {
+			v.repos(repos)
+		}
+	}
+
+	if defaultProfile != nil && defaultProfile.Kind == yaml.ScalarNode && defaultProfile.Value != "" {
+		if _, ok := firstSeen[defaultProfile.Value]; !ok {
+			v.report(defaultProfile, "default_profile %q is not one of the profiles", defaultProfile.Value)
+		}
+	}
+}
+
+func (v *validator) repoName(node *yaml.Node) bool {
+	if strings.ContainsAny(node.Value, "*?[") {
+		if _, err := path.Match(node.Value, ""); err != nil {
+			v.report(node, "repo %q is not a valid glob", node.Value)
+			return false
+		}
+		return true
+	}
+
+	if strings.Contains(node.Value, "/") {
+		v.report(node, "repo must be the name of the repo without its owner, got %q", node.Value)
+		return false
+	}
+	if !repoPattern.MatchString(node.Value) || node.Value == "." || node.Value == ".." {
+		v.report(node, "repo %q is not a valid repository name", node.Value)
+		return false
+	}
+	return true
+}
+
+// Reports a missing or empty setting of the mapping
+func (v *validator) required(entry *yaml.Node, node *yaml.Node, key string) bool {
+	if node == nil {
+		v.report(entry, "%s is required", key)
+		return false
+	}
+	if !v.scalar(node, key) {
+		return false
+	}
+	if node.Value == "" {
+		v.report(node, "%s must not be empty", key)
+		return false
+	}
+	return true
+}
+
+// Reports a setting that isn't a single value. The value itself is never reported, as it may be a token
+func (v *validator) scalar(node *yaml.Node, key string) bool {
+	if node == nil {
+		return false
+	}
+	if node.Kind != yaml.ScalarNode {
+		v.report(node, "%s must be a single value", key)
+		return false
+	}
+	return true
+}
+
+func (v *validator) list(node *yaml.Node, key string) bool {
+	if node == nil {
+		return false
+	}
+	if node.Kind != yaml.SequenceNode {
+		v.report(node, "%s must be a list", key)
+		return false
+	}
+	for _, item := range node.Content {
+		if !v.scalar(item, key+" entries") {
+			return false
+		}
+	}
+	return true
+}
+
+func (v *validator) globs(node *yaml.Node, key string) {
+	if !v.list(node, key) {
+		return
+	}
+	for _, item := range node.Content {
+		if _, err := path.Match(item.Value, ""); err != nil {
+			v.report(item, "%q in %s is not a valid glob", item.Value, key)
+		}
+	}
+}
+
+func (v *validator) apiUrl(node *yaml.Node) {
+	if !v.scalar(node, "api_url") || node.Value == "" {
+		return
+	}
+	parsed, err := url.Parse(node.Value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		v.report(node, "api_url must be an http or https URL, got %q", node.Value)
+	}
+}
+
+// Reports numbers that aren't whole or are out of bounds. A max of zero means there is no upper bound
+func (v *validator) number(node *yaml.Node, key string, min int, max int) {
+	if !v.scalar(node, key) {
+		return
+	}
+	number, err := strconv.Atoi(node.Value)
+	switch {
+	case err != nil:
+		v.report(node, "%s must be a whole number, got %q", key, node.Value)
+	case number < min:
+		v.report(node, "%s must be at least %d, got %d", key, min, number)
+	case max > 0 && number > max:
+		v.report(node, "%s must be at most %d, got %d", key, max, number)
+	}
+}
+
+func (v *validator) duration(node *yaml.Node, key string) {
+	if !v.scalar(node, key) {
+		return
+	}
+	if duration, err := time.ParseDuration(node.Value); err != nil || duration < 0 {
+		v.report(node, "%s must be a duration like 30s or 5m, got %q", key, node.Value)
+	}
+}
+
+// Reports the settings that only the global config may give, at the top, in repos and in profiles
+func (v *validator) globalOnly(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if globalOnlyKeys[key.Value] {
+				v.report(key, "%s can't be set in a project config, set it in the global config instead", key.Value)
+			}
+			// The keys of profiles are their names
+			if key.Value == "profiles" && value.Kind == yaml.MappingNode {
+				for j := 1; j < len(value.Content); j += 2 {
+					v.globalOnly(value.Content[j])
+				}
+				continue
+			}
+			v.globalOnly(value)
+		}
+	case yaml.SequenceNode:
+		for _, entry := range node.Content {
+			v.globalOnly(entry)
+		}
+	}
+}
+
+// The keys of the fields of a config struct, as yaml.v3 names them
+func keysOf(structType reflect.Type) map[string]bool {
+	keys := map[string]bool{}
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		// What the config is read with rather than from
+		if field.PkgPath != "" {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = strings.ToLower(field.Name)
+		}
+		keys[name] = true
+	}
+	return keys
+}
@@ -0,0 +1,76 @@
+package appconfig
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const invalidConfig = `cache: redis
+timeout: soon
+tokne: ghp_secretvalue
+repos:
+  - owner: octo
+    repo: present
+    token: ghp_secretvalue
+  - owner: Octo
+    repo: Present
+    token: ghp_secretvalue
+  - owner: -octo
+    repo: octo/other
+    token: [ghp_secretvalue]
+  - repo: "[broken"
+    include: ["*"]
+`
+
+func TestValidateReportsEveryProblemWithItsPosition(t *testing.T) {
+	problems, err := Validate([]byte(invalidConfig))
+	if err != nil {
+		t.Fatalf("Expected the config to parse, but got %v", err)
+	}
+
+	expected := []string{
+		`1:8: cache must be one of memory, disk or none, got "redis"`,
+		`2:10: timeout must be a duration like 30s or 5m, got "soon"`,
+		`3:1: unknown key "tokne"`,
+		`8:5: repo Octo/Present is already configured on line 5`,
+		`11:12: owner "-octo" is not a valid GitHub user or organization name`,
+		`12:11: repo must be the name of the repo without its owner, got "octo/other"`,
+		`13:12: token must be a single value`,
+		`14:5: owner is required`,
+		`14:11: repo "[broken" is not a valid glob`,
+	}
+
+	actual := []string{}
+	for _, problem := range problems {
+		actual = append(actual, fmt.Sprintf("%d:%d: %s", problem.Line, problem.Column, problem.Message))
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected the problems\n%v\nbut got\n%v", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+
+	root, _ := parse(FormatYaml, []byte(invalidConfig))
+	invalid := checkDocuments([]document{{path: "config.yml", root: root}})
+	if len(invalid) != 1 || strings.Contains(invalid[0].Error(), "ghp_secretvalue") {
+		t.Errorf("Expected the problems to be reported without the token, but got %v", invalid)
+	}
+}
+
+func TestValidateAcceptsAValidConfig(t *testing.T) {
+	config := `token_command: pass show gh
+per_page: 50
+discovery_interval: 30m
+repos:
+  - owner: octo-org
+    repo: "*"
+    exclude: ["*-legacy"]
+  - owner: octo-org
+    repo: octo.github.io
+    api_url: https://github.example.com/api/v3
+`
+
+	problems, err := Validate([]byte(config))
+	if err != nil || len(problems) > 0 {
+		t.Errorf("Expected no problems, but got %v %v", problems, err)
+	}
+}
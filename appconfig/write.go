@@ -0,0 +1,99 @@
+package appconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Write creates the config file at the path, with comments explaining the settings such that it can be
+// edited by hand later on. An existing file is never overwritten. The file is only readable by its owner,
+// as it may hold a token. Only YAML is written, as it is the only format that keeps the comments
+func Write(configFilePath string, conf AppConfig) error {
+	if format := FormatOf(configFilePath); format != FormatYaml {
+		return fmt.Errorf("only YAML config files can be written, but %s is %s. Write it by hand instead", configFilePath, format)
+	}
+
+	if err := ensureCreated(filepath.Dir(configFilePath)); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(configFilePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(Render(conf)); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Render returns the config as commented YAML. Only the settings that are set are written,
+// the others are left as comments showing how to set them
+func Render(conf AppConfig) []byte {
+	builder := strings.Builder{}
+
+	builder.WriteString("# The config of lazyworkflows. Run `lazyworkflows config validate` after editing it.\n")
+	builder.WriteString("#\n")
+	builder.WriteString("# Tokens are taken from a repo's own settings, then from the ones below, then from $GITHUB_TOKEN or $GH_TOKEN.\n")
+	builder.WriteString("# Rather than a plain token, token_file reads it from a file and token_command from what a command prints.\n")
+	writeSetting(&builder, "", "token", conf.Token)
+	writeSetting(&builder, "", "token_file", conf.TokenFile)
+	writeSetting(&builder, "", "token_command", conf.TokenCommand)
+	if conf.Token == "" && conf.TokenFile == "" && conf.TokenCommand == "" {
+		builder.WriteString("# token_command: pass show github/token\n")
+	}
+
+	builder.WriteString("\n# The API of a GitHub Enterprise Server instance, for repos that don't set their own\n")
+	if conf.ApiUrl != "" {
+		writeSetting(&builder, "", "api_url", conf.ApiUrl)
+	} else {
+		builder.WriteString("# api_url: https://github.example.com/api/v3\n")
+	}
+
+	builder.WriteString("\n# Where API responses are cached between requests: memory, disk or none\n")
+	cache := conf.Cache
+	if cache == "" {
+		cache = CacheMemory
+	}
+	writeSetting(&builder, "", "cache", cache)
+
+	builder.WriteString("\n# The repos to show. A repo may be a glob like \"*\" to discover every repo of its owner,\n")
+	builder.WriteString("# narrowed down by include and exclude globs and by topics\n")
+	builder.WriteString("repos:\n")
+	for _, repo := range conf.Repos {
+		writeSetting(&builder, "  - ", "owner", repo.Owner)
+		writeSetting(&builder, "    ", "repo", repo.Repo)
+		writeSetting(&builder, "    ", "token", repo.Token)
+		writeSetting(&builder, "    ", "token_file", repo.TokenFile)
+		writeSetting(&builder, "    ", "token_command", repo.TokenCommand)
+		writeSetting(&builder, "    ", "api_url", repo.ApiUrl)
+	}
+	if len(conf.Repos) == 0 {
+		builder.WriteString("  # - owner: octo-org\n  #   repo: octo-repo\n")
+	}
+
+	return []byte(builder.String())
+}
+
+// Writes a single setting, quoting the value as YAML needs it. Empty settings are left out
+func writeSetting(builder *strings.Builder, indent string, key string, value string) {
+	if value == "" {
+		return
+	}
+	builder.WriteString(fmt.Sprintf("%s%s: %s\n", indent, key, quote(value)))
+}
+
+func quote(value string) string {
+	node := yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	encoded, err := yaml.Marshal(&node)
+	if err != nil {
+		return fmt.Sprintf("%q", value)
+	}
+	return strings.TrimSuffix(string(encoded), "\n")
+}
@@ -0,0 +1,49 @@
+package appconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestWrittenConfigIsValidAndReadsBack(t *testing.T) {
+	conf := AppConfig{
+		Token: "ghp_token: with a colon",
+		Repos: []Repo{
+			{Owner: "octo-org", Repo: "*"},
+			{Owner: "octo-org", Repo: "octo-repo", TokenCommand: "pass show other"},
+		},
+	}
+	path := filepath.Join(t.TempDir(), "nested", "config.yml")
+
+	if err := Write(path, conf); err != nil {
+		t.Fatalf("Expected the config to be written, but got %v", err)
+	}
+	if err := Write(path, conf); err == nil {
+		t.Errorf("Expected an existing config to be left alone")
+	}
+
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the config to be readable by its owner only, but got %v", info.Mode().Perm())
+	}
+
+	contents, _ := os.ReadFile(path)
+	if problems, err := Validate(contents); err != nil || len(problems) > 0 {
+		t.Fatalf("Expected the written config to be valid, but got %v %v", problems, err)
+	}
+	if !strings.Contains(string(contents), "# Rather than a plain token") {
+		t.Errorf("Expected the settings to be explained, but got\n%s", contents)
+	}
+
+	read := AppConfig{}
+	if err := yaml.Unmarshal(contents, &read); err != nil {
+		t.Fatal(err)
+	}
+	if read.Token != conf.Token || read.Cache != CacheMemory || len(read.Repos) != 2 || read.Repos[1].TokenCommand != "pass show other" {
+		t.Errorf("Expected the config to read back, but got %+v", read)
+	}
+}
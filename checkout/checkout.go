@@ -0,0 +1,195 @@
+package checkout
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoCheckout is returned by Find when the directory isn't inside a git working tree
+var ErrNoCheckout = errors.New("not inside a git checkout")
+
+// Checkout is the git working tree a directory is in
+type Checkout struct {
+	// The top directory of the working tree
+	Root string
+	// The branch checked out. It is empty when HEAD is detached
+	Branch string
+	// The URLs of the remotes, by their names
+	Remotes map[string]string
+}
+
+// Remote is a repo on a GitHub host, as read from the URL of a git remote
+type Remote struct {
+	// The host serving the repo, e.g. github.com or the host of a GitHub Enterprise Server instance
+	Host  string
+	Owner string
+	Repo  string
+}
+
+// Find looks for the git working tree holding the directory, walking up from it. The checkout is read
+// from its files, git itself isn't needed
+func Find(dir string) (Checkout, error) {
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		if info, err := os.Stat(dotGit); err == nil {
+			gitDir, commonDir, err := gitDirs(dotGit, info)
+			if err != nil {
+				return Checkout{}, err
+			}
+			return read(dir, gitDir, commonDir)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return Checkout{}, ErrNoCheckout
+		}
+		dir = parent
+	}
+}
+
+// Resolves where the files of the checkout are. Worktrees and submodules have a .git file pointing
+// at their git directory, and worktrees share the config of the main checkout through its common directory
+func gitDirs(dotGit string, info os.FileInfo) (string, string, error) {
+	if info.IsDir() {
+		return dotGit, dotGit, nil
+	}
+
+	contents, err := os.ReadFile(dotGit)
+	if err != nil {
+		return "", "", err
+	}
+	gitDir := strings.TrimSpace(strings.TrimPrefix(string(contents), "gitdir:"))
+	if gitDir == "" || !strings.HasPrefix(string(contents), "gitdir:") {
+		return "", "", fmt.Errorf("%s doesn't point at a git directory", dotGit)
+	}
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(dotGit), gitDir)
+	}
+
+	commonDir := gitDir
+	if common, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir = strings.TrimSpace(string(common))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+	}
+	return gitDir, commonDir, nil
+}
+
+func read(root string, gitDir string, commonDir string) (Checkout, error) {
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return Checkout{}, err
+	}
+
+	config, err := os.ReadFile(filepath.Join(commonDir, "config"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Checkout{}, err
+	}
+
+	return Checkout{
+		Root:    root,
+		Branch:  branchOf(head),
+		Remotes: remotesOf(config),
+	}, nil
+}
+
+// Reads the branch from HEAD, which names it as a ref unless a commit is checked out
+func branchOf(head []byte) string {
+	ref := strings.TrimSpace(string(head))
+	if !strings.HasPrefix(ref, "ref: refs/heads/") {
+		return ""
+	}
+	return strings.TrimPrefix(ref, "ref: refs/heads/")
+}
+
+// Reads the URLs of the [remote "name"] sections of a git config
+func remotesOf(config []byte) map[string]string {
+	remotes := map[string]string{}
+	remote := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(config))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			remote = ""
+			section := strings.TrimSpace(strings.Trim(line, "[]"))
+			if name, found := cutPrefixFold(section, "remote "); found {
+				remote = strings.Trim(strings.TrimSpace(name), `"`)
+			}
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if remote == "" || !found || !strings.EqualFold(strings.TrimSpace(key), "url") {
+			continue
+		}
+		// The first URL of a remote is the one fetched from
+		if _, ok := remotes[remote]; !ok {
+			remotes[remote] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+
+	return remotes
+}
+
+func cutPrefixFold(s string, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
+
+// ParseUrl reads the host, owner and repo from the URL of a remote. SSH URLs, including the
+// user@host:owner/repo form, and HTTPS URLs are understood
+func ParseUrl(remoteUrl string) (Remote, error) {
+	host, path := "", ""
+
+	if strings.Contains(remoteUrl, "://") {
+		parsed, err := url.Parse(remoteUrl)
+		if err != nil {
+			return Remote{}, fmt.Errorf("invalid remote URL %q: %w", remoteUrl, err)
+		}
+		host, path = parsed.Host, parsed.Path
+		// The port of an SSH server says nothing about where the API is
+		if parsed.Scheme != "http" && parsed.Scheme != "https" {
+			host = parsed.Hostname()
+		}
+	} else {
+		// The scp-like form, e.g. git@github.com:owner/repo.git
+		hostPart, pathPart, found := strings.Cut(remoteUrl, ":")
+		if !found || strings.Contains(hostPart, "/") {
+			return Remote{}, fmt.Errorf("remote URL %q is not an SSH or HTTPS URL", remoteUrl)
+		}
+		if _, afterUser, hasUser := strings.Cut(hostPart, "@"); hasUser {
+			hostPart = afterUser
+		}
+		host, path = hostPart, pathPart
+	}
+
+	// GitHub serves SSH on port 443 through its own host, for networks that block port 22
+	if strings.EqualFold(host, "ssh.github.com") {
+		host = "github.com"
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if host == "" || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Remote{}, fmt.Errorf("remote URL %q doesn't point at an owner and repo", remoteUrl)
+	}
+
+	return Remote{
+		Host:  strings.ToLower(host),
+		Owner: parts[0],
+		Repo:  strings.TrimSuffix(parts[1], ".git"),
+	}, nil
+}
@@ -0,0 +1,297 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/andreaswachs/lazyworkflows/appconfig"
+	"github.com/andreaswachs/lazyworkflows/consumer"
+	"github.com/andreaswachs/lazyworkflows/discovery"
+	"github.com/andreaswachs/lazyworkflows/meta"
+)
+
+// Exit codes of the subcommands
+const (
+	ExitOk      = 0
+	ExitFailure = 1
+	ExitUsage   = 2
+)
+
+// Where output goes, and how the config and consumer are made.
+// They are global variables and thus able to get mocked by tests
+var (
+	stdout      io.Writer = os.Stdout
+	stderr      io.Writer = os.Stderr
+	loadConfig            = defaultLoadConfig
+	newConsumer           = consumer.New
+	configPaths           = appconfig.Paths
+)
+
+// A subcommand, e.g. list or dispatch
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+// Returned by commands when they were called the wrong way
+type usageError struct {
+	message string
+}
+
+func (e usageError) Error() string {
+	return e.message
+}
+
+// Returned by commands that report their outcome through the exit code alone, like watch
+type exitError struct {
+	code int
+}
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit code %d", e.code)
+}
+
+func commands() []command {
+	return []command{
+		{"list", "[--repo owner/name]", "List the workflows of one or every configured repo", runList},
+		{"get", "--repo owner/name <workflow>", "Show a single workflow", runGet},
+		{"dispatch", "--repo owner/name [--ref <ref>] [--input key=value]... [--watch] <workflow>", "Trigger a workflow_dispatch event", runDispatch},
+		{"enable", "--repo owner/name <workflow>", "Enable a workflow", runEnable},
+		{"disable", "--repo owner/name <workflow>", "Disable a workflow", runDisable},
+		{"runs", "--repo owner/name [--workflow <workflow>] [filters]", "List recent workflow runs", runRuns},
+		{"jobs", "--repo owner/name [--attempt n] <run id>", "List the jobs and steps of a run", runJobs},
+		{"watch", "--repo owner/name <run id>", "Wait for a run to complete and exit with its conclusion", runWatch},
+		{"config", "validate", "Check the config file and report every problem in it", runConfig},
+	}
+}
+
+// Run executes the subcommand named by the first argument and returns the exit code for the process
+func Run(args []string) int {
+	args, err := GlobalFlags(args)
+	if err != nil {
+		return exitCode(err)
+	}
+
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(stdout)
+		return ExitOk
+	}
+	if args[0] == "version" || args[0] == "--version" {
+		fmt.Fprintf(stdout, "%s %s\n", meta.AppName, meta.Version)
+		return ExitOk
+	}
+
+	for _, cmd := range commands() {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		return exitCode(cmd.run(ctx, args[1:]))
+	}
+
+	fmt.Fprintf(stderr, "%s: unknown command %q\n\n", meta.AppName, args[0])
+	printUsage(stderr)
+	return ExitUsage
+}
+
+// The flags that may be given ahead of the command as well as to it, and what they set
+var globalFlags = []struct {
+	name  string
+	usage string
+	set   func(string)
+}{
+	{"config", "Read the config from this file", appconfig.SetPath},
+	{"profile", "Use the repos and token of this profile of the config", appconfig.SetProfile},
+}
+
+// GlobalFlags applies the flags given ahead of the command, like --config, and returns the arguments after them
+func GlobalFlags(args []string) ([]string, error) {
+	for len(args) > 0 {
+		if !strings.HasPrefix(args[0], "-") {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[0], "-"), "=")
+		set := globalFlag(name)
+		if set == nil {
+			break
+		}
+		if !hasValue {
+			if len(args) < 2 {
+				return nil, usageError{fmt.Sprintf("--%s needs a value", name)}
+			}
+			value, args = args[1], args[1:]
+		}
+		set(value)
+		args = args[1:]
+	}
+	return args, nil
+}
+
+func globalFlag(name string) func(string) {
+	for _, global := range globalFlags {
+		if global.name == name {
+			return global.set
+		}
+	}
+	return nil
+}
+
+// Turns the error of a command into an exit code, reporting it on the way
+func exitCode(err error) int {
+	var usage usageError
+	var exit exitError
+	switch {
+	case err == nil:
+		return ExitOk
+	case errors.Is(err, flag.ErrHelp):
+		return ExitOk
+	case errors.As(err, &usage):
+		fmt.Fprintf(stderr, "%s: %s\n", meta.AppName, usage.message)
+		return ExitUsage
+	case errors.As(err, &exit):
+		return exit.code
+	default:
+		fmt.Fprintf(stderr, "%s: %v\n", meta.AppName, err)
+		return ExitFailure
+	}
+}
+
+func printUsage(out io.Writer) {
+	fmt.Fprintf(out, "Usage: %s [--config <file>] [--profile <name>] [command] [flags]\n\n", meta.AppName)
+	fmt.Fprintf(out, "Without a command, the terminal UI is started.\n\nCommands:\n")
+	for _, cmd := range commands() {
+		fmt.Fprintf(out, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(out, "  %-10s %s\n", "version", "Print the version")
+	fmt.Fprintf(out, "\nThe config is read from --config, $%s or the config directory, with a\n", appconfig.EnvConfig)
+	fmt.Fprintf(out, "project's .lazyworkflows.yml found in the working directory or its parents merged over it.\n")
+	fmt.Fprintf(out, "--profile or $%s picks one of the profiles of the config.\n", appconfig.EnvProfile)
+	fmt.Fprintf(out, "\nRun '%s <command> -h' for the flags of a command.\n", meta.AppName)
+}
+
+// Creates the flag set of a command. Parse errors are returned rather than exiting the process
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	for _, global := range globalFlags {
+		set := global.set
+		flags.Func(global.name, global.usage, func(value string) error {
+			set(value)
+			return nil
+		})
+	}
+	flags.Usage = func() {
+		for _, cmd := range commands() {
+			if cmd.name == name {
+				fmt.Fprintf(stderr, "Usage: %s %s %s\n\n%s\n", meta.AppName, cmd.name, cmd.args, cmd.summary)
+			}
+		}
+		if hasFlags(flags) {
+			fmt.Fprintf(stderr, "\nFlags:\n")
+			flags.PrintDefaults()
+		}
+	}
+	return flags
+}
+
+func hasFlags(flags *flag.FlagSet) bool {
+	found := false
+	flags.VisitAll(func(*flag.Flag) { found = true })
+	return found
+}
+
+// Parses the flags of a command, which may be mixed with its positional arguments,
+// and checks that the expected number of positional arguments was given
+func parseFlags(flags *flag.FlagSet, args []string, positional int) ([]string, error) {
+	rest := []string{}
+	for {
+		if err := flags.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, usageError{err.Error()}
+		}
+
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+		rest = append(rest, args[0])
+		args = args[1:]
+	}
+
+	if len(rest) != positional {
+		return nil, usageError{fmt.Sprintf("%s expects %d argument(s), got %d. See '%s %s -h'", flags.Name(), positional, len(rest), meta.AppName, flags.Name())}
+	}
+	return rest, nil
+}
+
+func defaultLoadConfig() (appconfig.AppConfig, error) {
+	conf := appconfig.New()
+	err := conf.Load()
+	if errors.Is(err, appconfig.ErrNoConfig) {
+		return appconfig.AppConfig{}, fmt.Errorf("%w. Run '%s' without a command to set one up", err, meta.AppName)
+	}
+	if err != nil {
+		return appconfig.AppConfig{}, err
+	}
+	return *conf, nil
+}
+
+// Picks the configured repo named by --repo. Without it, the repo of the git checkout the command
+// runs in is used, or else the only configured repo
+func selectRepo(conf appconfig.AppConfig, name string) (appconfig.Repo, error) {
+	if name == "" {
+		for _, repo := range conf.Repos {
+			if repo.Checkout != "" {
+				return repo, nil
+			}
+		}
+		if len(conf.Repos) == 1 && !conf.Repos[0].IsPattern() {
+			return conf.Repos[0], nil
+		}
+		return appconfig.Repo{}, usageError{"--repo owner/name is required when more than one repo is configured"}
+	}
+
+	owner, repo, found := strings.Cut(name, "/")
+	if !found || owner == "" || repo == "" || strings.Contains(repo, "/") {
+		return appconfig.Repo{}, usageError{fmt.Sprintf("--repo must look like owner/name, got %q", name)}
+	}
+
+	named, patterns := discovery.Split(conf.Repos)
+	for _, configured := range named {
+		if strings.EqualFold(configured.Owner, owner) && strings.EqualFold(configured.Repo, repo) {
+			return configured, nil
+		}
+	}
+	// Naming the repo is enough to pick it from a pattern, without listing every repo of the owner
+	for _, pattern := range patterns {
+		if strings.EqualFold(pattern.Owner, owner) && pattern.MatchesName(repo) {
+			return pattern.ForName(repo), nil
+		}
+	}
+	return appconfig.Repo{}, fmt.Errorf("repo %s is not in the config", name)
+}
+
+// A flag that may be given more than once, e.g. --input
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
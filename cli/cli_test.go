@@ -0,0 +1,287 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andreaswachs/lazyworkflows/appconfig"
+	"github.com/andreaswachs/lazyworkflows/consumer"
+	"github.com/andreaswachs/lazyworkflows/model/request"
+	"github.com/andreaswachs/lazyworkflows/model/response"
+	"github.com/andreaswachs/lazyworkflows/watch"
+)
+
+// A consumer that serves canned responses. Calls that aren't overridden panic through the nil interface
+type fakeConsumer struct {
+	consumer.Consumer
+	workflows  map[string][]response.Workflow
+	dispatched []request.Dispatch
+	// The runs returned by consecutive GetRun calls. The last one is repeated
+	runs []response.Run
+	// The runs of the workflow, which the dispatched run joins once dispatched
+	listed     []response.Run
+	dispatchAs response.Run
+	// The workflow file the inputs of a dispatch are read from
+	content string
+}
+
+func (f *fakeConsumer) CurrentUser(ctx context.Context, repo appconfig.Repo) (response.User, error) {
+	return response.User{Login: "octocat"}, nil
+}
+
+func (f *fakeConsumer) ListRuns(ctx context.Context, repo appconfig.Repo, id string, filter request.RunFilter) ([]response.Run, error) {
+	if len(f.dispatched) > 0 {
+		return append([]response.Run{f.dispatchAs}, f.listed...), nil
+	}
+	return f.listed, nil
+}
+
+func (f *fakeConsumer) List(ctx context.Context, repo appconfig.Repo) ([]response.Workflow, error) {
+	workflows, ok := f.workflows[repo.Repo]
+	if !ok {
+		return nil, fmt.Errorf("repo %s not found", repo.Repo)
+	}
+	return workflows, nil
+}
+
+func (f *fakeConsumer) Get(ctx context.Context, repo appconfig.Repo, id string) (response.Workflow, error) {
+	return response.Workflow{Name: "CI", Path: ".github/workflows/" + id}, nil
+}
+
+func (f *fakeConsumer) GetContent(ctx context.Context, repo appconfig.Repo, path string, ref string) (string, error) {
+	return f.content, nil
+}
+
+func (f *fakeConsumer) Dispatch(ctx context.Context, repo appconfig.Repo, id string, dispatchRequest request.Dispatch) (response.Dispatch, error) {
+	f.dispatched = append(f.dispatched, dispatchRequest)
+	return response.Dispatch{Status: 204}, nil
+}
+
+func (f *fakeConsumer) GetRun(ctx context.Context, repo appconfig.Repo, runId string) (response.Run, error) {
+	run := f.runs[0]
+	if len(f.runs) > 1 {
+		f.runs = f.runs[1:]
+	}
+	return run, nil
+}
+
+// A workflow file declaring an input of every type
+const dispatchableWorkflow = `on:
+  workflow_dispatch:
+    inputs:
+      env:
+        type: environment
+      dry_run:
+        type: boolean
+      tags:
+        type: string
+      replicas:
+        type: number
+`
+
+// Points the CLI at a fake consumer and captures its output
+func setupCli(t *testing.T, api *fakeConsumer) (*bytes.Buffer, *bytes.Buffer) {
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	previousStdout, previousStderr := stdout, stderr
+	previousLoadConfig, previousNewConsumer, previousConfigPaths := loadConfig, newConsumer, configPaths
+
+	stdout, stderr = out, errOut
+	loadConfig = func() (appconfig.AppConfig, error) {
+		return appconfig.AppConfig{Repos: []appconfig.Repo{
+			{Owner: "octo", Repo: "present", Token: "secret"},
+			{Owner: "octo", Repo: "missing", Token: "secret"},
+		}}, nil
+	}
+	newConsumer = func(appconfig.AppConfig) consumer.Consumer { return api }
+	watch.InjectSleep(func(context.Context, time.Duration) error { return nil })
+
+	t.Cleanup(func() {
+		stdout, stderr = previousStdout, previousStderr
+		loadConfig, newConsumer, configPaths = previousLoadConfig, previousNewConsumer, previousConfigPaths
+		appconfig.SetPath("")
+		appconfig.SetProfile("")
+		watch.InjectSleep(nil)
+	})
+
+	return out, errOut
+}
+
+func TestListPrintsWorkflowsAndFailsForBrokenRepos(t *testing.T) {
+	out, errOut := setupCli(t, &fakeConsumer{workflows: map[string][]response.Workflow{
+		"present": {{Id: "161335", Name: "CI", State: "active", Path: ".github/workflows/ci.yml"}},
+	}})
+
+	code := Run([]string{"list"})
+
+	if code != ExitFailure {
+		t.Errorf("Expected exit code %v, but got %v", ExitFailure, code)
+	}
+	if !strings.Contains(out.String(), "octo/present  161335  CI    active") {
+		t.Errorf("Expected the workflow to be listed, but got %v", out.String())
+	}
+	if !strings.Contains(errOut.String(), "octo/missing: repo missing not found") {
+		t.Errorf("Expected the failing repo to be reported, but got %v", errOut.String())
+	}
+}
+
+func TestDispatchSendsRefAndInputs(t *testing.T) {
+	api := &fakeConsumer{content: dispatchableWorkflow}
+	setupCli(t, api)
+
+	code := Run([]string{"dispatch", "ci.yml", "--repo", "octo/present", "--ref", "main",
+		"--input", "env=prod", "--input", "dry_run=true", "--input", "tags=a,b", "--input", "replicas=3"})
+
+	if code != ExitOk {
+		t.Fatalf("Expected exit code %v, but got %v", ExitOk, code)
+	}
+	expected := map[string]interface{}{"env": "prod", "dry_run": true, "tags": "a,b", "replicas": 3.0}
+	if len(api.dispatched) != 1 || api.dispatched[0].Ref != "main" || !reflect.DeepEqual(api.dispatched[0].Inputs, expected) {
+		t.Errorf("Expected a dispatch on main with inputs, but got %+v", api.dispatched)
+	}
+}
+
+func TestDispatchRejectsInputsOfTheWrongType(t *testing.T) {
+	api := &fakeConsumer{content: dispatchableWorkflow}
+	_, errOut := setupCli(t, api)
+
+	code := Run([]string{"dispatch", "ci.yml", "--repo", "octo/present", "--ref", "main", "--input", "replicas=many"})
+
+	if code != ExitUsage {
+		t.Errorf("Expected exit code %v, but got %v", ExitUsage, code)
+	}
+	if len(api.dispatched) != 0 || !strings.Contains(errOut.String(), `input "replicas" must be a number`) {
+		t.Errorf("Expected the input to be rejected before dispatching, but got %v", errOut.String())
+	}
+}
+
+func TestDispatchDefaultsToTheCheckout(t *testing.T) {
+	api := &fakeConsumer{}
+	out, _ := setupCli(t, api)
+	loadConfig = func() (appconfig.AppConfig, error) {
+		return appconfig.AppConfig{Repos: []appconfig.Repo{
+			{Owner: "octo", Repo: "present", Token: "secret"},
+			{Owner: "octo", Repo: "checkout", Token: "secret", Checkout: "/src/checkout", Branch: "feature/login"},
+		}}, nil
+	}
+
+	code := Run([]string{"dispatch", "ci.yml"})
+
+	if code != ExitOk {
+		t.Fatalf("Expected exit code %v, but got %v", ExitOk, code)
+	}
+	if len(api.dispatched) != 1 || api.dispatched[0].Ref != "feature/login" {
+		t.Errorf("Expected a dispatch on the branch of the checkout, but got %+v", api.dispatched)
+	}
+	if !strings.Contains(out.String(), "on feature/login") {
+		t.Errorf("Expected the branch to be reported, but got %v", out.String())
+	}
+}
+
+func TestUsageErrorsExitWithTwo(t *testing.T) {
+	cases := [][]string{
+		{"dispatch", "--repo", "octo/present", "ci.yml"},
+		{"dispatch", "--repo", "octo/present", "--ref", "main"},
+		{"get", "ci.yml"},
+		{"get", "--repo", "not-a-repo", "ci.yml"},
+		{"list", "--unknown"},
+		{"unknown"},
+	}
+
+	for _, args := range cases {
+		setupCli(t, &fakeConsumer{})
+		if code := Run(args); code != ExitUsage {
+			t.Errorf("Expected %v to exit with %v, but got %v", args, ExitUsage, code)
+		}
+	}
+}
+
+func TestWatchExitsWithTheConclusion(t *testing.T) {
+	out, _ := setupCli(t, &fakeConsumer{runs: []response.Run{
+		{RunNumber: 7, Status: "queued"},
+		{RunNumber: 7, Status: "in_progress"},
+		{RunNumber: 7, Status: "in_progress"},
+		{RunNumber: 7, Status: "completed", Conclusion: "failure"},
+	}})
+
+	code := Run([]string{"watch", "--repo", "octo/present", "30433642"})
+
+	if code != ExitFailure {
+		t.Errorf("Expected exit code %v, but got %v", ExitFailure, code)
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != 3 {
+		t.Errorf("Expected a line per status change, but got %v", out.String())
+	}
+}
+
+func TestDispatchWatchFollowsTheCreatedRun(t *testing.T) {
+	dispatched := response.Run{Id: "30433643", RunNumber: 8, Event: "workflow_dispatch", Actor: response.Actor{Login: "octocat"}, Status: "queued"}
+	api := &fakeConsumer{
+		listed:     []response.Run{{Id: "30433642", RunNumber: 7, Event: "workflow_dispatch", Actor: response.Actor{Login: "octocat"}}},
+		dispatchAs: dispatched,
+		runs: []response.Run{
+			dispatched,
+			{Id: "30433643", RunNumber: 8, Status: "completed", Conclusion: "timed_out"},
+		},
+	}
+	out, _ := setupCli(t, api)
+
+	code := Run([]string{"dispatch", "ci.yml", "--repo", "octo/present", "--ref", "main", "--watch"})
+
+	if code != watch.ExitTimedOut {
+		t.Errorf("Expected exit code %v, but got %v", watch.ExitTimedOut, code)
+	}
+	if !strings.Contains(out.String(), "as run #8") || !strings.Contains(out.String(), "run #8 completed (timed_out)") {
+		t.Errorf("Expected the dispatched run to be followed, but got %v", out.String())
+	}
+}
+
+func TestListFormatsIncludeTheRepo(t *testing.T) {
+	out, _ := setupCli(t, &fakeConsumer{workflows: map[string][]response.Workflow{
+		"present": {{Id: "161335", Name: "CI", State: "active"}},
+	}})
+
+	Run([]string{"list", "--repo", "octo/present", "--format", "{{.Repository}} {{.Name}}"})
+	if out.String() != "octo/present CI\n" {
+		t.Errorf("Expected the template to see the repo, but got %v", out.String())
+	}
+
+	out.Reset()
+	Run([]string{"list", "--repo", "octo/present", "--format", "ndjson"})
+	if !strings.HasPrefix(out.String(), `{"repository":"octo/present","id":161335,`) {
+		t.Errorf("Expected the repo as the first key, but got %v", out.String())
+	}
+}
+
+func TestUnknownFormatIsAUsageError(t *testing.T) {
+	setupCli(t, &fakeConsumer{})
+
+	if code := Run([]string{"runs", "--repo", "octo/present", "--format", "xml"}); code != ExitUsage {
+		t.Errorf("Expected exit code %v, but got %v", ExitUsage, code)
+	}
+}
+
+func TestConfigValidateReportsProblemsWithoutSecrets(t *testing.T) {
+	out, _ := setupCli(t, &fakeConsumer{})
+	path := filepath.Join(t.TempDir(), "config.yml")
+	os.WriteFile(path, []byte("repos:\n  - owner: octo\n    repo: octo/present\n    token: ghp_secretvalue\n"), 0600)
+	configPaths = func() []string { return []string{path} }
+
+	code := Run([]string{"config", "validate"})
+
+	if code != ExitFailure {
+		t.Errorf("Expected exit code %v, but got %v", ExitFailure, code)
+	}
+	if !strings.Contains(out.String(), path+":3:11: repo must be the name of the repo without its owner") {
+		t.Errorf("Expected the problem with its position, but got %v", out.String())
+	}
+	if strings.Contains(out.String(), "ghp_secretvalue") {
+		t.Errorf("Expected the token to be left out, but got %v", out.String())
+	}
+}
@@ -0,0 +1,397 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andreaswachs/lazyworkflows/appconfig"
+	"github.com/andreaswachs/lazyworkflows/consumer"
+	"github.com/andreaswachs/lazyworkflows/discovery"
+	"github.com/andreaswachs/lazyworkflows/model/definition"
+	"github.com/andreaswachs/lazyworkflows/model/request"
+	"github.com/andreaswachs/lazyworkflows/model/response"
+	"github.com/andreaswachs/lazyworkflows/output"
+	"github.com/andreaswachs/lazyworkflows/watch"
+)
+
+func runList(ctx context.Context, args []string) error {
+	flags := newFlagSet("list")
+	repoName := flags.String("repo", "", "only list the workflows of this repo, as owner/name")
+	format := formatFlag(flags)
+	if _, err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	writer, err := output.New(*format, repoWorkflowColumns)
+	if err != nil {
+		return usageError{err.Error()}
+	}
+
+	conf, api, err := setup()
+	if err != nil {
+		return err
+	}
+
+	listed := []repoWorkflow{}
+	failed := 0
+
+	var repos []appconfig.Repo
+	if *repoName != "" {
+		repo, err := selectRepo(conf, *repoName)
+		if err != nil {
+			return err
+		}
+		repos = []appconfig.Repo{repo}
+	} else {
+		repos, err = discovery.Resolve(ctx, api, conf.Repos)
+		if err != nil {
+			// The repos that were discovered are still listed
+			fmt.Fprintf(stderr, "%v\n", err)
+			failed++
+		}
+	}
+	for _, repo := range repos {
+		workflows, err := api.List(ctx, repo)
+		if err != nil {
+			// One broken repo shouldn't hide the workflows of the others
+			fmt.Fprintf(stderr, "%s/%s: %v\n", repo.Owner, repo.Repo, err)
+			failed++
+			continue
+		}
+
+		for _, workflow := range workflows {
+			listed = append(listed, repoWorkflow{Repository: repo.Owner + "/" + repo.Repo, Workflow: workflow})
+		}
+	}
+
+	if err := writer.Write(stdout, listed); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return exitError{ExitFailure}
+	}
+	return nil
+}
+
+func runGet(ctx context.Context, args []string) error {
+	flags := newFlagSet("get")
+	repoName := flags.String("repo", "", "the repo of the workflow, as owner/name")
+	format := formatFlag(flags)
+	rest, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	writer, err := output.New(*format, workflowColumns)
+	if err != nil {
+		return usageError{err.Error()}
+	}
+
+	repo, api, err := setupRepo(*repoName)
+	if err != nil {
+		return err
+	}
+
+	workflow, err := api.Get(ctx, repo, rest[0])
+	if err != nil {
+		return err
+	}
+
+	return writer.WriteOne(stdout, workflow)
+}
+
+func runDispatch(ctx context.Context, args []string) error {
+	flags := newFlagSet("dispatch")
+	repoName := flags.String("repo", "", "the repo of the workflow, as owner/name")
+	ref := flags.String("ref", "", "the branch or tag to run the workflow on. Defaults to the branch checked out in the repo's git checkout")
+	inputs := stringList{}
+	flags.Var(&inputs, "input", "a workflow input as key=value. May be given more than once")
+	wait := flags.Bool("watch", false, "wait for the run to complete and exit with its conclusion")
+	options := watchFlags(flags)
+	rest, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	parsedInputs, err := request.ParseInputs(inputs)
+	if err != nil {
+		return usageError{err.Error()}
+	}
+
+	repo, api, err := setupRepo(*repoName)
+	if err != nil {
+		return err
+	}
+
+	if *ref == "" {
+		*ref = repo.Branch
+	}
+	if *ref == "" {
+		return usageError{"--ref is required to dispatch a workflow outside of a git checkout of its repo"}
+	}
+
+	typedInputs, err := typeInputs(ctx, api, repo, rest[0], *ref, parsedInputs)
+	if err != nil {
+		return err
+	}
+
+	dispatch := request.Dispatch{Ref: *ref, Inputs: typedInputs}
+	if !*wait {
+		if _, err := api.Dispatch(ctx, repo, rest[0], dispatch); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Dispatched %s on %s\n", rest[0], *ref)
+		return nil
+	}
+
+	run, err := watch.Dispatch(ctx, api, repo, rest[0], dispatch, *options)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Dispatched %s on %s as run #%d %s\n", rest[0], *ref, run.RunNumber, run.HtmlUrl)
+	return watchRun(ctx, api, repo, run.Id.String(), *options)
+}
+
+// Types the values of the inputs by what the workflow file declares at the ref it is dispatched on
+func typeInputs(ctx context.Context, api consumer.Consumer, repo appconfig.Repo, id string, ref string, values map[string]string) (map[string]interface{}, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	workflow, err := api.Get(ctx, repo, id)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := api.GetContent(ctx, repo, workflow.Path, ref)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the inputs of %s on %s: %w", id, ref, err)
+	}
+
+	parsed, err := definition.Parse(content)
+	if err != nil {
+		return nil, err
+	}
+
+	typed, err := parsed.On.Inputs.Type(values)
+	if err != nil {
+		return nil, usageError{err.Error()}
+	}
+	return typed, nil
+}
+
+func runEnable(ctx context.Context, args []string) error {
+	return toggle(ctx, "enable", "Enabled", args, func(api consumer.Consumer, repo appconfig.Repo, id string) error {
+		_, err := api.Enable(ctx, repo, id)
+		return err
+	})
+}
+
+func runDisable(ctx context.Context, args []string) error {
+	return toggle(ctx, "disable", "Disabled", args, func(api consumer.Consumer, repo appconfig.Repo, id string) error {
+		_, err := api.Disable(ctx, repo, id)
+		return err
+	})
+}
+
+// Enables or disables the workflow named in the arguments
+func toggle(ctx context.Context, name string, done string, args []string, mutate func(consumer.Consumer, appconfig.Repo, string) error) error {
+	flags := newFlagSet(name)
+	repoName := flags.String("repo", "", "the repo of the workflow, as owner/name")
+	rest, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	repo, api, err := setupRepo(*repoName)
+	if err != nil {
+		return err
+	}
+
+	if err := mutate(api, repo, rest[0]); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "%s %s\n", done, rest[0])
+	return nil
+}
+
+func runRuns(ctx context.Context, args []string) error {
+	flags := newFlagSet("runs")
+	repoName := flags.String("repo", "", "the repo to list runs of, as owner/name")
+	workflow := flags.String("workflow", "", "only list the runs of this workflow, by id or file name")
+	filter := request.RunFilter{}
+	flags.StringVar(&filter.Branch, "branch", "", "only list runs on this branch")
+	flags.StringVar(&filter.Event, "event", "", "only list runs triggered by this event, e.g. push")
+	flags.StringVar(&filter.Status, "status", "", "only list runs with this status or conclusion, e.g. failure")
+	flags.StringVar(&filter.Actor, "actor", "", "only list runs started by this user")
+	flags.IntVar(&filter.Limit, "limit", 20, "the maximum number of runs to list")
+	format := formatFlag(flags)
+	if _, err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	writer, err := output.New(*format, runColumns)
+	if err != nil {
+		return usageError{err.Error()}
+	}
+
+	repo, api, err := setupRepo(*repoName)
+	if err != nil {
+		return err
+	}
+
+	var runs []response.Run
+	if *workflow != "" {
+		runs, err = api.ListRuns(ctx, repo, *workflow, filter)
+	} else {
+		runs, err = api.ListRepoRuns(ctx, repo, filter)
+	}
+	if err != nil {
+		return err
+	}
+
+	return writer.Write(stdout, runs)
+}
+
+func runJobs(ctx context.Context, args []string) error {
+	flags := newFlagSet("jobs")
+	repoName := flags.String("repo", "", "the repo of the run, as owner/name")
+	attempt := flags.Int("attempt", 0, "list the jobs of this attempt instead of the latest one")
+	format := formatFlag(flags)
+	rest, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	writer, err := output.New(*format, jobColumns)
+	if err != nil {
+		return usageError{err.Error()}
+	}
+
+	repo, api, err := setupRepo(*repoName)
+	if err != nil {
+		return err
+	}
+
+	var jobs []response.Job
+	if *attempt > 0 {
+		jobs, err = api.ListAttemptJobs(ctx, repo, rest[0], *attempt)
+	} else {
+		jobs, err = api.ListJobs(ctx, repo, rest[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	return writer.Write(stdout, jobs)
+}
+
+func runWatch(ctx context.Context, args []string) error {
+	flags := newFlagSet("watch")
+	repoName := flags.String("repo", "", "the repo of the run, as owner/name")
+	options := watchFlags(flags)
+	rest, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	repo, api, err := setupRepo(*repoName)
+	if err != nil {
+		return err
+	}
+
+	return watchRun(ctx, api, repo, rest[0], *options)
+}
+
+// Adds the flags that tune how often a run is polled
+func watchFlags(flags *flag.FlagSet) *watch.Options {
+	options := &watch.Options{}
+	flags.DurationVar(&options.Interval, "interval", watch.DefaultInterval, "how often to check the run at first")
+	flags.DurationVar(&options.MaxInterval, "max-interval", watch.DefaultMaxInterval, "how often to check the run at most, once it stops changing")
+	return options
+}
+
+// Prints a line per status change of the run until it completes, and exits with its conclusion
+func watchRun(ctx context.Context, api consumer.Consumer, repo appconfig.Repo, runId string, options watch.Options) error {
+	run, err := watch.Until(ctx, api, repo, runId, options, func(run response.Run) {
+		state := run.Status
+		if run.Conclusion != "" {
+			state += " (" + run.Conclusion + ")"
+		}
+		fmt.Fprintf(stdout, "%s run #%d %s\n", time.Now().Format("15:04:05"), run.RunNumber, state)
+	})
+	if err != nil {
+		return err
+	}
+
+	if code := watch.ExitCode(run); code != ExitOk {
+		return exitError{code}
+	}
+	return nil
+}
+
+func runConfig(ctx context.Context, args []string) error {
+	flags := newFlagSet("config")
+	rest, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	if rest[0] != "validate" {
+		return usageError{fmt.Sprintf("unknown config command %q, expected validate", rest[0])}
+	}
+
+	paths := configPaths()
+	if len(paths) == 0 {
+		return fmt.Errorf("%w at %s", appconfig.ErrNoConfig, appconfig.Path())
+	}
+
+	invalid, err := appconfig.ValidateFiles(paths...)
+	if err != nil {
+		return err
+	}
+
+	if len(invalid) > 0 {
+		problems := 0
+		for _, validationErr := range invalid {
+			fmt.Fprintln(stdout, validationErr.Error())
+			problems += len(validationErr.Problems)
+		}
+		fmt.Fprintf(stderr, "%d problem(s) found in %s\n", problems, strings.Join(paths, ", "))
+		return exitError{ExitFailure}
+	}
+
+	for _, path := range paths {
+		fmt.Fprintf(stdout, "%s is valid\n", path)
+	}
+	return nil
+}
+
+// Loads the config and creates a consumer for it
+func setup() (appconfig.AppConfig, consumer.Consumer, error) {
+	conf, err := loadConfig()
+	if err != nil {
+		return appconfig.AppConfig{}, nil, err
+	}
+	return conf, newConsumer(conf), nil
+}
+
+// Loads the config and picks the repo named by --repo from it
+func setupRepo(name string) (appconfig.Repo, consumer.Consumer, error) {
+	conf, api, err := setup()
+	if err != nil {
+		return appconfig.Repo{}, nil, err
+	}
+
+	repo, err := selectRepo(conf, name)
+	if err != nil {
+		return appconfig.Repo{}, nil, err
+	}
+	return repo, api, nil
+}
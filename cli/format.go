@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/andreaswachs/lazyworkflows/model/response"
+	"github.com/andreaswachs/lazyworkflows/output"
+)
+
+// A workflow together with the repo it belongs to, as listed across repos
+type repoWorkflow struct {
+	Repository string `json:"repository"`
+	response.Workflow
+}
+
+var workflowColumns = []output.Column[response.Workflow]{
+	{Header: "ID", Value: func(w response.Workflow) string { return w.Id.String() }},
+	{Header: "NAME", Value: func(w response.Workflow) string { return w.Name }},
+	{Header: "STATE", Value: func(w response.Workflow) string { return w.State }},
+	{Header: "PATH", Value: func(w response.Workflow) string { return w.Path }},
+}
+
+var repoWorkflowColumns = append([]output.Column[repoWorkflow]{
+	{Header: "REPO", Value: func(w repoWorkflow) string { return w.Repository }},
+}, forWorkflows(workflowColumns)...)
+
+var runColumns = []output.Column[response.Run]{
+	{Header: "ID", Value: func(r response.Run) string { return r.Id.String() }},
+	{Header: "NUMBER", Value: func(r response.Run) string { return fmt.Sprint(r.RunNumber) }},
+	{Header: "WORKFLOW", Value: func(r response.Run) string { return r.Name }},
+	{Header: "BRANCH", Value: func(r response.Run) string { return r.HeadBranch }},
+	{Header: "EVENT", Value: func(r response.Run) string { return r.Event }},
+	{Header: "STATUS", Value: func(r response.Run) string { return r.Status }},
+	{Header: "CONCLUSION", Value: func(r response.Run) string { return r.Conclusion }},
+	{Header: "CREATED", Value: func(r response.Run) string { return r.CreatedAt }},
+}
+
+var jobColumns = []output.Column[response.Job]{
+	{Header: "ID", Value: func(j response.Job) string { return j.Id.String() }},
+	{Header: "NAME", Value: func(j response.Job) string { return j.Name }},
+	{Header: "ATTEMPT", Value: func(j response.Job) string { return fmt.Sprint(j.RunAttempt) }},
+	{Header: "STATUS", Value: func(j response.Job) string { return j.Status }},
+	{Header: "CONCLUSION", Value: func(j response.Job) string { return j.Conclusion }},
+	{Header: "STARTED", Value: func(j response.Job) string { return j.StartedAt }},
+	{Header: "COMPLETED", Value: func(j response.Job) string { return j.CompletedAt }},
+	{Header: "STEPS", Value: func(j response.Job) string { return fmt.Sprint(len(j.Steps)) }},
+}
+
+// Adds the --format flag shared by every command that lists something
+func formatFlag(flags *flag.FlagSet) *string {
+	return flags.String("format", output.Table, fmt.Sprintf("one of %s, or a Go template like '{{.Name}}'", strings.Join(output.Formats, ", ")))
+}
+
+// Lets the workflow columns be used for workflows listed across repos
+func forWorkflows(columns []output.Column[response.Workflow]) []output.Column[repoWorkflow] {
+	converted := make([]output.Column[repoWorkflow], len(columns))
+	for i, column := range columns {
+		value := column.Value
+		converted[i] = output.Column[repoWorkflow]{Header: column.Header, Value: func(w repoWorkflow) string { return value(w.Workflow) }}
+	}
+	return converted
+}
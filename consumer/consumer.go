@@ -1,21 +1,66 @@
 package consumer
 
 import (
+	"context"
+
 	"github.com/andreaswachs/lazyworkflows/appconfig"
 	"github.com/andreaswachs/lazyworkflows/consumer/webapi"
+	"github.com/andreaswachs/lazyworkflows/model/request"
 	"github.com/andreaswachs/lazyworkflows/model/response"
 )
 
+// Consumer talks to the GitHub API. Every call that reaches the network takes
+// a context first, such that it can be cancelled or time out
 type Consumer interface {
-	List(appconfig.Repo) ([]response.Workflow, error)
-	Get(appconfig.Repo, string) (response.Workflow, error)
-	Dispatch(appconfig.Repo, string) (response.Dispatch, error)
-	Enable(appconfig.Repo, string) (response.Enable, error)
-	Disable(appconfig.Repo, string) (response.Disable, error)
+	List(context.Context, appconfig.Repo) ([]response.Workflow, error)
+	ListPages(context.Context, appconfig.Repo, func([]response.Workflow)) error
+	Get(context.Context, appconfig.Repo, string) (response.Workflow, error)
+	Dispatch(context.Context, appconfig.Repo, string, request.Dispatch) (response.Dispatch, error)
+	Enable(context.Context, appconfig.Repo, string) (response.Enable, error)
+	Disable(context.Context, appconfig.Repo, string) (response.Disable, error)
+	ListRuns(context.Context, appconfig.Repo, string, request.RunFilter) ([]response.Run, error)
+	ListRepoRuns(context.Context, appconfig.Repo, request.RunFilter) ([]response.Run, error)
+	GetRun(context.Context, appconfig.Repo, string) (response.Run, error)
+	RateLimit(appconfig.Repo) (response.RateLimit, bool)
+	ListJobs(context.Context, appconfig.Repo, string) ([]response.Job, error)
+	ListAttemptJobs(context.Context, appconfig.Repo, string, int) ([]response.Job, error)
+	GetContent(context.Context, appconfig.Repo, string, string) (string, error)
+	CancelRun(context.Context, appconfig.Repo, string) (response.Cancel, error)
+	ForceCancelRun(context.Context, appconfig.Repo, string) (response.Cancel, error)
+	RerunRun(context.Context, appconfig.Repo, string, request.Rerun) (response.Rerun, error)
+	RerunFailedJobs(context.Context, appconfig.Repo, string, request.Rerun) (response.Rerun, error)
+	RerunJob(context.Context, appconfig.Repo, string, request.Rerun) (response.Rerun, error)
+	CurrentUser(context.Context, appconfig.Repo) (response.User, error)
+	ListRepos(context.Context, appconfig.Repo) ([]response.Repository, error)
+	GetRepo(context.Context, appconfig.Repo) (response.Repository, error)
+	ActionsEnabled(context.Context, appconfig.Repo) (bool, error)
+	JobLogs(context.Context, appconfig.Repo, string) (string, error)
+	RunLogs(context.Context, appconfig.Repo, string) ([]response.LogFile, error)
 }
 
 // Returns a new API consumer
-// The concrete consumer can be configured in this functiok
-func New() Consumer {
-	return &webapi.WebApi{}
+// The concrete consumer can be configured in this function
+func New(conf appconfig.AppConfig) Consumer {
+	return &webapi.WebApi{
+		PerPage: conf.PerPage,
+		Cache:   newCache(conf.Cache),
+		Timeout: conf.Timeout,
+	}
+}
+
+// Picks the response cache from the configured kind. Memory is the default
+func newCache(kind string) webapi.Cache {
+	switch kind {
+	case appconfig.CacheNone:
+		return nil
+	case appconfig.CacheDisk:
+		cache, err := webapi.NewDiskCache(webapi.DiskCacheDir())
+		if err == nil {
+			return cache
+		}
+		// Falling back to memory still saves us requests while the program runs
+		return webapi.NewMemoryCache()
+	default:
+		return webapi.NewMemoryCache()
+	}
 }
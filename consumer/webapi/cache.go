@@ -0,0 +1,104 @@
+package webapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/adrg/xdg"
+	"github.com/andreaswachs/lazyworkflows/meta"
+)
+
+// CacheEntry is a previously seen response, along with the validators needed to revalidate it
+type CacheEntry struct {
+	ETag         string
+	LastModified string
+	Link         string
+	Body         string
+}
+
+// Cache stores responses per request such that they can be revalidated with conditional requests.
+// A 304 Not Modified reply doesn't count against the rate limit
+type Cache interface {
+	Get(key string) (CacheEntry, bool)
+	Set(key string, entry CacheEntry)
+}
+
+type memoryCache struct {
+	lock    sync.RWMutex
+	entries map[string]CacheEntry
+}
+
+type diskCache struct {
+	dir string
+}
+
+// NewMemoryCache returns a cache that lives as long as the program does
+func NewMemoryCache() Cache {
+	return &memoryCache{entries: make(map[string]CacheEntry)}
+}
+
+// NewDiskCache returns a cache that stores its entries as files in the given directory
+func NewDiskCache(dir string) (Cache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &diskCache{dir: dir}, nil
+}
+
+// DiskCacheDir is where the on-disk cache is stored by default
+func DiskCacheDir() string {
+	return filepath.Join(xdg.CacheHome, meta.AppName, "http")
+}
+
+func (c *memoryCache) Get(key string) (CacheEntry, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	entry, ok := c.entries[key]
+	return entry, ok
+}
+
+func (c *memoryCache) Set(key string, entry CacheEntry) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.entries[key] = entry
+}
+
+func (c *diskCache) Get(key string) (CacheEntry, bool) {
+	contents, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return CacheEntry{}, false
+	}
+
+	entry := CacheEntry{}
+	if err := json.Unmarshal(contents, &entry); err != nil {
+		return CacheEntry{}, false
+	}
+
+	return entry, true
+}
+
+func (c *diskCache) Set(key string, entry CacheEntry) {
+	contents, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	// The cache is best effort, a failed write only means a full request next time
+	_ = os.WriteFile(c.path(key), contents, 0600)
+}
+
+func (c *diskCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// Responses depend on who is asking, so the token is part of the key
+func cacheKey(token string, url string) string {
+	sum := sha256.Sum256([]byte(token + " " + url))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,82 @@
+package webapi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/andreaswachs/lazyworkflows/appconfig"
+)
+
+const publicApiUrl = "https://api.github.com"
+
+var (
+	// Clients trusting a custom CA bundle, one per bundle file
+	caClients     = make(map[string]*http.Client)
+	caClientsLock sync.Mutex
+)
+
+// Resolves the configured API URL into the base that endpoint paths are appended to.
+// GitHub Enterprise Server serves its API below /api/v3, which is added when only the host is given
+func apiBaseUrl(apiUrl string) (string, error) {
+	if apiUrl == "" {
+		return publicApiUrl, nil
+	}
+
+	parsed, err := url.Parse(apiUrl)
+	if err != nil || parsed.Host == "" {
+		return "", fmt.Errorf("invalid API URL %q, expected e.g. https://github.example.com/api/v3", apiUrl)
+	}
+
+	if parsed.Host == "github.com" || parsed.Host == "api.github.com" {
+		return publicApiUrl, nil
+	}
+
+	path := strings.TrimSuffix(parsed.Path, "/")
+	if path == "" {
+		path = "/api/v3"
+	}
+
+	return fmt.Sprintf("%s://%s%s", parsed.Scheme, parsed.Host, path), nil
+}
+
+// Returns the client to talk to the API of the given repo with.
+// Self-hosted instances signed by a private CA get a client that trusts the configured bundle
+func httpClientFor(repo appconfig.Repo) (*http.Client, error) {
+	if repo.CaFile == "" {
+		return GetHttpClient(), nil
+	}
+
+	caClientsLock.Lock()
+	defer caClientsLock.Unlock()
+
+	if client, ok := caClients[repo.CaFile]; ok {
+		return client, nil
+	}
+
+	bundle, err := os.ReadFile(repo.CaFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read CA bundle for %s/%s: %w", repo.Owner, repo.Repo, err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("CA bundle %s for %s/%s contains no PEM certificates", repo.CaFile, repo.Owner, repo.Repo)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+
+	client := &http.Client{Transport: transport}
+	caClients[repo.CaFile] = client
+
+	return client, nil
+}
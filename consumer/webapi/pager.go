@@ -0,0 +1,112 @@
+package webapi
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+// The page size used when none is configured. This is the maximum GitHub allows
+const defaultPerPage = 100
+
+var linkNextPattern = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// Pager walks through the pages of a list endpoint by following the
+// Link: rel="next" headers. Every call to Next performs a single request,
+// which lets callers render the first page while the rest are loading
+type Pager[T any] struct {
+	target  action
+	request *webApiRequest
+	extract func(string) ([]T, error)
+	limit   int
+	fetched int
+	nextUrl string
+	started bool
+}
+
+func newPager[T any](target action, apiRequest *webApiRequest, perPage int, limit int, extract func(string) ([]T, error)) *Pager[T] {
+	if perPage <= 0 {
+		perPage = defaultPerPage
+	}
+	if limit > 0 && limit < perPage {
+		perPage = limit
+	}
+
+	query := apiRequest.Query
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("per_page", strconv.Itoa(perPage))
+
+	return &Pager[T]{
+		target:  target,
+		request: apiRequest.withQuery(query),
+		extract: extract,
+		limit:   limit,
+	}
+}
+
+// HasNext reports whether there are more pages to fetch
+func (p *Pager[T]) HasNext() bool {
+	if p.limit > 0 && p.fetched >= p.limit {
+		return false
+	}
+	return !p.started || p.nextUrl != ""
+}
+
+// Next fetches the next page. It returns an empty page once there are no more pages
+func (p *Pager[T]) Next(ctx context.Context) ([]T, error) {
+	if !p.HasNext() {
+		return []T{}, nil
+	}
+
+	apiRequest := p.request
+	if p.started {
+		apiRequest = apiRequest.withUrl(p.nextUrl)
+	}
+
+	apiResponse, err := doRequest(ctx, p.target, apiRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := p.extract(apiResponse.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	p.started = true
+	p.nextUrl = nextPageUrl(apiResponse.Header.Get("Link"))
+
+	if p.limit > 0 && p.fetched+len(items) > p.limit {
+		items = items[:p.limit-p.fetched]
+	}
+	p.fetched += len(items)
+
+	return items, nil
+}
+
+// All fetches the remaining pages and returns their items in order
+func (p *Pager[T]) All(ctx context.Context) ([]T, error) {
+	items := []T{}
+
+	for p.HasNext() {
+		page, err := p.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page...)
+	}
+
+	return items, nil
+}
+
+// Extracts the URL of the next page from a Link header, if any
+func nextPageUrl(linkHeader string) string {
+	match := linkNextPattern.FindStringSubmatch(linkHeader)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
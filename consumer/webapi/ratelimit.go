@@ -0,0 +1,139 @@
+package webapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andreaswachs/lazyworkflows/appconfig"
+	"github.com/andreaswachs/lazyworkflows/model/response"
+)
+
+const (
+	// How many times a request is retried after a secondary rate limit or server error
+	maxRetries = 3
+	// Waits longer than this are not worth blocking a refresh for, so we give up instead
+	maxRetryWait = time.Minute
+)
+
+var (
+	// The first delay of the exponential backoff. It doubles on every attempt
+	retryBaseDelay = time.Second
+
+	// Sleeping is a variable such that tests don't have to wait for real
+	sleep = sleepContext
+
+	// The last known rate limit per token. Tokens are hashed so they aren't kept around as map keys
+	rateLimits     = make(map[string]response.RateLimit)
+	rateLimitsLock sync.RWMutex
+)
+
+// RateLimit returns the last known rate limit budget of the token used for the given repo.
+// The boolean is false when no request has been made with the token yet
+func (w *WebApi) RateLimit(repo appconfig.Repo) (response.RateLimit, bool) {
+	rateLimitsLock.RLock()
+	defer rateLimitsLock.RUnlock()
+
+	rateLimit, ok := rateLimits[tokenKey(repo.Token)]
+	return rateLimit, ok
+}
+
+// Stores the rate limit reported by the X-RateLimit-* headers, if present
+func recordRateLimit(token string, header http.Header) {
+	limit, err := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return
+	}
+
+	rateLimit := response.RateLimit{
+		Limit:    limit,
+		Resource: header.Get("X-RateLimit-Resource"),
+	}
+	rateLimit.Remaining, _ = strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	rateLimit.Used, _ = strconv.Atoi(header.Get("X-RateLimit-Used"))
+	if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		rateLimit.Reset = time.Unix(reset, 0)
+	}
+
+	rateLimitsLock.Lock()
+	defer rateLimitsLock.Unlock()
+	rateLimits[tokenKey(token)] = rateLimit
+}
+
+// Fails fast when the token is known to have exhausted its budget
+func checkRateLimit(token string) error {
+	rateLimitsLock.RLock()
+	rateLimit, ok := rateLimits[tokenKey(token)]
+	rateLimitsLock.RUnlock()
+
+	if ok && rateLimit.Remaining == 0 && time.Now().Before(rateLimit.Reset) {
+		return &response.RateLimitError{RateLimit: rateLimit}
+	}
+
+	return nil
+}
+
+// Decides whether a failed response should be retried and how long to wait before doing so
+func retryDelay(method string, apiResponse webApiResponse, attempt int) (time.Duration, bool) {
+	if attempt >= maxRetries {
+		return 0, false
+	}
+
+	backoff := retryBaseDelay * time.Duration(1<<attempt)
+
+	var wait time.Duration
+	switch {
+	case apiResponse.StatusCode == http.StatusForbidden || apiResponse.StatusCode == http.StatusTooManyRequests:
+		if seconds, err := strconv.Atoi(apiResponse.Header.Get("Retry-After")); err == nil {
+			wait = time.Duration(seconds) * time.Second
+		} else if apiResponse.Header.Get("X-RateLimit-Remaining") == "0" {
+			reset, err := strconv.ParseInt(apiResponse.Header.Get("X-RateLimit-Reset"), 10, 64)
+			if err != nil {
+				return 0, false
+			}
+			wait = time.Until(time.Unix(reset, 0))
+		} else if strings.Contains(strings.ToLower(apiResponse.Body), "secondary rate limit") {
+			wait = backoff
+		} else {
+			// A plain 403 means we aren't allowed to do this, retrying won't help
+			return 0, false
+		}
+	case apiResponse.StatusCode >= 500 && apiResponse.StatusCode <= 599:
+		// A failed dispatch might still have gone through, so only idempotent requests are repeated
+		if method == "POST" {
+			return 0, false
+		}
+		wait = backoff
+	default:
+		return 0, false
+	}
+
+	if wait > maxRetryWait {
+		return 0, false
+	}
+
+	return wait, true
+}
+
+// Waits for the given duration, or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
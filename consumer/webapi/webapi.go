@@ -1,12 +1,20 @@
 package webapi
 
 import (
+	"archive/zip"
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/andreaswachs/lazyworkflows/appconfig"
+	"github.com/andreaswachs/lazyworkflows/model/request"
 	"github.com/andreaswachs/lazyworkflows/model/response"
 )
 
@@ -21,43 +29,95 @@ const (
 	dispatch
 	get
 	list
+	listRuns
+	listRepoRuns
+	getRun
+	listJobs
+	runLogs
+	jobLogs
+	listAttemptJobs
+	getContent
+	cancelRun
+	forceCancelRun
+	rerunRun
+	rerunFailedJobs
+	rerunJob
+	getUser
+	listOrgRepos
+	listUserRepos
+	listOwnRepos
+	getActionsPermissions
+	getRepo
 )
 
 // The data structure for the WebApi consumer.
 // This implements the Consumer interface
 type WebApi struct {
+	// The number of items requested per page from list endpoints. Zero uses the maximum
+	PerPage int
+	// Stores responses for conditional requests. Nil disables caching
+	Cache Cache
+	// How long a single HTTP request may take. Zero leaves it up to the caller's context
+	Timeout time.Duration
+}
+
+// The parts of an API response that the consumer cares about
+type webApiResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       string
 }
 
 type webApiRequest struct {
-	Repo appconfig.Repo
-	Id   string
+	Repo    appconfig.Repo
+	Id      string
+	Attempt int
+	Query   url.Values
+	Body    []byte
+	Url     string
+	Cache   Cache
+	Timeout time.Duration
 }
 
-// List returns a list of workflows for a given repo
-func (w *WebApi) List(repo appconfig.Repo) ([]response.Workflow, error) {
-	apiResponse, err := doRequest(list, repo, "")
-	if err != nil {
-		return nil, err
-	}
+// List returns all workflows for a given repo, following every page
+func (w *WebApi) List(ctx context.Context, repo appconfig.Repo) ([]response.Workflow, error) {
+	return w.WorkflowPages(repo).All(ctx)
+}
 
-	lstResponse := response.List{}
-	err = response.FromString(apiResponse, &lstResponse)
-	if err != nil {
-		return nil, err
+// ListPages fetches the workflows of a given repo page by page, handing every page to onPage as soon as it
+// arrives. Callers can show the first page while the rest are loading
+func (w *WebApi) ListPages(ctx context.Context, repo appconfig.Repo, onPage func([]response.Workflow)) error {
+	pager := w.WorkflowPages(repo)
+
+	for pager.HasNext() {
+		page, err := pager.Next(ctx)
+		if err != nil {
+			return err
+		}
+		onPage(page)
 	}
 
-	return lstResponse.Workflows, nil
+	return nil
+}
+
+// WorkflowPages returns a pager that lazily fetches the workflows of a given repo page by page
+func (w *WebApi) WorkflowPages(repo appconfig.Repo) *Pager[response.Workflow] {
+	return newPager(list, w.newRequest(repo), w.PerPage, 0, func(body string) ([]response.Workflow, error) {
+		lstResponse := response.List{}
+		err := response.FromString(body, &lstResponse)
+		return lstResponse.Workflows, err
+	})
 }
 
 // Get returns a single workflow for a given repo
-func (w *WebApi) Get(repo appconfig.Repo, id string) (response.Workflow, error) {
-	apiResponse, err := doRequest(get, repo, id)
+func (w *WebApi) Get(ctx context.Context, repo appconfig.Repo, id string) (response.Workflow, error) {
+	apiResponse, err := doRequest(ctx, get, w.newRequest(repo).withId(id))
 	if err != nil {
 		return response.Workflow{}, err
 	}
 
 	getResponse := response.Get{}
-	err = response.FromString(apiResponse, &getResponse)
+	err = response.FromString(apiResponse.Body, &getResponse)
 	if err != nil {
 		return response.Workflow{}, err
 	}
@@ -65,54 +125,392 @@ func (w *WebApi) Get(repo appconfig.Repo, id string) (response.Workflow, error)
 	return getResponse.Workflow, nil
 }
 
-// Dispatch triggers a workflow for a given repo
-func (w *WebApi) Dispatch(repo appconfig.Repo, id string) (response.Dispatch, error) {
-	dispatchResponse, err := doRequest(dispatch, repo, id)
+// Dispatch triggers a workflow for a given repo on the given ref, with the given inputs
+func (w *WebApi) Dispatch(ctx context.Context, repo appconfig.Repo, id string, dispatchRequest request.Dispatch) (response.Dispatch, error) {
+	if dispatchRequest.Ref == "" {
+		return response.Dispatch{}, fmt.Errorf("a ref is required to dispatch workflow %s", id)
+	}
+
+	body, err := json.Marshal(dispatchRequest)
 	if err != nil {
 		return response.Dispatch{}, err
 	}
 
-	dispatchResponseObj := response.Dispatch{}
-	err = response.FromString(dispatchResponse, &dispatchResponseObj)
+	dispatchResponse, err := doRequest(ctx, dispatch, w.newRequest(repo).withId(id).withBody(body))
 	if err != nil {
 		return response.Dispatch{}, err
 	}
 
+	// Successful calls reply with 204 No Content, so there is not always a body to parse
+	dispatchResponseObj := response.Dispatch{Status: dispatchResponse.StatusCode}
+	if dispatchResponse.hasBody() {
+		err = response.FromString(dispatchResponse.Body, &dispatchResponseObj)
+		if err != nil {
+			return response.Dispatch{}, err
+		}
+	}
+
 	return dispatchResponseObj, nil
 }
 
 // Enable enables a workflow for a given repo
-func (w *WebApi) Enable(repo appconfig.Repo, id string) (response.Enable, error) {
-	enableResponse, err := doRequest(enable, repo, id)
+func (w *WebApi) Enable(ctx context.Context, repo appconfig.Repo, id string) (response.Enable, error) {
+	enableResponse, err := doRequest(ctx, enable, w.newRequest(repo).withId(id))
 	if err != nil {
 		return response.Enable{}, err
 	}
 
-	enableResponseObj := response.Enable{}
-	err = response.FromString(enableResponse, &enableResponseObj)
-	if err != nil {
-		return response.Enable{}, err
+	// Successful calls reply with 204 No Content, so there is not always a body to parse
+	enableResponseObj := response.Enable{Status: enableResponse.StatusCode}
+	if enableResponse.hasBody() {
+		err = response.FromString(enableResponse.Body, &enableResponseObj)
+		if err != nil {
+			return response.Enable{}, err
+		}
 	}
 
 	return enableResponseObj, nil
 }
 
 // Disable disables a workflow for a given repo
-func (w *WebApi) Disable(repo appconfig.Repo, id string) (response.Disable, error) {
-	disableResponse, err := doRequest(disable, repo, id)
+func (w *WebApi) Disable(ctx context.Context, repo appconfig.Repo, id string) (response.Disable, error) {
+	disableResponse, err := doRequest(ctx, disable, w.newRequest(repo).withId(id))
 	if err != nil {
 		return response.Disable{}, err
 	}
 
-	disableResponseObj := response.Disable{}
-	err = response.FromString(disableResponse, &disableResponseObj)
-	if err != nil {
-		return response.Disable{}, err
+	// Successful calls reply with 204 No Content, so there is not always a body to parse
+	disableResponseObj := response.Disable{Status: disableResponse.StatusCode}
+	if disableResponse.hasBody() {
+		err = response.FromString(disableResponse.Body, &disableResponseObj)
+		if err != nil {
+			return response.Disable{}, err
+		}
 	}
 
 	return disableResponseObj, nil
 }
 
+// ListRuns returns the runs of a single workflow for a given repo
+func (w *WebApi) ListRuns(ctx context.Context, repo appconfig.Repo, id string, filter request.RunFilter) ([]response.Run, error) {
+	return w.RunPages(repo, id, filter).All(ctx)
+}
+
+// ListRepoRuns returns the runs of all workflows for a given repo
+func (w *WebApi) ListRepoRuns(ctx context.Context, repo appconfig.Repo, filter request.RunFilter) ([]response.Run, error) {
+	return w.RepoRunPages(repo, filter).All(ctx)
+}
+
+// RunPages returns a pager that lazily fetches the runs of a single workflow page by page
+func (w *WebApi) RunPages(repo appconfig.Repo, id string, filter request.RunFilter) *Pager[response.Run] {
+	return w.runPager(listRuns, repo, id, filter)
+}
+
+// RepoRunPages returns a pager that lazily fetches the runs of all workflows in a repo page by page
+func (w *WebApi) RepoRunPages(repo appconfig.Repo, filter request.RunFilter) *Pager[response.Run] {
+	return w.runPager(listRepoRuns, repo, "", filter)
+}
+
+// GetRun returns a single workflow run for a given repo
+func (w *WebApi) GetRun(ctx context.Context, repo appconfig.Repo, runId string) (response.Run, error) {
+	apiResponse, err := doRequest(ctx, getRun, w.newRequest(repo).withId(runId))
+	if err != nil {
+		return response.Run{}, err
+	}
+
+	getRunResponse := response.GetRun{}
+	err = response.FromString(apiResponse.Body, &getRunResponse)
+	if err != nil {
+		return response.Run{}, err
+	}
+
+	return getRunResponse.Run, nil
+}
+
+// CurrentUser returns the account the token of the repo belongs to
+func (w *WebApi) CurrentUser(ctx context.Context, repo appconfig.Repo) (response.User, error) {
+	apiResponse, err := doRequest(ctx, getUser, w.newRequest(repo))
+	if err != nil {
+		return response.User{}, err
+	}
+
+	user := response.User{}
+	err = response.FromString(apiResponse.Body, &user)
+	if err != nil {
+		return response.User{}, err
+	}
+
+	// Only classic tokens report their scopes
+	for _, scope := range strings.Split(apiResponse.Header.Get("X-OAuth-Scopes"), ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			user.Scopes = append(user.Scopes, scope)
+		}
+	}
+
+	return user, nil
+}
+
+// GetRepo returns a single repo, along with the permissions of the token in it
+func (w *WebApi) GetRepo(ctx context.Context, repo appconfig.Repo) (response.Repository, error) {
+	apiResponse, err := doRequest(ctx, getRepo, w.newRequest(repo))
+	if err != nil {
+		return response.Repository{}, err
+	}
+
+	repository := response.Repository{}
+	err = response.FromString(apiResponse.Body, &repository)
+	if err != nil {
+		return response.Repository{}, err
+	}
+
+	return repository, nil
+}
+
+// ListRepos returns every repo of the owner of the given repo, which may be an organization or a user.
+// The repos of the user the token belongs to include their private ones
+func (w *WebApi) ListRepos(ctx context.Context, repo appconfig.Repo) ([]response.Repository, error) {
+	repos, err := w.repoPager(listOrgRepos, repo, nil).All(ctx)
+
+	var apiError *response.ApiError
+	if !errors.As(err, &apiError) || apiError.StatusCode != http.StatusNotFound {
+		return repos, err
+	}
+
+	// Not an organization, so the owner is a user
+	user, err := w.CurrentUser(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(user.Login, repo.Owner) {
+		return w.repoPager(listOwnRepos, repo, url.Values{"affiliation": {"owner"}}).All(ctx)
+	}
+	return w.repoPager(listUserRepos, repo, nil).All(ctx)
+}
+
+func (w *WebApi) repoPager(target action, repo appconfig.Repo, query url.Values) *Pager[response.Repository] {
+	return newPager(target, w.newRequest(repo).withQuery(query), w.PerPage, 0, func(body string) ([]response.Repository, error) {
+		repos := []response.Repository{}
+		err := response.FromString(body, &repos)
+		return repos, err
+	})
+}
+
+// ActionsEnabled reports whether GitHub Actions may run in the repo.
+// Tokens that may not read the setting are assumed to see a repo with Actions enabled
+func (w *WebApi) ActionsEnabled(ctx context.Context, repo appconfig.Repo) (bool, error) {
+	apiResponse, err := doRequest(ctx, getActionsPermissions, w.newRequest(repo))
+
+	var apiError *response.ApiError
+	if errors.As(err, &apiError) && (apiError.StatusCode == http.StatusForbidden || apiError.StatusCode == http.StatusNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	permissions := response.ActionsPermissions{}
+	if err := response.FromString(apiResponse.Body, &permissions); err != nil {
+		return false, err
+	}
+	return permissions.Enabled, nil
+}
+
+// CancelRun asks GitHub to cancel a workflow run. The run keeps going until its jobs have stopped
+func (w *WebApi) CancelRun(ctx context.Context, repo appconfig.Repo, runId string) (response.Cancel, error) {
+	return w.cancel(ctx, cancelRun, repo, runId)
+}
+
+// ForceCancelRun cancels a workflow run, bypassing conditions like always() that keep jobs running after a cancel
+func (w *WebApi) ForceCancelRun(ctx context.Context, repo appconfig.Repo, runId string) (response.Cancel, error) {
+	return w.cancel(ctx, forceCancelRun, repo, runId)
+}
+
+// RerunRun starts a new attempt of every job in a workflow run
+func (w *WebApi) RerunRun(ctx context.Context, repo appconfig.Repo, runId string, rerunRequest request.Rerun) (response.Rerun, error) {
+	return w.rerun(ctx, rerunRun, repo, runId, rerunRequest)
+}
+
+// RerunFailedJobs starts a new attempt of the failed jobs in a workflow run, and the jobs depending on them
+func (w *WebApi) RerunFailedJobs(ctx context.Context, repo appconfig.Repo, runId string, rerunRequest request.Rerun) (response.Rerun, error) {
+	return w.rerun(ctx, rerunFailedJobs, repo, runId, rerunRequest)
+}
+
+// RerunJob starts a new attempt of a single job, and the jobs depending on it
+func (w *WebApi) RerunJob(ctx context.Context, repo appconfig.Repo, jobId string, rerunRequest request.Rerun) (response.Rerun, error) {
+	return w.rerun(ctx, rerunJob, repo, jobId, rerunRequest)
+}
+
+func (w *WebApi) cancel(ctx context.Context, target action, repo appconfig.Repo, runId string) (response.Cancel, error) {
+	cancelResponse, err := doRequest(ctx, target, w.newRequest(repo).withId(runId))
+	if err != nil {
+		return response.Cancel{}, err
+	}
+
+	// Successful calls reply with 202 Accepted and an empty object
+	cancelResponseObj := response.Cancel{Status: cancelResponse.StatusCode}
+	if cancelResponse.hasBody() {
+		err = response.FromString(cancelResponse.Body, &cancelResponseObj)
+		if err != nil {
+			return response.Cancel{}, err
+		}
+	}
+
+	return cancelResponseObj, nil
+}
+
+func (w *WebApi) rerun(ctx context.Context, target action, repo appconfig.Repo, id string, rerunRequest request.Rerun) (response.Rerun, error) {
+	body, err := json.Marshal(rerunRequest)
+	if err != nil {
+		return response.Rerun{}, err
+	}
+
+	rerunResponse, err := doRequest(ctx, target, w.newRequest(repo).withId(id).withBody(body))
+	if err != nil {
+		return response.Rerun{}, err
+	}
+
+	// Successful calls reply with 201 Created and an empty object
+	rerunResponseObj := response.Rerun{Status: rerunResponse.StatusCode}
+	if rerunResponse.hasBody() {
+		err = response.FromString(rerunResponse.Body, &rerunResponseObj)
+		if err != nil {
+			return response.Rerun{}, err
+		}
+	}
+
+	return rerunResponseObj, nil
+}
+
+// ListJobs returns the jobs of the latest attempt of a workflow run
+func (w *WebApi) ListJobs(ctx context.Context, repo appconfig.Repo, runId string) ([]response.Job, error) {
+	return w.JobPages(repo, runId).All(ctx)
+}
+
+// JobPages returns a pager that lazily fetches the jobs of a workflow run page by page
+func (w *WebApi) JobPages(repo appconfig.Repo, runId string) *Pager[response.Job] {
+	return w.jobPager(listJobs, w.newRequest(repo).withId(runId))
+}
+
+// ListAttemptJobs returns the jobs of a single attempt of a workflow run. Attempts are numbered from 1
+func (w *WebApi) ListAttemptJobs(ctx context.Context, repo appconfig.Repo, runId string, attempt int) ([]response.Job, error) {
+	return w.AttemptJobPages(repo, runId, attempt).All(ctx)
+}
+
+// AttemptJobPages returns a pager that lazily fetches the jobs of a single attempt of a workflow run page by page
+func (w *WebApi) AttemptJobPages(repo appconfig.Repo, runId string, attempt int) *Pager[response.Job] {
+	return w.jobPager(listAttemptJobs, w.newRequest(repo).withId(runId).withAttempt(attempt))
+}
+
+// GetContent returns the decoded contents of a file in the repo at the given ref, e.g. a workflow file
+func (w *WebApi) GetContent(ctx context.Context, repo appconfig.Repo, path string, ref string) (string, error) {
+	apiRequest := w.newRequest(repo).
+		withId(path).
+		withQuery(url.Values{"ref": []string{ref}})
+
+	apiResponse, err := doRequest(ctx, getContent, apiRequest)
+	if err != nil {
+		return "", err
+	}
+
+	content := response.Content{}
+	err = response.FromString(apiResponse.Body, &content)
+	if err != nil {
+		return "", err
+	}
+
+	return content.Decode()
+}
+
+// RunLogs downloads the logs of every job in a workflow run.
+// The API redirects to a zip archive, which is unpacked into one file per job and step
+func (w *WebApi) RunLogs(ctx context.Context, repo appconfig.Repo, runId string) ([]response.LogFile, error) {
+	// Logs are large and immutable once written, so they are kept out of the cache
+	apiResponse, err := doRequest(ctx, runLogs, w.newRequest(repo).withId(runId).withCache(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	return unzipLogs([]byte(apiResponse.Body))
+}
+
+// JobLogs downloads the plain text log of a single job
+func (w *WebApi) JobLogs(ctx context.Context, repo appconfig.Repo, jobId string) (string, error) {
+	apiResponse, err := doRequest(ctx, jobLogs, w.newRequest(repo).withId(jobId).withCache(nil))
+	if err != nil {
+		return "", err
+	}
+
+	return apiResponse.Body, nil
+}
+
+func (w *WebApi) jobPager(target action, apiRequest *webApiRequest) *Pager[response.Job] {
+	return newPager(target, apiRequest, w.PerPage, 0, func(body string) ([]response.Job, error) {
+		jobListResponse := response.JobList{}
+		err := response.FromString(body, &jobListResponse)
+		return jobListResponse.Jobs, err
+	})
+}
+
+func (w *WebApi) runPager(target action, repo appconfig.Repo, id string, filter request.RunFilter) *Pager[response.Run] {
+	apiRequest := w.newRequest(repo).
+		withId(id).
+		withQuery(filter.Query())
+
+	return newPager(target, apiRequest, w.PerPage, filter.Limit, func(body string) ([]response.Run, error) {
+		runListResponse := response.RunList{}
+		err := response.FromString(body, &runListResponse)
+		return runListResponse.WorkflowRuns, err
+	})
+}
+
+// Unpacks the log archive of a run. Full job logs sit at the top level,
+// while the logs of the individual steps are in a directory per job
+func unzipLogs(archive []byte) ([]response.LogFile, error) {
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, fmt.Errorf("could not read log archive: %w", err)
+	}
+
+	logFiles := []response.LogFile{}
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		contents, err := readZipFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		logFiles = append(logFiles, response.LogFile{Name: file.Name, Content: contents})
+	}
+
+	return logFiles, nil
+}
+
+func readZipFile(file *zip.File) (string, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	contents, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+
+	return string(contents), nil
+}
+
+// Starts a request for the given repo with the settings of this consumer
+func (w *WebApi) newRequest(repo appconfig.Repo) *webApiRequest {
+	return newWebApiRequest().
+		withRepo(repo).
+		withCache(w.Cache).
+		withTimeout(w.Timeout)
+}
+
 func GetHttpClient() *http.Client {
 	if sharedHttpClient == nil {
 		sharedHttpClient = http.DefaultClient
@@ -124,54 +522,169 @@ func InjectHttpClient(injectedClient *http.Client) {
 	sharedHttpClient = injectedClient
 }
 
-func doRequest(target action, repo appconfig.Repo, id string) (string, error) {
-	url, err := newWebApiRequest().
-		withRepo(repo).
-		withId(id).
-		build(target)
-
+// Performs the request and returns the response. Responses with a non-2xx status
+// are returned as a *response.ApiError
+func doRequest(ctx context.Context, target action, apiRequest *webApiRequest) (webApiResponse, error) {
+	url, err := apiRequest.build(target)
 	if err != nil {
-		return "", err
+		return webApiResponse{}, err
 	}
 
 	var method string
-	var bodyRaw []byte
 
 	switch target {
-	case disable:
-	case enable:
+	case disable, enable:
 		method = "PUT"
-	case dispatch:
+	case dispatch, cancelRun, forceCancelRun, rerunRun, rerunFailedJobs, rerunJob:
 		method = "POST"
-	case get:
-	case list:
+	case get, list, listRuns, listRepoRuns, getRun, listJobs, runLogs, jobLogs, listAttemptJobs, getContent, getUser,
+		listOrgRepos, listUserRepos, listOwnRepos, getActionsPermissions, getRepo:
 		method = "GET"
 	default:
-		return "", fmt.Errorf("invalid target")
+		return webApiResponse{}, fmt.Errorf("invalid target")
+	}
+
+	// Don't spend a request we already know will be rejected
+	if err := checkRateLimit(apiRequest.Repo.Token); err != nil {
+		return webApiResponse{}, err
 	}
 
-	body := bytes.NewReader(bodyRaw)
-	req, err := http.NewRequest(method, url, body)
+	// Only reads are cached, anything else must reach the API
+	var key string
+	var cached *CacheEntry
+	if apiRequest.Cache != nil && method == "GET" {
+		key = cacheKey(apiRequest.Repo.Token, url)
+		if entry, ok := apiRequest.Cache.Get(key); ok {
+			cached = &entry
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		apiResponse, err := send(ctx, method, url, apiRequest, cached)
+		if err != nil {
+			return webApiResponse{}, err
+		}
+
+		recordRateLimit(apiRequest.Repo.Token, apiResponse.Header)
+
+		if apiResponse.StatusCode == http.StatusNotModified && cached != nil {
+			return cached.response(), nil
+		}
+
+		if key != "" && apiResponse.StatusCode == http.StatusOK {
+			storeInCache(apiRequest.Cache, key, apiResponse)
+		}
+
+		if apiResponse.StatusCode >= 200 && apiResponse.StatusCode <= 299 {
+			return apiResponse, nil
+		}
+
+		wait, retry := retryDelay(method, apiResponse, attempt)
+		if !retry {
+			return apiResponse, newApiError(apiResponse)
+		}
+
+		if err := sleep(ctx, wait); err != nil {
+			return apiResponse, err
+		}
+	}
+}
+
+// Sends a single HTTP request and reads the full response.
+// Given a cached entry, the request is made conditional on it having changed
+func send(ctx context.Context, method string, url string, apiRequest *webApiRequest, cached *CacheEntry) (webApiResponse, error) {
+	if apiRequest.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, apiRequest.Timeout)
+		defer cancel()
+	}
+
+	body := bytes.NewReader(apiRequest.Body)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
-		return "", err
+		return webApiResponse{}, err
 	}
 
 	req.Header.Set("Accept", "application/vnd.github+json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", repo.Token))
+	if len(apiRequest.Body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiRequest.Repo.Token))
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		} else if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
 
-	resp, err := GetHttpClient().Do(req)
+	client, err := httpClientFor(apiRequest.Repo)
 	if err != nil {
-		return "", err
+		return webApiResponse{}, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return webApiResponse{}, err
 	}
 
 	defer resp.Body.Close()
 
 	responseText, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return webApiResponse{}, err
+	}
+
+	return webApiResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       string(responseText),
+	}, nil
+}
+
+// Stores the response if it carries a validator we can revalidate it with later
+func storeInCache(cache Cache, key string, apiResponse webApiResponse) {
+	entry := CacheEntry{
+		ETag:         apiResponse.Header.Get("ETag"),
+		LastModified: apiResponse.Header.Get("Last-Modified"),
+		Link:         apiResponse.Header.Get("Link"),
+		Body:         apiResponse.Body,
+	}
+
+	if entry.ETag != "" || entry.LastModified != "" {
+		cache.Set(key, entry)
+	}
+}
+
+// Turns a cache hit back into the response it was stored from
+func (e CacheEntry) response() webApiResponse {
+	header := http.Header{}
+	if e.Link != "" {
+		header.Set("Link", e.Link)
 	}
 
-	return string(responseText), nil
+	return webApiResponse{StatusCode: http.StatusOK, Header: header, Body: e.Body}
+}
+
+// Whether the response carries a body worth parsing
+func (r webApiResponse) hasBody() bool {
+	return r.StatusCode != http.StatusNoContent && strings.TrimSpace(r.Body) != ""
+}
+
+// Turns an unsuccessful response into an API error, using the error body GitHub sends when available
+func newApiError(apiResponse webApiResponse) *response.ApiError {
+	apiError := &response.ApiError{}
+	if apiResponse.hasBody() {
+		// A body that isn't a GitHub error document still leaves us with the status code
+		_ = response.FromString(apiResponse.Body, apiError)
+	}
+
+	apiError.StatusCode = apiResponse.StatusCode
+	if apiError.Message == "" {
+		apiError.Message = http.StatusText(apiResponse.StatusCode)
+	}
+
+	return apiError
 }
 
 // Use the builder pattern to create a new webApiRequest
@@ -191,39 +704,151 @@ func (w *webApiRequest) withId(id string) *webApiRequest {
 	return w
 }
 
+// Set the run attempt for the webApiRequest
+func (w *webApiRequest) withAttempt(attempt int) *webApiRequest {
+	w.Attempt = attempt
+	return w
+}
+
+// Set the query parameters for the webApiRequest
+func (w *webApiRequest) withQuery(query url.Values) *webApiRequest {
+	w.Query = query
+	return w
+}
+
+// Set the cache used to make conditional requests for the webApiRequest
+func (w *webApiRequest) withCache(cache Cache) *webApiRequest {
+	w.Cache = cache
+	return w
+}
+
+// Set how long a single attempt of the webApiRequest may take
+func (w *webApiRequest) withTimeout(timeout time.Duration) *webApiRequest {
+	w.Timeout = timeout
+	return w
+}
+
+// Set the JSON body for the webApiRequest
+func (w *webApiRequest) withBody(body []byte) *webApiRequest {
+	w.Body = body
+	return w
+}
+
+// Set an absolute URL for the webApiRequest, e.g. the next page of a list.
+// It takes precedence over the URL that would be built from the target
+func (w *webApiRequest) withUrl(url string) *webApiRequest {
+	copied := *w
+	copied.Url = url
+	return &copied
+}
+
 // Build the webApiRequest
 func (w *webApiRequest) build(target action) (string, error) {
 	// Check to see if the repo is set and valid (not empty)
-	err := checkValidRepo(w.Repo)
+	err := checkValidRepo(target, w.Repo)
+	if err != nil {
+		return "", err
+	}
+
+	if w.Url != "" {
+		return w.Url, nil
+	}
+
+	path, err := w.path(target)
+	if err != nil {
+		return "", err
+	}
+
+	if len(w.Query) > 0 {
+		path += "?" + w.Query.Encode()
+	}
+
+	return path, nil
+}
+
+// Resolves the URL of the endpoint, without any query parameters
+func (w *webApiRequest) path(target action) (string, error) {
+	base, err := apiBaseUrl(w.Repo.ApiUrl)
 	if err != nil {
 		return "", err
 	}
 
 	switch target {
 	case enable:
-		return fmt.Sprintf("https://api.github.com/repos/%s/%s/actions/workflows/%s/enable", w.Repo.Owner, w.Repo.Repo, w.Id), nil
+		return fmt.Sprintf("%s/repos/%s/%s/actions/workflows/%s/enable", base, w.Repo.Owner, w.Repo.Repo, w.Id), nil
 	case disable:
-		return fmt.Sprintf("https://api.github.com/repos/%s/%s/actions/workflows/%s/disable", w.Repo.Owner, w.Repo.Repo, w.Id), nil
+		return fmt.Sprintf("%s/repos/%s/%s/actions/workflows/%s/disable", base, w.Repo.Owner, w.Repo.Repo, w.Id), nil
 	case dispatch:
-		return fmt.Sprintf("https://api.github.com/repos/%s/%s/actions/workflows/%s/dispatches", w.Repo.Owner, w.Repo.Repo, w.Id), nil
+		return fmt.Sprintf("%s/repos/%s/%s/actions/workflows/%s/dispatches", base, w.Repo.Owner, w.Repo.Repo, w.Id), nil
 	case get:
-		return fmt.Sprintf("https://api.github.com/repos/%s/%s/actions/workflows/%s", w.Repo.Owner, w.Repo.Repo, w.Id), nil
+		return fmt.Sprintf("%s/repos/%s/%s/actions/workflows/%s", base, w.Repo.Owner, w.Repo.Repo, w.Id), nil
 	case list:
-		return fmt.Sprintf("https://api.github.com/repos/%s/%s/actions/workflows", w.Repo.Owner, w.Repo.Repo), nil
+		return fmt.Sprintf("%s/repos/%s/%s/actions/workflows", base, w.Repo.Owner, w.Repo.Repo), nil
+	case listRuns:
+		return fmt.Sprintf("%s/repos/%s/%s/actions/workflows/%s/runs", base, w.Repo.Owner, w.Repo.Repo, w.Id), nil
+	case listRepoRuns:
+		return fmt.Sprintf("%s/repos/%s/%s/actions/runs", base, w.Repo.Owner, w.Repo.Repo), nil
+	case getRun:
+		return fmt.Sprintf("%s/repos/%s/%s/actions/runs/%s", base, w.Repo.Owner, w.Repo.Repo, w.Id), nil
+	case listJobs:
+		return fmt.Sprintf("%s/repos/%s/%s/actions/runs/%s/jobs", base, w.Repo.Owner, w.Repo.Repo, w.Id), nil
+	case runLogs:
+		return fmt.Sprintf("%s/repos/%s/%s/actions/runs/%s/logs", base, w.Repo.Owner, w.Repo.Repo, w.Id), nil
+	case jobLogs:
+		return fmt.Sprintf("%s/repos/%s/%s/actions/jobs/%s/logs", base, w.Repo.Owner, w.Repo.Repo, w.Id), nil
+	case listAttemptJobs:
+		return fmt.Sprintf("%s/repos/%s/%s/actions/runs/%s/attempts/%d/jobs", base, w.Repo.Owner, w.Repo.Repo, w.Id, w.Attempt), nil
+	case cancelRun:
+		return fmt.Sprintf("%s/repos/%s/%s/actions/runs/%s/cancel", base, w.Repo.Owner, w.Repo.Repo, w.Id), nil
+	case forceCancelRun:
+		return fmt.Sprintf("%s/repos/%s/%s/actions/runs/%s/force-cancel", base, w.Repo.Owner, w.Repo.Repo, w.Id), nil
+	case rerunRun:
+		return fmt.Sprintf("%s/repos/%s/%s/actions/runs/%s/rerun", base, w.Repo.Owner, w.Repo.Repo, w.Id), nil
+	case rerunFailedJobs:
+		return fmt.Sprintf("%s/repos/%s/%s/actions/runs/%s/rerun-failed-jobs", base, w.Repo.Owner, w.Repo.Repo, w.Id), nil
+	case rerunJob:
+		return fmt.Sprintf("%s/repos/%s/%s/actions/jobs/%s/rerun", base, w.Repo.Owner, w.Repo.Repo, w.Id), nil
+	case getUser:
+		return fmt.Sprintf("%s/user", base), nil
+	case listOrgRepos:
+		return fmt.Sprintf("%s/orgs/%s/repos", base, w.Repo.Owner), nil
+	case listUserRepos:
+		return fmt.Sprintf("%s/users/%s/repos", base, w.Repo.Owner), nil
+	case listOwnRepos:
+		return fmt.Sprintf("%s/user/repos", base), nil
+	case getRepo:
+		return fmt.Sprintf("%s/repos/%s/%s", base, w.Repo.Owner, w.Repo.Repo), nil
+	case getActionsPermissions:
+		return fmt.Sprintf("%s/repos/%s/%s/actions/permissions", base, w.Repo.Owner, w.Repo.Repo), nil
+	case getContent:
+		return fmt.Sprintf("%s/repos/%s/%s/contents/%s", base, w.Repo.Owner, w.Repo.Repo, strings.TrimPrefix(w.Id, "/")), nil
 	default:
 		return "", fmt.Errorf("invalid target")
 	}
 }
 
-func checkValidRepo(repo appconfig.Repo) error {
+// Checks that the repo has what the target needs to be requested. The token is never part of the error
+func checkValidRepo(target action, repo appconfig.Repo) error {
+	if repo.Token == "" {
+		return fmt.Errorf("token is not set for repo %s/%s", repo.Owner, repo.Repo)
+	}
+
+	switch target {
+	case getUser, listOwnRepos:
+		// These are about the token alone
+		return nil
+	case listOrgRepos, listUserRepos:
+		if repo.Owner == "" {
+			return fmt.Errorf("owner is not set for repo %q", repo.Repo)
+		}
+		return nil
+	}
+
 	if repo.Owner == "" {
-		return fmt.Errorf("owner is not set for repository settings: %v", repo)
+		return fmt.Errorf("owner is not set for repo %q", repo.Repo)
 	}
 	if repo.Repo == "" {
-		return fmt.Errorf("repo is not set for repository settings: %v", repo)
-	}
-	if repo.Token == "" {
-		return fmt.Errorf("token is not set for repository settings: %v", repo)
+		return fmt.Errorf("repo is not set for owner %q", repo.Owner)
 	}
 	return nil
 }
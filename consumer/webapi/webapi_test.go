@@ -1,12 +1,19 @@
 package webapi
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/andreaswachs/lazyworkflows/appconfig"
+	"github.com/andreaswachs/lazyworkflows/model/request"
 	"github.com/andreaswachs/lazyworkflows/model/response"
 	"github.com/andreaswachs/lazyworkflows/test_resources"
 )
@@ -21,10 +28,15 @@ func (f MockRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
 
 // Mocks the sharedHttpClient such that we can control the response
 func SetupSuite(t *testing.T, response string) func(t *testing.T) {
+	return SetupSuiteWithStatus(t, 200, response)
+}
+
+// Mocks the sharedHttpClient such that we can control both the status code and the response
+func SetupSuiteWithStatus(t *testing.T, statusCode int, response string) func(t *testing.T) {
 	InjectHttpClient(&http.Client{
 		Transport: MockRoundTripper(func(r *http.Request) *http.Response {
 			return &http.Response{
-				StatusCode: 200,
+				StatusCode: statusCode,
 				Body:       io.NopCloser(strings.NewReader(response)),
 			}
 		})})
@@ -35,7 +47,7 @@ func SetupSuite(t *testing.T, response string) func(t *testing.T) {
 func TestListCanGetListOfWorkflows(t *testing.T) {
 
 	responseInterface, err := executeWithSetup(t, test_resources.ListResponse, func(apiConsumer WebApi, repo appconfig.Repo) (interface{}, error) {
-		return apiConsumer.List(repo)
+		return apiConsumer.List(context.Background(), repo)
 	})
 	if err != nil {
 		t.Errorf("error getting list of workflows: %v", err)
@@ -49,7 +61,7 @@ func TestListCanGetListOfWorkflows(t *testing.T) {
 
 func TestGetCanGetAWorkflow(t *testing.T) {
 	responseInterface, err := executeWithSetup(t, test_resources.Workflow1, func(apiConsumer WebApi, repo appconfig.Repo) (interface{}, error) {
-		return apiConsumer.Get(repo, "filler")
+		return apiConsumer.Get(context.Background(), repo, "filler")
 	})
 	if err != nil {
 		t.Errorf("error getting workflow: %v", err)
@@ -70,7 +82,7 @@ func TestGetCanGetAWorkflow(t *testing.T) {
 
 func TestDispatchCanGetAWorkflow(t *testing.T) {
 	responseInterface, err := executeWithSetup(t, test_resources.Status200Response, func(apiConsumer WebApi, repo appconfig.Repo) (interface{}, error) {
-		return apiConsumer.Dispatch(repo, "filler")
+		return apiConsumer.Dispatch(context.Background(), repo, "filler", request.Dispatch{Ref: "main"})
 	})
 	if err != nil {
 		t.Errorf("error dispatching workflow: %v", err)
@@ -82,9 +94,124 @@ func TestDispatchCanGetAWorkflow(t *testing.T) {
 	}
 }
 
+func TestDispatchSendsRefAndInputs(t *testing.T) {
+	var requestBody string
+	InjectHttpClient(&http.Client{
+		Transport: MockRoundTripper(func(r *http.Request) *http.Response {
+			body, _ := io.ReadAll(r.Body)
+			requestBody = string(body)
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(test_resources.Status200Response)),
+			}
+		})})
+
+	apiConsumer := WebApi{}
+	_, err := apiConsumer.Dispatch(context.Background(), getTestingRepo(), "filler", request.Dispatch{
+		Ref:    "main",
+		Inputs: map[string]interface{}{"environment": "staging", "dry_run": true},
+	})
+	if err != nil {
+		t.Fatalf("error dispatching workflow: %v", err)
+	}
+
+	expected := `{"ref":"main","inputs":{"dry_run":true,"environment":"staging"}}`
+	if requestBody != expected {
+		t.Errorf("error: expected body %v, got: %v", expected, requestBody)
+	}
+}
+
+func TestDispatchRequiresRef(t *testing.T) {
+	_, err := executeWithSetup(t, test_resources.Status200Response, func(apiConsumer WebApi, repo appconfig.Repo) (interface{}, error) {
+		return apiConsumer.Dispatch(context.Background(), repo, "filler", request.Dispatch{})
+	})
+	if err == nil {
+		t.Errorf("error: expected dispatching without a ref to fail")
+	}
+}
+
+func TestDispatchSurfacesValidationErrors(t *testing.T) {
+	_, err := executeWithStatus(t, 422, test_resources.DispatchValidationResponse, func(apiConsumer WebApi, repo appconfig.Repo) (interface{}, error) {
+		return apiConsumer.Dispatch(context.Background(), repo, "filler", request.Dispatch{Ref: "main"})
+	})
+	if err == nil {
+		t.Fatalf("error: expected dispatch to fail")
+	}
+	if !strings.Contains(err.Error(), "Required input 'environment' not provided") {
+		t.Errorf("error: expected the API message in the error, got: %v", err)
+	}
+}
+
+func TestDispatchTreatsNoContentAsSuccess(t *testing.T) {
+	responseInterface, err := executeWithStatus(t, 204, "", func(apiConsumer WebApi, repo appconfig.Repo) (interface{}, error) {
+		return apiConsumer.Dispatch(context.Background(), repo, "filler", request.Dispatch{Ref: "main"})
+	})
+	if err != nil {
+		t.Fatalf("error dispatching workflow: %v", err)
+	}
+
+	dispatchResponse := responseInterface.(response.Dispatch)
+	if dispatchResponse.Status != 204 {
+		t.Errorf("error: expected status 204, got: %v", dispatchResponse.Status)
+	}
+}
+
+func TestEnableAndDisableTreatNoContentAsSuccess(t *testing.T) {
+	responseInterface, err := executeWithStatus(t, 204, "", func(apiConsumer WebApi, repo appconfig.Repo) (interface{}, error) {
+		return apiConsumer.Enable(context.Background(), repo, "filler")
+	})
+	if err != nil {
+		t.Fatalf("error enabling workflow: %v", err)
+	}
+	if responseInterface.(response.Enable).Status != 204 {
+		t.Errorf("error: expected status 204, got: %v", responseInterface.(response.Enable).Status)
+	}
+
+	responseInterface, err = executeWithStatus(t, 204, "", func(apiConsumer WebApi, repo appconfig.Repo) (interface{}, error) {
+		return apiConsumer.Disable(context.Background(), repo, "filler")
+	})
+	if err != nil {
+		t.Fatalf("error disabling workflow: %v", err)
+	}
+	if responseInterface.(response.Disable).Status != 204 {
+		t.Errorf("error: expected status 204, got: %v", responseInterface.(response.Disable).Status)
+	}
+}
+
+func TestNonSuccessfulStatusReturnsApiError(t *testing.T) {
+	_, err := executeWithStatus(t, 404, test_resources.NotFoundResponse, func(apiConsumer WebApi, repo appconfig.Repo) (interface{}, error) {
+		return apiConsumer.Get(context.Background(), repo, "filler")
+	})
+
+	var apiError *response.ApiError
+	if !errors.As(err, &apiError) {
+		t.Fatalf("error: expected an ApiError, got: %v", err)
+	}
+	if apiError.StatusCode != 404 {
+		t.Errorf("error: expected status 404, got: %v", apiError.StatusCode)
+	}
+	if apiError.Message != "Not Found" {
+		t.Errorf("error: expected message \"Not Found\", got: %v", apiError.Message)
+	}
+}
+
+func TestNonSuccessfulStatusWithoutBodyReturnsApiError(t *testing.T) {
+	_, err := executeWithStatus(t, 401, "", func(apiConsumer WebApi, repo appconfig.Repo) (interface{}, error) {
+		return apiConsumer.List(context.Background(), repo)
+	})
+
+	var apiError *response.ApiError
+	if !errors.As(err, &apiError) {
+		t.Fatalf("error: expected an ApiError, got: %v", err)
+	}
+	if apiError.Message != "Unauthorized" {
+		t.Errorf("error: expected message \"Unauthorized\", got: %v", apiError.Message)
+	}
+}
+
 func TestEnableCanEnableAWorkflow(t *testing.T) {
 	responseInterface, err := executeWithSetup(t, test_resources.Status200Response, func(apiConsumer WebApi, repo appconfig.Repo) (interface{}, error) {
-		return apiConsumer.Enable(repo, "filler")
+		return apiConsumer.Enable(context.Background(), repo, "filler")
 	})
 	if err != nil {
 		t.Errorf("error enabling workflow: %v", err)
@@ -98,7 +225,7 @@ func TestEnableCanEnableAWorkflow(t *testing.T) {
 
 func TestDisableCanDisableAWorkflow(t *testing.T) {
 	responseInterface, err := executeWithSetup(t, test_resources.Status200Response, func(apiConsumer WebApi, repo appconfig.Repo) (interface{}, error) {
-		return apiConsumer.Disable(repo, "filler")
+		return apiConsumer.Disable(context.Background(), repo, "filler")
 	})
 	if err != nil {
 		t.Errorf("error disabling workflow: %v", err)
@@ -110,6 +237,735 @@ func TestDisableCanDisableAWorkflow(t *testing.T) {
 	}
 }
 
+func TestListRunsCanGetListOfRuns(t *testing.T) {
+	responseInterface, err := executeWithSetup(t, test_resources.RunListResponse, func(apiConsumer WebApi, repo appconfig.Repo) (interface{}, error) {
+		return apiConsumer.ListRuns(context.Background(), repo, "filler", request.RunFilter{})
+	})
+	if err != nil {
+		t.Errorf("error getting list of runs: %v", err)
+	}
+
+	runs := responseInterface.([]response.Run)
+	if len(runs) != 1 {
+		t.Fatalf("error: expected 1 run, got: %v", len(runs))
+	}
+	if runs[0].Conclusion != "success" {
+		t.Errorf("error: expected conclusion \"success\", got: %v", runs[0].Conclusion)
+	}
+}
+
+func TestListRepoRunsSendsFilterAsQuery(t *testing.T) {
+	var requestedUrl string
+	InjectHttpClient(&http.Client{
+		Transport: MockRoundTripper(func(r *http.Request) *http.Response {
+			requestedUrl = r.URL.String()
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(test_resources.RunListResponse)),
+			}
+		})})
+
+	apiConsumer := WebApi{}
+	filter := request.RunFilter{
+		Branch:       "main",
+		Status:       "failure",
+		CreatedAfter: time.Date(2022, 8, 28, 0, 0, 0, 0, time.UTC),
+	}
+	_, err := apiConsumer.ListRepoRuns(context.Background(), getTestingRepo(), filter)
+	if err != nil {
+		t.Fatalf("error getting list of runs: %v", err)
+	}
+
+	expected := "https://api.github.com/repos/filler/filler/actions/runs?branch=main&created=%3E%3D2022-08-28T00%3A00%3A00Z&per_page=100&status=failure"
+	if requestedUrl != expected {
+		t.Errorf("error: expected url %v, got: %v", expected, requestedUrl)
+	}
+}
+
+func TestGetRunCanGetARun(t *testing.T) {
+	responseInterface, err := executeWithSetup(t, test_resources.Run1, func(apiConsumer WebApi, repo appconfig.Repo) (interface{}, error) {
+		return apiConsumer.GetRun(context.Background(), repo, "30433642")
+	})
+	if err != nil {
+		t.Errorf("error getting run: %v", err)
+	}
+
+	run := responseInterface.(response.Run)
+	if run.Id != "30433642" {
+		t.Errorf("error: expected id 30433642, got: %v", run.Id)
+	}
+	if run.Actor.Login != "octocat" {
+		t.Errorf("error: expected actor \"octocat\", got: %v", run.Actor.Login)
+	}
+}
+
+// Mocks the sharedHttpClient with two pages of workflows linked through the Link header
+func setupPagedWorkflows(t *testing.T, requestedUrls *[]string) {
+	InjectHttpClient(&http.Client{
+		Transport: MockRoundTripper(func(r *http.Request) *http.Response {
+			*requestedUrls = append(*requestedUrls, r.URL.String())
+
+			header := http.Header{}
+			body := `{"total_count":3,"workflows":[` + test_resources.Workflow1 + `]}`
+			if r.URL.Query().Get("page") == "" {
+				header.Set("Link", `<https://api.github.com/repositories/1/actions/workflows?per_page=2&page=2>; rel="next", <https://api.github.com/repositories/1/actions/workflows?per_page=2&page=2>; rel="last"`)
+				body = `{"total_count":3,"workflows":[` + test_resources.Workflow1 + `,` + test_resources.Workflow2 + `]}`
+			}
+
+			return &http.Response{
+				StatusCode: 200,
+				Header:     header,
+				Body:       io.NopCloser(strings.NewReader(body)),
+			}
+		})})
+}
+
+func TestListFollowsAllPages(t *testing.T) {
+	requestedUrls := []string{}
+	setupPagedWorkflows(t, &requestedUrls)
+
+	apiConsumer := WebApi{PerPage: 2}
+	workflows, err := apiConsumer.List(context.Background(), getTestingRepo())
+	if err != nil {
+		t.Fatalf("error getting list of workflows: %v", err)
+	}
+
+	if len(workflows) != 3 {
+		t.Errorf("error: expected 3 workflows, got: %v", len(workflows))
+	}
+	if len(requestedUrls) != 2 {
+		t.Fatalf("error: expected 2 requests, got: %v", len(requestedUrls))
+	}
+	if requestedUrls[0] != "https://api.github.com/repos/filler/filler/actions/workflows?per_page=2" {
+		t.Errorf("error: unexpected url for the first page: %v", requestedUrls[0])
+	}
+	if requestedUrls[1] != "https://api.github.com/repositories/1/actions/workflows?per_page=2&page=2" {
+		t.Errorf("error: unexpected url for the second page: %v", requestedUrls[1])
+	}
+}
+
+func TestListPagesHandsOverEveryPageAsItArrives(t *testing.T) {
+	requestedUrls := []string{}
+	setupPagedWorkflows(t, &requestedUrls)
+
+	apiConsumer := WebApi{PerPage: 2}
+	pages := []int{}
+	err := apiConsumer.ListPages(context.Background(), getTestingRepo(), func(page []response.Workflow) {
+		// Every page is handed over before the next one is requested
+		if len(requestedUrls) != len(pages)+1 {
+			t.Errorf("error: expected page %v to be handed over after %v requests, got: %v", len(pages)+1, len(pages)+1, len(requestedUrls))
+		}
+		pages = append(pages, len(page))
+	})
+	if err != nil {
+		t.Fatalf("error getting the pages of workflows: %v", err)
+	}
+
+	if len(pages) != 2 || pages[0] != 2 || pages[1] != 1 {
+		t.Errorf("error: expected pages of 2 and 1 workflows, got: %v", pages)
+	}
+}
+
+func TestWorkflowPagesFetchesLazily(t *testing.T) {
+	requestedUrls := []string{}
+	setupPagedWorkflows(t, &requestedUrls)
+
+	apiConsumer := WebApi{PerPage: 2}
+	pager := apiConsumer.WorkflowPages(getTestingRepo())
+
+	page, err := pager.Next(context.Background())
+	if err != nil {
+		t.Fatalf("error getting first page: %v", err)
+	}
+	if len(page) != 2 || len(requestedUrls) != 1 {
+		t.Fatalf("error: expected 2 workflows from 1 request, got %v from %v", len(page), len(requestedUrls))
+	}
+	if !pager.HasNext() {
+		t.Fatalf("error: expected a second page")
+	}
+
+	page, err = pager.Next(context.Background())
+	if err != nil {
+		t.Fatalf("error getting second page: %v", err)
+	}
+	if len(page) != 1 {
+		t.Errorf("error: expected 1 workflow on the second page, got: %v", len(page))
+	}
+	if pager.HasNext() {
+		t.Errorf("error: expected no more pages")
+	}
+}
+
+func TestRunPagesStopsAtLimit(t *testing.T) {
+	requestedUrls := []string{}
+	InjectHttpClient(&http.Client{
+		Transport: MockRoundTripper(func(r *http.Request) *http.Response {
+			requestedUrls = append(requestedUrls, r.URL.String())
+			header := http.Header{}
+			header.Set("Link", `<https://api.github.com/repositories/1/actions/runs?page=2>; rel="next"`)
+			return &http.Response{
+				StatusCode: 200,
+				Header:     header,
+				Body:       io.NopCloser(strings.NewReader(`{"total_count":50,"workflow_runs":[` + test_resources.Run1 + `]}`)),
+			}
+		})})
+
+	apiConsumer := WebApi{}
+	runs, err := apiConsumer.ListRepoRuns(context.Background(), getTestingRepo(), request.RunFilter{Limit: 1})
+	if err != nil {
+		t.Fatalf("error getting list of runs: %v", err)
+	}
+
+	if len(runs) != 1 || len(requestedUrls) != 1 {
+		t.Errorf("error: expected 1 run from 1 request, got %v from %v", len(runs), len(requestedUrls))
+	}
+	if !strings.Contains(requestedUrls[0], "per_page=1") {
+		t.Errorf("error: expected the page size to be capped by the limit, got: %v", requestedUrls[0])
+	}
+}
+
+// Mocks the sharedHttpClient with a sequence of responses, one per request,
+// and replaces sleeping with recording the requested waits
+func setupResponseSequence(t *testing.T, responses []*http.Response, waits *[]time.Duration) *int {
+	requests := 0
+	InjectHttpClient(&http.Client{
+		Transport: MockRoundTripper(func(r *http.Request) *http.Response {
+			resp := responses[requests]
+			requests++
+			return resp
+		})})
+
+	sleep = func(ctx context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		return nil
+	}
+	rateLimits = make(map[string]response.RateLimit)
+	t.Cleanup(func() {
+		sleep = sleepContext
+		rateLimits = make(map[string]response.RateLimit)
+	})
+
+	return &requests
+}
+
+func newMockResponse(statusCode int, header http.Header, body string) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		StatusCode: statusCode,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestServerErrorsAreRetriedWithBackoff(t *testing.T) {
+	waits := []time.Duration{}
+	requests := setupResponseSequence(t, []*http.Response{
+		newMockResponse(502, nil, ""),
+		newMockResponse(503, nil, ""),
+		newMockResponse(200, nil, test_resources.Workflow1),
+	}, &waits)
+
+	apiConsumer := WebApi{}
+	workflow, err := apiConsumer.Get(context.Background(), getTestingRepo(), "filler")
+	if err != nil {
+		t.Fatalf("error getting workflow: %v", err)
+	}
+
+	if workflow.Name != "CI" {
+		t.Errorf("error: expected name \"CI\", got: %v", workflow.Name)
+	}
+	if *requests != 3 {
+		t.Errorf("error: expected 3 requests, got: %v", *requests)
+	}
+	if len(waits) != 2 || waits[1] != 2*waits[0] {
+		t.Errorf("error: expected two exponentially growing waits, got: %v", waits)
+	}
+}
+
+func TestServerErrorsAreNotRetriedForDispatch(t *testing.T) {
+	waits := []time.Duration{}
+	requests := setupResponseSequence(t, []*http.Response{
+		newMockResponse(502, nil, ""),
+	}, &waits)
+
+	apiConsumer := WebApi{}
+	_, err := apiConsumer.Dispatch(context.Background(), getTestingRepo(), "filler", request.Dispatch{Ref: "main"})
+	if err == nil {
+		t.Fatalf("error: expected dispatch to fail")
+	}
+	if *requests != 1 {
+		t.Errorf("error: expected 1 request, got: %v", *requests)
+	}
+}
+
+func TestSecondaryRateLimitHonorsRetryAfter(t *testing.T) {
+	header := http.Header{}
+	header.Set("Retry-After", "5")
+
+	waits := []time.Duration{}
+	setupResponseSequence(t, []*http.Response{
+		newMockResponse(403, header, test_resources.SecondaryRateLimitResponse),
+		newMockResponse(200, nil, test_resources.ListResponse),
+	}, &waits)
+
+	apiConsumer := WebApi{}
+	_, err := apiConsumer.List(context.Background(), getTestingRepo())
+	if err != nil {
+		t.Fatalf("error getting list of workflows: %v", err)
+	}
+
+	if len(waits) != 1 || waits[0] != 5*time.Second {
+		t.Errorf("error: expected a single wait of 5s, got: %v", waits)
+	}
+}
+
+func TestRateLimitIsRecordedAndExhaustedBudgetFailsFast(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Unix()
+	header := http.Header{}
+	header.Set("X-RateLimit-Limit", "5000")
+	header.Set("X-RateLimit-Remaining", "0")
+	header.Set("X-RateLimit-Used", "5000")
+	header.Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+	header.Set("X-RateLimit-Resource", "core")
+
+	waits := []time.Duration{}
+	requests := setupResponseSequence(t, []*http.Response{
+		newMockResponse(200, header, test_resources.Workflow1),
+	}, &waits)
+
+	apiConsumer := WebApi{}
+	_, err := apiConsumer.Get(context.Background(), getTestingRepo(), "filler")
+	if err != nil {
+		t.Fatalf("error getting workflow: %v", err)
+	}
+
+	rateLimit, ok := apiConsumer.RateLimit(getTestingRepo())
+	if !ok {
+		t.Fatalf("error: expected a rate limit to be recorded")
+	}
+	if rateLimit.Limit != 5000 || rateLimit.Remaining != 0 || rateLimit.Reset.Unix() != reset {
+		t.Errorf("error: unexpected rate limit: %+v", rateLimit)
+	}
+
+	_, err = apiConsumer.Get(context.Background(), getTestingRepo(), "filler")
+	var rateLimitError *response.RateLimitError
+	if !errors.As(err, &rateLimitError) {
+		t.Fatalf("error: expected a RateLimitError, got: %v", err)
+	}
+	if *requests != 1 {
+		t.Errorf("error: expected the exhausted budget to skip the request, got %v requests", *requests)
+	}
+}
+
+func TestConditionalRequestsServeNotModifiedFromCache(t *testing.T) {
+	for name, newCache := range map[string]func() Cache{
+		"memory": NewMemoryCache,
+		"disk": func() Cache {
+			cache, err := NewDiskCache(t.TempDir())
+			if err != nil {
+				t.Fatalf("error creating disk cache: %v", err)
+			}
+			return cache
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ifNoneMatch := []string{}
+			InjectHttpClient(&http.Client{
+				Transport: MockRoundTripper(func(r *http.Request) *http.Response {
+					ifNoneMatch = append(ifNoneMatch, r.Header.Get("If-None-Match"))
+					if r.Header.Get("If-None-Match") == `"abc"` {
+						return newMockResponse(304, nil, "")
+					}
+
+					header := http.Header{}
+					header.Set("ETag", `"abc"`)
+					return newMockResponse(200, header, test_resources.Workflow1)
+				})})
+
+			apiConsumer := WebApi{Cache: newCache()}
+			for i := 0; i < 2; i++ {
+				workflow, err := apiConsumer.Get(context.Background(), getTestingRepo(), "filler")
+				if err != nil {
+					t.Fatalf("error getting workflow: %v", err)
+				}
+				if workflow.Name != "CI" {
+					t.Errorf("error: expected name \"CI\", got: %v", workflow.Name)
+				}
+			}
+
+			if len(ifNoneMatch) != 2 || ifNoneMatch[0] != "" || ifNoneMatch[1] != `"abc"` {
+				t.Errorf("error: expected only the second request to be conditional, got: %v", ifNoneMatch)
+			}
+		})
+	}
+}
+
+func TestCacheIsNotUsedForWrites(t *testing.T) {
+	conditional := false
+	InjectHttpClient(&http.Client{
+		Transport: MockRoundTripper(func(r *http.Request) *http.Response {
+			conditional = conditional || r.Header.Get("If-None-Match") != ""
+			header := http.Header{}
+			header.Set("ETag", `"abc"`)
+			return newMockResponse(204, header, "")
+		})})
+
+	apiConsumer := WebApi{Cache: NewMemoryCache()}
+	for i := 0; i < 2; i++ {
+		if _, err := apiConsumer.Enable(context.Background(), getTestingRepo(), "filler"); err != nil {
+			t.Fatalf("error enabling workflow: %v", err)
+		}
+	}
+
+	if conditional {
+		t.Errorf("error: expected writes to never be conditional")
+	}
+}
+
+func TestApiBaseUrlHandlesEnterpriseServer(t *testing.T) {
+	for apiUrl, expected := range map[string]string{
+		"":                                    "https://api.github.com",
+		"https://github.com":                  "https://api.github.com",
+		"https://api.github.com/":             "https://api.github.com",
+		"https://github.example.com":          "https://github.example.com/api/v3",
+		"https://github.example.com/":         "https://github.example.com/api/v3",
+		"https://github.example.com/api/v3/":  "https://github.example.com/api/v3",
+		"http://localhost:8080/custom/prefix": "http://localhost:8080/custom/prefix",
+	} {
+		base, err := apiBaseUrl(apiUrl)
+		if err != nil {
+			t.Errorf("error resolving %q: %v", apiUrl, err)
+		}
+		if base != expected {
+			t.Errorf("error: expected %q to resolve to %v, got: %v", apiUrl, expected, base)
+		}
+	}
+
+	if _, err := apiBaseUrl("github.example.com"); err == nil {
+		t.Errorf("error: expected an API URL without scheme to be rejected")
+	}
+}
+
+func TestRequestsUseTheRepoApiUrl(t *testing.T) {
+	var requestedUrl string
+	InjectHttpClient(&http.Client{
+		Transport: MockRoundTripper(func(r *http.Request) *http.Response {
+			requestedUrl = r.URL.String()
+			return newMockResponse(200, nil, test_resources.Workflow1)
+		})})
+
+	repo := getTestingRepo()
+	repo.ApiUrl = "https://github.example.com"
+
+	apiConsumer := WebApi{}
+	if _, err := apiConsumer.Get(context.Background(), repo, "161335"); err != nil {
+		t.Fatalf("error getting workflow: %v", err)
+	}
+
+	expected := "https://github.example.com/api/v3/repos/filler/filler/actions/workflows/161335"
+	if requestedUrl != expected {
+		t.Errorf("error: expected url %v, got: %v", expected, requestedUrl)
+	}
+}
+
+// Blocks every request until its context is done
+type blockingRoundTripper struct{}
+
+func (blockingRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	<-r.Context().Done()
+	return nil, r.Context().Err()
+}
+
+func TestRequestsAreAbortedWhenTheContextIsCancelled(t *testing.T) {
+	InjectHttpClient(&http.Client{Transport: blockingRoundTripper{}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	apiConsumer := WebApi{}
+	_, err := apiConsumer.List(ctx, getTestingRepo())
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error: expected the request to be cancelled, got: %v", err)
+	}
+}
+
+func TestRequestsTimeOut(t *testing.T) {
+	InjectHttpClient(&http.Client{Transport: blockingRoundTripper{}})
+
+	apiConsumer := WebApi{Timeout: 10 * time.Millisecond}
+	_, err := apiConsumer.Get(context.Background(), getTestingRepo(), "filler")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error: expected the request to time out, got: %v", err)
+	}
+}
+
+func TestRetriesStopWhenTheContextIsCancelled(t *testing.T) {
+	InjectHttpClient(&http.Client{
+		Transport: MockRoundTripper(func(r *http.Request) *http.Response {
+			return newMockResponse(503, nil, "")
+		})})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	retryBaseDelay = 10 * time.Second
+	t.Cleanup(func() { retryBaseDelay = time.Second })
+
+	go cancel()
+
+	apiConsumer := WebApi{}
+	_, err := apiConsumer.Get(ctx, getTestingRepo(), "filler")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error: expected the retry to be cancelled, got: %v", err)
+	}
+}
+
+func TestListJobsCanGetListOfJobs(t *testing.T) {
+	responseInterface, err := executeWithSetup(t, test_resources.JobListResponse, func(apiConsumer WebApi, repo appconfig.Repo) (interface{}, error) {
+		return apiConsumer.ListJobs(context.Background(), repo, "29679449")
+	})
+	if err != nil {
+		t.Errorf("error getting list of jobs: %v", err)
+	}
+
+	jobs := responseInterface.([]response.Job)
+	if len(jobs) != 1 || jobs[0].Name != "build" {
+		t.Errorf("error: expected a single job named \"build\", got: %+v", jobs)
+	}
+}
+
+func TestListAttemptJobsRequestsTheAttempt(t *testing.T) {
+	var requestedUrl string
+	InjectHttpClient(&http.Client{
+		Transport: MockRoundTripper(func(r *http.Request) *http.Response {
+			requestedUrl = r.URL.String()
+			return newMockResponse(200, nil, test_resources.JobListResponse)
+		})})
+
+	apiConsumer := WebApi{}
+	jobs, err := apiConsumer.ListAttemptJobs(context.Background(), getTestingRepo(), "29679449", 2)
+	if err != nil {
+		t.Fatalf("error getting list of jobs: %v", err)
+	}
+
+	expected := "https://api.github.com/repos/filler/filler/actions/runs/29679449/attempts/2/jobs?per_page=100"
+	if requestedUrl != expected {
+		t.Errorf("error: expected url %v, got: %v", expected, requestedUrl)
+	}
+	if len(jobs) != 1 || len(jobs[0].Steps) != 2 {
+		t.Errorf("error: expected a single job with 2 steps, got: %+v", jobs)
+	}
+}
+
+func TestGetContentDecodesTheFileAtTheRef(t *testing.T) {
+	var requestedUrl string
+	InjectHttpClient(&http.Client{
+		Transport: MockRoundTripper(func(r *http.Request) *http.Response {
+			requestedUrl = r.URL.String()
+			return newMockResponse(200, nil, test_resources.WorkflowFileContent)
+		})})
+
+	apiConsumer := WebApi{}
+	content, err := apiConsumer.GetContent(context.Background(), getTestingRepo(), ".github/workflows/blank.yaml", "f83a356")
+	if err != nil {
+		t.Fatalf("error getting content: %v", err)
+	}
+
+	expected := "https://api.github.com/repos/filler/filler/contents/.github/workflows/blank.yaml?ref=f83a356"
+	if requestedUrl != expected {
+		t.Errorf("error: expected url %v, got: %v", expected, requestedUrl)
+	}
+	if content != test_resources.WorkflowFile {
+		t.Errorf("error: expected the decoded workflow file, got: %v", content)
+	}
+}
+
+func TestCancelAndForceCancelPostToTheRun(t *testing.T) {
+	var requests []string
+	InjectHttpClient(&http.Client{
+		Transport: MockRoundTripper(func(r *http.Request) *http.Response {
+			requests = append(requests, r.Method+" "+r.URL.String())
+			return newMockResponse(202, nil, "{}")
+		})})
+
+	apiConsumer := WebApi{}
+	cancelResponse, err := apiConsumer.CancelRun(context.Background(), getTestingRepo(), "30433642")
+	if err != nil {
+		t.Fatalf("error cancelling run: %v", err)
+	}
+	if cancelResponse.Status != 202 {
+		t.Errorf("error: expected status 202, got: %v", cancelResponse.Status)
+	}
+	if _, err := apiConsumer.ForceCancelRun(context.Background(), getTestingRepo(), "30433642"); err != nil {
+		t.Fatalf("error force cancelling run: %v", err)
+	}
+
+	expected := []string{
+		"POST https://api.github.com/repos/filler/filler/actions/runs/30433642/cancel",
+		"POST https://api.github.com/repos/filler/filler/actions/runs/30433642/force-cancel",
+	}
+	if strings.Join(requests, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("error: expected requests %v, got: %v", expected, requests)
+	}
+}
+
+func TestRerunsSendTheDebugLoggingToggle(t *testing.T) {
+	var requests []string
+	InjectHttpClient(&http.Client{
+		Transport: MockRoundTripper(func(r *http.Request) *http.Response {
+			body, _ := io.ReadAll(r.Body)
+			requests = append(requests, r.URL.Path+" "+string(body))
+			return newMockResponse(201, nil, "{}")
+		})})
+
+	apiConsumer := WebApi{}
+	repo := getTestingRepo()
+	debug := request.Rerun{EnableDebugLogging: true}
+	if _, err := apiConsumer.RerunRun(context.Background(), repo, "30433642", debug); err != nil {
+		t.Fatalf("error re-running run: %v", err)
+	}
+	if _, err := apiConsumer.RerunFailedJobs(context.Background(), repo, "30433642", request.Rerun{}); err != nil {
+		t.Fatalf("error re-running failed jobs: %v", err)
+	}
+	if _, err := apiConsumer.RerunJob(context.Background(), repo, "399444496", debug); err != nil {
+		t.Fatalf("error re-running job: %v", err)
+	}
+
+	expected := []string{
+		`/repos/filler/filler/actions/runs/30433642/rerun {"enable_debug_logging":true}`,
+		`/repos/filler/filler/actions/runs/30433642/rerun-failed-jobs {"enable_debug_logging":false}`,
+		`/repos/filler/filler/actions/jobs/399444496/rerun {"enable_debug_logging":true}`,
+	}
+	if strings.Join(requests, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("error: expected requests %v, got: %v", expected, requests)
+	}
+}
+
+func TestCancelSurfacesConflicts(t *testing.T) {
+	_, err := executeWithStatus(t, 409, `{"message":"Cannot cancel a workflow run that is completed."}`, func(apiConsumer WebApi, repo appconfig.Repo) (interface{}, error) {
+		return apiConsumer.CancelRun(context.Background(), repo, "30433642")
+	})
+
+	var apiError *response.ApiError
+	if !errors.As(err, &apiError) || apiError.StatusCode != 409 {
+		t.Fatalf("error: expected a 409 API error, got: %v", err)
+	}
+}
+
+func TestCurrentUserAsksForTheTokenOwner(t *testing.T) {
+	var requestedUrl string
+	InjectHttpClient(&http.Client{
+		Transport: MockRoundTripper(func(r *http.Request) *http.Response {
+			requestedUrl = r.URL.String()
+			return newMockResponse(200, http.Header{"X-Oauth-Scopes": {"repo, workflow"}}, `{"login":"octocat","id":1,"type":"User","name":"The Octocat"}`)
+		})})
+
+	apiConsumer := WebApi{}
+	user, err := apiConsumer.CurrentUser(context.Background(), getTestingRepo())
+	if err != nil {
+		t.Fatalf("error getting user: %v", err)
+	}
+
+	if requestedUrl != "https://api.github.com/user" {
+		t.Errorf("error: expected url https://api.github.com/user, got: %v", requestedUrl)
+	}
+	if user.Login != "octocat" {
+		t.Errorf("error: expected login \"octocat\", got: %v", user.Login)
+	}
+	if len(user.Scopes) != 2 || user.Scopes[1] != "workflow" {
+		t.Errorf("error: expected the scopes repo and workflow, got: %v", user.Scopes)
+	}
+}
+
+func TestListReposFallsBackToTheReposOfTheTokenOwner(t *testing.T) {
+	requestedUrls := []string{}
+	InjectHttpClient(&http.Client{
+		Transport: MockRoundTripper(func(r *http.Request) *http.Response {
+			requestedUrls = append(requestedUrls, r.URL.String())
+			switch r.URL.Path {
+			case "/user":
+				return newMockResponse(200, nil, `{"login":"Filler"}`)
+			case "/user/repos":
+				return newMockResponse(200, nil, `[{"name":"private-repo","owner":{"login":"filler"},"private":true,"topics":["ci"]}]`)
+			default:
+				return newMockResponse(404, nil, `{"message":"Not Found"}`)
+			}
+		})})
+
+	apiConsumer := WebApi{}
+	repos, err := apiConsumer.ListRepos(context.Background(), getTestingRepo())
+	if err != nil {
+		t.Fatalf("error listing repos: %v", err)
+	}
+
+	if len(requestedUrls) != 3 || requestedUrls[2] != "https://api.github.com/user/repos?affiliation=owner&per_page=100" {
+		t.Errorf("error: expected the org, the user and then the repos of the user to be requested, got: %v", requestedUrls)
+	}
+	if len(repos) != 1 || repos[0].Name != "private-repo" || repos[0].Topics[0] != "ci" {
+		t.Errorf("error: expected the private repo, got: %+v", repos)
+	}
+}
+
+func TestActionsEnabledAssumesEnabledWithoutPermission(t *testing.T) {
+	enabled, err := executeWithStatus(t, 403, `{"message":"Resource not accessible by personal access token"}`, func(apiConsumer WebApi, repo appconfig.Repo) (interface{}, error) {
+		return apiConsumer.ActionsEnabled(context.Background(), repo)
+	})
+	if err != nil || enabled != true {
+		t.Errorf("error: expected Actions to be assumed enabled, got: %v, %v", enabled, err)
+	}
+
+	enabled, err = executeWithSetup(t, `{"enabled":false}`, func(apiConsumer WebApi, repo appconfig.Repo) (interface{}, error) {
+		return apiConsumer.ActionsEnabled(context.Background(), repo)
+	})
+	if err != nil || enabled != false {
+		t.Errorf("error: expected Actions to be disabled, got: %v, %v", enabled, err)
+	}
+}
+
+func TestJobLogsReturnsThePlainTextLog(t *testing.T) {
+	responseInterface, err := executeWithSetup(t, test_resources.JobLog, func(apiConsumer WebApi, repo appconfig.Repo) (interface{}, error) {
+		return apiConsumer.JobLogs(context.Background(), repo, "399444496")
+	})
+	if err != nil {
+		t.Errorf("error getting job logs: %v", err)
+	}
+
+	if responseInterface.(string) != test_resources.JobLog {
+		t.Errorf("error: expected the log to be returned untouched, got: %v", responseInterface)
+	}
+}
+
+func TestRunLogsUnpacksTheArchive(t *testing.T) {
+	archive := bytes.Buffer{}
+	writer := zip.NewWriter(&archive)
+	for name, contents := range map[string]string{
+		"0_build.txt":              test_resources.JobLog,
+		"build/1_Set up job.txt":   "Preparing",
+		"build/2_Run checkout.txt": "Syncing",
+	} {
+		file, _ := writer.Create(name)
+		file.Write([]byte(contents))
+	}
+	writer.Close()
+
+	responseInterface, err := executeWithSetup(t, archive.String(), func(apiConsumer WebApi, repo appconfig.Repo) (interface{}, error) {
+		return apiConsumer.RunLogs(context.Background(), repo, "29679449")
+	})
+	if err != nil {
+		t.Fatalf("error getting run logs: %v", err)
+	}
+
+	logFiles := responseInterface.([]response.LogFile)
+	if len(logFiles) != 3 {
+		t.Fatalf("error: expected 3 log files, got: %v", len(logFiles))
+	}
+	for _, logFile := range logFiles {
+		if logFile.Name == "0_build.txt" && logFile.Content != test_resources.JobLog {
+			t.Errorf("error: unexpected contents of the job log: %v", logFile.Content)
+		}
+	}
+}
+
 func executeWithSetup(t *testing.T, requestResponse string, f func(apiConsumer WebApi, repo appconfig.Repo) (interface{}, error)) (interface{}, error) {
 	teardown := SetupSuite(t, requestResponse)
 	defer teardown(t)
@@ -117,6 +973,13 @@ func executeWithSetup(t *testing.T, requestResponse string, f func(apiConsumer W
 	return f(WebApi{}, getTestingRepo())
 }
 
+func executeWithStatus(t *testing.T, statusCode int, requestResponse string, f func(apiConsumer WebApi, repo appconfig.Repo) (interface{}, error)) (interface{}, error) {
+	teardown := SetupSuiteWithStatus(t, statusCode, requestResponse)
+	defer teardown(t)
+
+	return f(WebApi{}, getTestingRepo())
+}
+
 func getTestingRepo() appconfig.Repo {
 	return appconfig.Repo{
 		Token: "filler",
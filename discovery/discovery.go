@@ -0,0 +1,133 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/andreaswachs/lazyworkflows/appconfig"
+	"github.com/andreaswachs/lazyworkflows/consumer"
+	"github.com/andreaswachs/lazyworkflows/pool"
+)
+
+// How many repos are checked for Actions being enabled at the same time
+const concurrency = 8
+
+// Split separates the repos configured by name from the patterns standing for several repos
+func Split(repos []appconfig.Repo) ([]appconfig.Repo, []appconfig.Repo) {
+	named, patterns := []appconfig.Repo{}, []appconfig.Repo{}
+	for _, repo := range repos {
+		if repo.IsPattern() {
+			patterns = append(patterns, repo)
+		} else {
+			named = append(named, repo)
+		}
+	}
+	return named, patterns
+}
+
+// Resolve returns the repos configured by name followed by the repos their patterns pick.
+// Repos configured by name win over the same repo found through a pattern
+func Resolve(ctx context.Context, api consumer.Consumer, repos []appconfig.Repo) ([]appconfig.Repo, error) {
+	named, patterns := Split(repos)
+	if len(patterns) == 0 {
+		return named, nil
+	}
+
+	found, err := Expand(ctx, api, patterns)
+
+	seen := map[string]bool{}
+	for _, repo := range named {
+		seen[Key(repo)] = true
+	}
+	for _, repo := range found {
+		if !seen[Key(repo)] {
+			named = append(named, repo)
+		}
+	}
+
+	return named, err
+}
+
+// Expand lists the repos of the owner of every pattern and returns those it picks, skipping repos
+// with Actions disabled. A failing pattern doesn't stop the others, whose repos are still returned
+// along with the first error
+func Expand(ctx context.Context, api consumer.Consumer, patterns []appconfig.Repo) ([]appconfig.Repo, error) {
+	candidates := []appconfig.Repo{}
+	seen := map[string]bool{}
+	var firstErr error
+
+	for _, pattern := range patterns {
+		repos, err := api.ListRepos(ctx, pattern)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("could not discover %s/%s: %w", pattern.Owner, pattern.Repo, err)
+			}
+			continue
+		}
+
+		sort.Slice(repos, func(i, j int) bool {
+			return strings.ToLower(repos[i].Name) < strings.ToLower(repos[j].Name)
+		})
+
+		for _, repo := range repos {
+			if !pattern.Matches(repo.Name, repo.Topics) {
+				continue
+			}
+
+			candidate := pattern.ForName(repo.Name)
+			if repo.Owner.Login != "" {
+				candidate.Owner = repo.Owner.Login
+			}
+			if !seen[Key(candidate)] {
+				seen[Key(candidate)] = true
+				candidates = append(candidates, candidate)
+			}
+		}
+	}
+
+	enabled, err := withActions(ctx, api, candidates)
+	if firstErr == nil {
+		firstErr = err
+	}
+	return enabled, firstErr
+}
+
+// Key identifies a repo regardless of the casing it was configured with
+func Key(repo appconfig.Repo) string {
+	return strings.ToLower(repo.Owner + "/" + repo.Repo)
+}
+
+// Keeps the repos that have Actions enabled, checking several of them at the same time
+func withActions(ctx context.Context, api consumer.Consumer, repos []appconfig.Repo) ([]appconfig.Repo, error) {
+	enabled := make([]bool, len(repos))
+	errs := make([]error, len(repos))
+	checks := pool.New(concurrency)
+	wg := sync.WaitGroup{}
+
+	for i, repo := range repos {
+		wg.Add(1)
+		go func(i int, repo appconfig.Repo) {
+			defer wg.Done()
+			if err := checks.Run(ctx, func() { enabled[i], errs[i] = api.ActionsEnabled(ctx, repo) }); err != nil {
+				errs[i] = err
+			}
+		}(i, repo)
+	}
+	wg.Wait()
+
+	kept := []appconfig.Repo{}
+	var firstErr error
+	for i, repo := range repos {
+		if errs[i] != nil && firstErr == nil {
+			firstErr = fmt.Errorf("could not check Actions of %s/%s: %w", repo.Owner, repo.Repo, errs[i])
+		}
+		if enabled[i] {
+			kept = append(kept, repo)
+		}
+	}
+
+	return kept, firstErr
+}
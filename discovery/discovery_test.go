@@ -0,0 +1,75 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/andreaswachs/lazyworkflows/appconfig"
+	"github.com/andreaswachs/lazyworkflows/consumer"
+	"github.com/andreaswachs/lazyworkflows/model/response"
+)
+
+// A consumer that serves canned repos. Calls that aren't overridden panic through the nil interface
+type fakeConsumer struct {
+	consumer.Consumer
+	repos    map[string][]response.Repository
+	disabled map[string]bool
+}
+
+func (f *fakeConsumer) ListRepos(ctx context.Context, repo appconfig.Repo) ([]response.Repository, error) {
+	repos, ok := f.repos[repo.Owner]
+	if !ok {
+		return nil, fmt.Errorf("owner %s not found", repo.Owner)
+	}
+	return repos, nil
+}
+
+func (f *fakeConsumer) ActionsEnabled(ctx context.Context, repo appconfig.Repo) (bool, error) {
+	return !f.disabled[repo.Repo], nil
+}
+
+func TestExpandFiltersByGlobsTopicsAndActions(t *testing.T) {
+	api := &fakeConsumer{
+		repos: map[string][]response.Repository{"octo": {
+			{Name: "service-b", Topics: []string{"backend"}},
+			{Name: "service-a", Topics: []string{"Backend"}},
+			{Name: "service-legacy", Topics: []string{"backend"}},
+			{Name: "service-off", Topics: []string{"backend"}},
+			{Name: "website", Topics: []string{"backend"}},
+			{Name: "service-c"},
+		}},
+		disabled: map[string]bool{"service-off": true},
+	}
+	pattern := appconfig.Repo{Owner: "octo", Repo: "*", Token: "secret", Include: []string{"service-*"}, Exclude: []string{"*-legacy"}, Topics: []string{"backend"}}
+
+	repos, err := Expand(context.Background(), api, []appconfig.Repo{pattern})
+	if err != nil {
+		t.Fatalf("Expected the pattern to expand, but got %v", err)
+	}
+
+	if len(repos) != 2 || repos[0].Repo != "service-a" || repos[1].Repo != "service-b" {
+		t.Fatalf("Expected service-a and service-b, but got %+v", repos)
+	}
+	if repos[0].Token != "secret" || repos[0].Include != nil {
+		t.Errorf("Expected the repo to be set up like its pattern, but got %+v", repos[0])
+	}
+}
+
+func TestResolveKeepsNamedReposAndReportsFailingPatterns(t *testing.T) {
+	api := &fakeConsumer{repos: map[string][]response.Repository{"octo": {{Name: "one"}, {Name: "two"}}}}
+	configured := []appconfig.Repo{
+		{Owner: "octo", Repo: "one", Token: "own"},
+		{Owner: "octo", Repo: "*"},
+		{Owner: "missing", Repo: "*"},
+	}
+
+	repos, err := Resolve(context.Background(), api, configured)
+	if err == nil {
+		t.Errorf("Expected the missing owner to be reported")
+	}
+
+	if len(repos) != 2 || repos[0].Token != "own" || repos[1].Repo != "two" {
+		t.Errorf("Expected the named repo followed by the discovered one, but got %+v", repos)
+	}
+}
@@ -1,27 +1,54 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	appConfig "github.com/andreaswachs/lazyworkflows/appconfig"
+	"github.com/andreaswachs/lazyworkflows/cli"
+	"github.com/andreaswachs/lazyworkflows/meta"
 	"github.com/andreaswachs/lazyworkflows/tui"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func main() {
+	args, err := cli.GlobalFlags(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", meta.AppName, err)
+		os.Exit(cli.ExitUsage)
+	}
+
+	// Any other arguments select a subcommand, meant for scripts rather than people
+	if len(args) > 0 {
+		os.Exit(cli.Run(args))
+	}
+
 	config := appConfig.New()
 
-	err := config.Load()
+	err = config.Load()
+	if errors.Is(err, appConfig.ErrNoConfig) {
+		// The first run sets up the config, after which the UI starts with it
+		written, wizardErr := tui.RunWizard(appConfig.Path())
+		if wizardErr != nil {
+			fmt.Fprintf(os.Stderr, "Could not write config file: %v\n", wizardErr)
+			os.Exit(cli.ExitFailure)
+		}
+		if !written {
+			fmt.Printf("No config was written. Run %s again to set it up.\n", meta.AppName)
+			os.Exit(cli.ExitOk)
+		}
+		err = config.Load()
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Could not load config file. See error msg.")
-		os.Exit(0)
+		fmt.Fprintf(os.Stderr, "Could not load config file. See error msg.\n")
+		os.Exit(cli.ExitFailure)
 	}
 
 	p := tea.NewProgram(tui.InitialModel(*config), tea.WithAltScreen())
 	if _, err := p.Run(); err != nil {
-		fmt.Fprintf(os.Stderr, "Could not start program. See error msg.")
-		os.Exit(0)
+		fmt.Fprintf(os.Stderr, "Could not start program: %v\n", err)
+		os.Exit(cli.ExitFailure)
 	}
 
 }
@@ -0,0 +1,226 @@
+package definition
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Definition is the part of a workflow file that the API doesn't tell us about
+type Definition struct {
+	Name string
+	On   Triggers
+	Jobs map[string]Job
+}
+
+// Triggers are the events a workflow runs on. Only the inputs of workflow_dispatch are kept
+type Triggers struct {
+	Inputs Inputs
+}
+
+// Inputs are the inputs a workflow takes when it is dispatched, by their name
+type Inputs map[string]Input
+
+// Input is a single input of a dispatched workflow
+type Input struct {
+	Description string
+	// boolean, number, string, choice or environment. Inputs without a type are strings
+	Type     string
+	Required bool
+	Default  string
+	// The values a choice input may take
+	Options []string
+}
+
+// Job is a job as declared in a workflow file, keyed by its id in Definition.Jobs
+type Job struct {
+	Name  string
+	Needs Needs
+}
+
+// Needs lists the jobs that must complete before a job runs.
+// A workflow file may declare it as a single job id or as a list of them
+type Needs []string
+
+func (n *Needs) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*n = Needs{node.Value}
+		return nil
+	}
+
+	var needs []string
+	if err := node.Decode(&needs); err != nil {
+		return err
+	}
+	*n = needs
+	return nil
+}
+
+func (t *Triggers) UnmarshalYAML(node *yaml.Node) error {
+	// A workflow triggered by a single event or a list of them can't be dispatched with inputs
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var events struct {
+		WorkflowDispatch struct {
+			Inputs Inputs
+		} `yaml:"workflow_dispatch"`
+	}
+	if err := node.Decode(&events); err != nil {
+		return err
+	}
+	t.Inputs = events.WorkflowDispatch.Inputs
+	return nil
+}
+
+// Type converts the values given for the inputs to the types the workflow declares for them,
+// which is how the API expects them. Inputs the workflow doesn't declare are rejected
+func (i Inputs) Type(values map[string]string) (map[string]interface{}, error) {
+	typed := make(map[string]interface{}, len(values))
+
+	for name, value := range values {
+		input, ok := i[name]
+		if !ok {
+			return nil, fmt.Errorf("the workflow has no input %q", name)
+		}
+
+		switch input.Type {
+		case "boolean":
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("input %q must be true or false, but is %q", name, value)
+			}
+			typed[name] = parsed
+		case "number":
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+				return nil, fmt.Errorf("input %q must be a number, but is %q", name, value)
+			}
+			typed[name] = parsed
+		case "choice":
+			if !contains(input.Options, value) {
+				return nil, fmt.Errorf("input %q must be one of %s, but is %q", name, strings.Join(input.Options, ", "), value)
+			}
+			typed[name] = value
+		default:
+			typed[name] = value
+		}
+	}
+
+	return typed, nil
+}
+
+// Parse reads the jobs and their dependencies from the contents of a workflow file
+func Parse(content string) (Definition, error) {
+	definition := Definition{}
+	if err := yaml.Unmarshal([]byte(content), &definition); err != nil {
+		return Definition{}, fmt.Errorf("could not parse workflow file: %w", err)
+	}
+
+	if definition.Jobs == nil {
+		definition.Jobs = make(map[string]Job)
+	}
+
+	return definition, nil
+}
+
+// Levels orders the jobs by their dependencies. Every job is in the level after the last of the jobs it needs,
+// so the jobs of a level can run side by side. Jobs within a level are sorted by id.
+// Jobs that are part of a cycle or need a job that doesn't exist end up in a level of their own at the end
+func (d Definition) Levels() [][]string {
+	level := make(map[string]int, len(d.Jobs))
+	remaining := d.jobIds()
+	levels := [][]string{}
+
+	for len(remaining) > 0 {
+		current := []string{}
+		for _, id := range remaining {
+			if d.ready(id, level) {
+				current = append(current, id)
+			}
+		}
+
+		if len(current) == 0 {
+			return append(levels, remaining)
+		}
+
+		for _, id := range current {
+			level[id] = len(levels)
+		}
+		levels = append(levels, current)
+		remaining = without(remaining, level)
+	}
+
+	return levels
+}
+
+// JobId finds the id of the job a job reported by the API was started from.
+// The API only knows the display name, which has the matrix values appended for matrix jobs
+// and the name of the called workflow's job for reusable workflows
+func (d Definition) JobId(name string) (string, bool) {
+	for _, id := range d.jobIds() {
+		if name == d.displayName(id) {
+			return id, true
+		}
+	}
+
+	for _, id := range d.jobIds() {
+		display := d.displayName(id)
+		if strings.HasPrefix(name, display+" (") || strings.HasPrefix(name, display+" / ") {
+			return id, true
+		}
+	}
+
+	return "", false
+}
+
+// The name the API reports for a job, before any matrix values are appended
+func (d Definition) displayName(id string) string {
+	if d.Jobs[id].Name != "" {
+		return d.Jobs[id].Name
+	}
+	return id
+}
+
+// Whether every job the given job needs is placed in an earlier level
+func (d Definition) ready(id string, level map[string]int) bool {
+	for _, need := range d.Jobs[id].Needs {
+		if _, ok := level[need]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (d Definition) jobIds() []string {
+	ids := make([]string, 0, len(d.Jobs))
+	for id := range d.Jobs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func without(ids []string, placed map[string]int) []string {
+	remaining := []string{}
+	for _, id := range ids {
+		if _, ok := placed[id]; !ok {
+			remaining = append(remaining, id)
+		}
+	}
+	return remaining
+}
+
+func contains(values []string, wanted string) bool {
+	for _, value := range values {
+		if value == wanted {
+			return true
+		}
+	}
+	return false
+}
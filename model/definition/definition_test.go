@@ -0,0 +1,145 @@
+package definition
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/andreaswachs/lazyworkflows/test_resources"
+)
+
+func TestParseReadsNeedsInBothForms(t *testing.T) {
+	definition, err := Parse(test_resources.WorkflowFile)
+	if err != nil {
+		t.Fatalf("Expected the workflow file to parse, but got %v", err)
+	}
+
+	if !reflect.DeepEqual(definition.Jobs["test"].Needs, Needs{"build"}) {
+		t.Fatalf("Expected test to need build, but got %v", definition.Jobs["test"].Needs)
+	}
+	if !reflect.DeepEqual(definition.Jobs["deploy"].Needs, Needs{"build", "test"}) {
+		t.Fatalf("Expected deploy to need build and test, but got %v", definition.Jobs["deploy"].Needs)
+	}
+}
+
+func TestLevelsFollowNeeds(t *testing.T) {
+	definition := Definition{Jobs: map[string]Job{
+		"lint":   {},
+		"build":  {},
+		"test":   {Needs: Needs{"build"}},
+		"deploy": {Needs: Needs{"test", "lint"}},
+	}}
+
+	expected := [][]string{{"build", "lint"}, {"test"}, {"deploy"}}
+	if levels := definition.Levels(); !reflect.DeepEqual(levels, expected) {
+		t.Fatalf("Expected levels to be %v, but got %v", expected, levels)
+	}
+}
+
+func TestLevelsPutUnresolvableJobsLast(t *testing.T) {
+	definition := Definition{Jobs: map[string]Job{
+		"build": {},
+		"a":     {Needs: Needs{"b"}},
+		"b":     {Needs: Needs{"a"}},
+		"c":     {Needs: Needs{"missing"}},
+	}}
+
+	expected := [][]string{{"build"}, {"a", "b", "c"}}
+	if levels := definition.Levels(); !reflect.DeepEqual(levels, expected) {
+		t.Fatalf("Expected levels to be %v, but got %v", expected, levels)
+	}
+}
+
+func TestJobIdMatchesDisplayNames(t *testing.T) {
+	definition, _ := Parse(test_resources.WorkflowFile)
+
+	cases := map[string]string{
+		"build":                     "build",
+		"Unit tests":                "test",
+		"Unit tests (ubuntu, 1.19)": "test",
+		"deploy / publish":          "deploy",
+	}
+	for name, expected := range cases {
+		if id, ok := definition.JobId(name); !ok || id != expected {
+			t.Fatalf("Expected %v to match job %v, but got %v", name, expected, id)
+		}
+	}
+
+	if id, ok := definition.JobId("unknown"); ok {
+		t.Fatalf("Expected no job to match, but got %v", id)
+	}
+}
+
+func TestParseReadsTheDispatchInputs(t *testing.T) {
+	content := `on:
+  push:
+  workflow_dispatch:
+    inputs:
+      environment:
+        type: choice
+        options: [staging, production]
+      dry_run:
+        type: boolean
+        default: false
+      replicas:
+        type: number
+      tags:
+        description: Comma separated
+`
+	definition, err := Parse(content)
+	if err != nil {
+		t.Fatalf("Expected the workflow file to parse, but got %v", err)
+	}
+
+	expected := Inputs{
+		"environment": {Type: "choice", Options: []string{"staging", "production"}},
+		"dry_run":     {Type: "boolean", Default: "false"},
+		"replicas":    {Type: "number"},
+		"tags":        {Description: "Comma separated"},
+	}
+	if !reflect.DeepEqual(definition.On.Inputs, expected) {
+		t.Errorf("Expected the inputs %+v, but got %+v", expected, definition.On.Inputs)
+	}
+
+	for _, content := range []string{test_resources.WorkflowFile, "on: [push, workflow_dispatch]\njobs: {}\n"} {
+		if definition, err := Parse(content); err != nil || len(definition.On.Inputs) != 0 {
+			t.Errorf("Expected no inputs, but got %+v %v", definition.On.Inputs, err)
+		}
+	}
+}
+
+func TestTypeConvertsValuesToTheDeclaredTypes(t *testing.T) {
+	inputs := Inputs{
+		"environment": {Type: "choice", Options: []string{"staging", "true"}},
+		"dry_run":     {Type: "boolean"},
+		"replicas":    {Type: "number"},
+		"tags":        {},
+	}
+
+	tests := []struct {
+		values   map[string]string
+		expected map[string]interface{}
+		valid    bool
+	}{
+		{
+			values:   map[string]string{"environment": "true", "dry_run": "true", "replicas": "3", "tags": "a,b"},
+			expected: map[string]interface{}{"environment": "true", "dry_run": true, "replicas": 3.0, "tags": "a,b"},
+			valid:    true,
+		},
+		{values: map[string]string{"tags": "false"}, expected: map[string]interface{}{"tags": "false"}, valid: true},
+		{values: map[string]string{"dry_run": "yes"}},
+		{values: map[string]string{"replicas": "three"}},
+		{values: map[string]string{"replicas": "NaN"}},
+		{values: map[string]string{"environment": "production"}},
+		{values: map[string]string{"unknown": "value"}},
+	}
+
+	for _, test := range tests {
+		typed, err := inputs.Type(test.values)
+		if (err == nil) != test.valid {
+			t.Errorf("Expected %v to be valid: %v, but got %v", test.values, test.valid, err)
+		}
+		if test.valid && !reflect.DeepEqual(typed, test.expected) {
+			t.Errorf("Expected %v to be typed as %#v, but got %#v", test.values, test.expected, typed)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package request
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Dispatch is the body sent when triggering a workflow_dispatch event.
+// Input values may be strings, booleans or numbers, matching the input types of the workflow
+type Dispatch struct {
+	Ref    string                 `json:"ref"`
+	Inputs map[string]interface{} `json:"inputs,omitempty"`
+}
+
+// Rerun is the body sent when re-running a run or some of its jobs
+type Rerun struct {
+	EnableDebugLogging bool `json:"enable_debug_logging"`
+}
+
+// ParseInputs reads dispatch inputs given as one key=value pair each. Blank pairs are skipped.
+// Values are only trimmed, commas and all, and are typed later by what the workflow declares
+func ParseInputs(pairs []string) (map[string]string, error) {
+	inputs := make(map[string]string)
+
+	for _, pair := range pairs {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		key, value, found := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("input %q is not of the form key=value", strings.TrimSpace(pair))
+		}
+
+		inputs[key] = strings.TrimSpace(value)
+	}
+
+	return inputs, nil
+}
+
+// RunFilter narrows down the workflow runs returned by the run listing endpoints.
+// Empty fields are not sent to the API
+type RunFilter struct {
+	Branch        string
+	Event         string
+	Status        string
+	Actor         string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// Limit caps the number of runs fetched across all pages. Zero fetches every run
+	Limit int
+}
+
+// Query returns the filter as query parameters understood by the GitHub API
+func (f RunFilter) Query() url.Values {
+	query := url.Values{}
+
+	if f.Branch != "" {
+		query.Set("branch", f.Branch)
+	}
+	if f.Event != "" {
+		query.Set("event", f.Event)
+	}
+	if f.Status != "" {
+		query.Set("status", f.Status)
+	}
+	if f.Actor != "" {
+		query.Set("actor", f.Actor)
+	}
+	if created := f.created(); created != "" {
+		query.Set("created", created)
+	}
+
+	return query
+}
+
+// Formats the created range using the GitHub search syntax
+func (f RunFilter) created() string {
+	switch {
+	case !f.CreatedAfter.IsZero() && !f.CreatedBefore.IsZero():
+		return f.CreatedAfter.Format(time.RFC3339) + ".." + f.CreatedBefore.Format(time.RFC3339)
+	case !f.CreatedAfter.IsZero():
+		return ">=" + f.CreatedAfter.Format(time.RFC3339)
+	case !f.CreatedBefore.IsZero():
+		return "<=" + f.CreatedBefore.Format(time.RFC3339)
+	default:
+		return ""
+	}
+}
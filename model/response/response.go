@@ -1,20 +1,24 @@
 package response
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
 )
 
 type Workflow struct {
-	Id        json.Number
-	NodeId    string `json:"node_id"`
-	Name      string
-	Path      string
-	State     string
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
-	Url       string
-	HtmlUrl   string `json:"html_url"`
-	BadgeUrl  string `json:"badge_url"`
+	Id        json.Number `json:"id"`
+	NodeId    string      `json:"node_id"`
+	Name      string      `json:"name"`
+	Path      string      `json:"path"`
+	State     string      `json:"state"`
+	CreatedAt string      `json:"created_at"`
+	UpdatedAt string      `json:"updated_at"`
+	Url       string      `json:"url"`
+	HtmlUrl   string      `json:"html_url"`
+	BadgeUrl  string      `json:"badge_url"`
 }
 
 type Get struct {
@@ -26,6 +30,150 @@ type List struct {
 	Workflows  []Workflow
 }
 
+type Actor struct {
+	Id      json.Number `json:"id"`
+	Login   string      `json:"login"`
+	Type    string      `json:"type"`
+	HtmlUrl string      `json:"html_url"`
+}
+
+// User is the account a token belongs to
+type User struct {
+	Id    json.Number `json:"id"`
+	Login string      `json:"login"`
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	// The OAuth scopes of a classic token. Fine-grained tokens have none, their permissions are per repo
+	Scopes []string `json:"-"`
+}
+
+type Run struct {
+	Id              json.Number `json:"id"`
+	NodeId          string      `json:"node_id"`
+	Name            string      `json:"name"`
+	DisplayTitle    string      `json:"display_title"`
+	Path            string      `json:"path"`
+	HeadBranch      string      `json:"head_branch"`
+	HeadSha         string      `json:"head_sha"`
+	RunNumber       int         `json:"run_number"`
+	RunAttempt      int         `json:"run_attempt"`
+	Event           string      `json:"event"`
+	Status          string      `json:"status"`
+	Conclusion      string      `json:"conclusion"`
+	WorkflowId      json.Number `json:"workflow_id"`
+	Actor           Actor       `json:"actor"`
+	TriggeringActor Actor       `json:"triggering_actor"`
+	CreatedAt       string      `json:"created_at"`
+	UpdatedAt       string      `json:"updated_at"`
+	RunStartedAt    string      `json:"run_started_at"`
+	Url             string      `json:"url"`
+	HtmlUrl         string      `json:"html_url"`
+	JobsUrl         string      `json:"jobs_url"`
+	LogsUrl         string      `json:"logs_url"`
+	CancelUrl       string      `json:"cancel_url"`
+	RerunUrl        string      `json:"rerun_url"`
+	WorkflowUrl     string      `json:"workflow_url"`
+}
+
+type GetRun struct {
+	Run
+}
+
+type RunList struct {
+	TotalCount   int   `json:"total_count"`
+	WorkflowRuns []Run `json:"workflow_runs"`
+}
+
+type Job struct {
+	Id           json.Number `json:"id"`
+	RunId        json.Number `json:"run_id"`
+	RunAttempt   int         `json:"run_attempt"`
+	HeadSha      string      `json:"head_sha"`
+	WorkflowName string      `json:"workflow_name"`
+	Name         string      `json:"name"`
+	Status       string      `json:"status"`
+	Conclusion   string      `json:"conclusion"`
+	StartedAt    string      `json:"started_at"`
+	CompletedAt  string      `json:"completed_at"`
+	HtmlUrl      string      `json:"html_url"`
+	Labels       []string    `json:"labels"`
+	RunnerName   string      `json:"runner_name"`
+	Steps        []Step      `json:"steps"`
+}
+
+// Step is a single step of a job
+type Step struct {
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	Conclusion  string `json:"conclusion"`
+	Number      int    `json:"number"`
+	StartedAt   string `json:"started_at"`
+	CompletedAt string `json:"completed_at"`
+}
+
+type JobList struct {
+	TotalCount int `json:"total_count"`
+	Jobs       []Job
+}
+
+// Repository is a repo of a user or organization, as returned when listing them
+type Repository struct {
+	Id            json.Number `json:"id"`
+	Name          string      `json:"name"`
+	FullName      string      `json:"full_name"`
+	Owner         Actor       `json:"owner"`
+	Private       bool        `json:"private"`
+	Fork          bool        `json:"fork"`
+	Archived      bool        `json:"archived"`
+	Topics        []string    `json:"topics"`
+	DefaultBranch string      `json:"default_branch"`
+	HtmlUrl       string      `json:"html_url"`
+	// What the token may do in the repo. Only set when the repo is requested on its own
+	Permissions *Permissions `json:"permissions,omitempty"`
+}
+
+// Permissions of the token in a repo
+type Permissions struct {
+	Admin bool `json:"admin"`
+	Push  bool `json:"push"`
+	Pull  bool `json:"pull"`
+}
+
+// ActionsPermissions tells whether GitHub Actions may run in a repo
+type ActionsPermissions struct {
+	Enabled        bool   `json:"enabled"`
+	AllowedActions string `json:"allowed_actions"`
+}
+
+// Content is a file of a repository, as returned by the contents API
+type Content struct {
+	Name     string
+	Path     string
+	Sha      string
+	Encoding string
+	Content  string
+}
+
+// Decode returns the contents of the file, which the API sends base64 encoded
+func (c Content) Decode() (string, error) {
+	if c.Encoding != "base64" {
+		return c.Content, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(c.Content, "\n", ""))
+	if err != nil {
+		return "", fmt.Errorf("could not decode %s: %w", c.Path, err)
+	}
+
+	return string(decoded), nil
+}
+
+// LogFile is a single file of the log archive of a run
+type LogFile struct {
+	Name    string
+	Content string
+}
+
 type Enable struct {
 	Status int
 }
@@ -38,6 +186,79 @@ type Dispatch struct {
 	Status int
 }
 
+type Cancel struct {
+	Status int
+}
+
+type Rerun struct {
+	Status int
+}
+
+// ApiError is returned by the consumer when the API responds with a non-2xx status
+type ApiError struct {
+	StatusCode       int `json:"-"`
+	Message          string
+	DocumentationUrl string `json:"documentation_url"`
+	Errors           []FieldError
+}
+
+// FieldError describes a single problem with the request, as reported by the API
+type FieldError struct {
+	Resource string
+	Field    string
+	Code     string
+	Message  string
+}
+
+func (e *ApiError) Error() string {
+	builder := strings.Builder{}
+	builder.WriteString(fmt.Sprintf("GitHub API responded with %d: %s", e.StatusCode, e.Message))
+
+	for _, fieldError := range e.Errors {
+		builder.WriteString("; ")
+		builder.WriteString(fieldError.String())
+	}
+
+	return builder.String()
+}
+
+func (e FieldError) String() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s.%s is %s", e.Resource, e.Field, strings.ReplaceAll(e.Code, "_", " "))
+}
+
+// Some endpoints report their errors as plain strings rather than objects
+func (e *FieldError) UnmarshalJSON(data []byte) error {
+	var message string
+	if err := json.Unmarshal(data, &message); err == nil {
+		e.Message = message
+		return nil
+	}
+
+	type plain FieldError
+	return json.Unmarshal(data, (*plain)(e))
+}
+
+// RateLimit is the request budget of a token, as reported by the X-RateLimit-* headers
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Used      int
+	Reset     time.Time
+	Resource  string
+}
+
+// RateLimitError is returned without contacting the API when the budget of a token is known to be spent
+type RateLimitError struct {
+	RateLimit RateLimit
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit of %d requests is exhausted until %s", e.RateLimit.Limit, e.RateLimit.Reset.Format(time.Kitchen))
+}
+
 func FromString[T any](response string, out T) error {
 	return json.Unmarshal([]byte(response), &out)
 }
@@ -91,3 +91,106 @@ func TestCanDeserializeWorkflowDisableResponse(t *testing.T) {
 		t.Fatalf("Expected status to be 200, but got %v", responseObj.Status)
 	}
 }
+
+func TestCanDeserializeRunResponse(t *testing.T) {
+	responseText := test_resources.Run1
+
+	var responseObj GetRun
+	FromString(responseText, &responseObj)
+
+	if responseObj.Run.Id != "30433642" {
+		t.Fatalf("Expected run id to be 30433642, but got %v", responseObj.Run.Id)
+	}
+	if responseObj.Run.WorkflowId != "159038" {
+		t.Fatalf("Expected run workflow_id to be 159038, but got %v", responseObj.Run.WorkflowId)
+	}
+	if responseObj.Run.HeadBranch != "master" {
+		t.Fatalf("Expected run head_branch to be master, but got %v", responseObj.Run.HeadBranch)
+	}
+	if responseObj.Run.Status != "completed" {
+		t.Fatalf("Expected run status to be completed, but got %v", responseObj.Run.Status)
+	}
+	if responseObj.Run.Conclusion != "success" {
+		t.Fatalf("Expected run conclusion to be success, but got %v", responseObj.Run.Conclusion)
+	}
+	if responseObj.Run.RunNumber != 562 {
+		t.Fatalf("Expected run run_number to be 562, but got %v", responseObj.Run.RunNumber)
+	}
+	if responseObj.Run.Actor.Login != "octocat" {
+		t.Fatalf("Expected run actor login to be octocat, but got %v", responseObj.Run.Actor.Login)
+	}
+}
+
+func TestCanDeserializeRunListResponse(t *testing.T) {
+	responseText := test_resources.RunListResponse
+
+	var responseObj RunList
+	FromString(responseText, &responseObj)
+
+	if responseObj.TotalCount != 1 {
+		t.Fatalf("Expected total count to be 1, but got %v", responseObj.TotalCount)
+	}
+	if len(responseObj.WorkflowRuns) != 1 {
+		t.Fatalf("Expected 1 run, but got %v", len(responseObj.WorkflowRuns))
+	}
+}
+
+func TestCanDeserializeApiErrorResponse(t *testing.T) {
+	responseText := test_resources.ValidationFailedResponse
+
+	responseObj := ApiError{StatusCode: 422}
+	FromString(responseText, &responseObj)
+
+	if responseObj.Message != "Validation Failed" {
+		t.Fatalf("Expected message to be Validation Failed, but got %v", responseObj.Message)
+	}
+	if len(responseObj.Errors) != 2 {
+		t.Fatalf("Expected 2 errors, but got %v", len(responseObj.Errors))
+	}
+	if responseObj.Errors[0].Field != "ref" || responseObj.Errors[0].Code != "missing_field" {
+		t.Fatalf("Expected the first error to be about a missing ref, but got %v", responseObj.Errors[0])
+	}
+	if responseObj.Errors[1].Message != "Unexpected inputs provided" {
+		t.Fatalf("Expected the second error to be a plain message, but got %v", responseObj.Errors[1])
+	}
+
+	expected := "GitHub API responded with 422: Validation Failed; WorkflowDispatch.ref is missing field; Unexpected inputs provided"
+	if responseObj.Error() != expected {
+		t.Fatalf("Expected error to be %v, but got %v", expected, responseObj.Error())
+	}
+}
+
+func TestCanDeserializeJobWithSteps(t *testing.T) {
+	responseText := test_resources.Job1
+
+	var responseObj Job
+	FromString(responseText, &responseObj)
+
+	if responseObj.RunAttempt != 1 {
+		t.Fatalf("Expected job run_attempt to be 1, but got %v", responseObj.RunAttempt)
+	}
+	if len(responseObj.Steps) != 2 {
+		t.Fatalf("Expected 2 steps, but got %v", len(responseObj.Steps))
+	}
+	if responseObj.Steps[1].Number != 2 || responseObj.Steps[1].Name != "Run actions/checkout@v3" {
+		t.Fatalf("Expected the second step to be the checkout, but got %v", responseObj.Steps[1])
+	}
+	if responseObj.Steps[1].CompletedAt != "2020-01-20T17:44:39Z" {
+		t.Fatalf("Expected step completed_at to be 2020-01-20T17:44:39Z, but got %v", responseObj.Steps[1].CompletedAt)
+	}
+}
+
+func TestCanDecodeContentResponse(t *testing.T) {
+	responseText := test_resources.WorkflowFileContent
+
+	var responseObj Content
+	FromString(responseText, &responseObj)
+
+	decoded, err := responseObj.Decode()
+	if err != nil {
+		t.Fatalf("Expected content to decode, but got %v", err)
+	}
+	if decoded != test_resources.WorkflowFile {
+		t.Fatalf("Expected content to be the workflow file, but got %v", decoded)
+	}
+}
@@ -0,0 +1,788 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andreaswachs/lazyworkflows/appconfig"
+	"github.com/andreaswachs/lazyworkflows/consumer"
+	"github.com/andreaswachs/lazyworkflows/discovery"
+	"github.com/andreaswachs/lazyworkflows/model/definition"
+	"github.com/andreaswachs/lazyworkflows/model/request"
+	"github.com/andreaswachs/lazyworkflows/model/response"
+	"github.com/andreaswachs/lazyworkflows/pool"
+)
+
+// How many events a subscriber may fall behind before events are dropped.
+// Events only signal that something changed, so a dropped event is caught up by the next
+const subscriberBuffer = 64
+
+type Status uint8
+
+const (
+	Loading Status = iota
+	Loaded
+	Failed
+	// The load was aborted through its context and is retried by the next Load
+	Cancelled
+)
+
+type EventKind uint8
+
+const (
+	// A page of the workflows of a repo arrived, or they were loaded, failed to load or changed
+	WorkflowsChanged EventKind = iota
+	// The runs of a workflow were loaded, failed to load or changed
+	RunsChanged
+	// A dispatch, enable or disable was rejected and its optimistic update rolled back
+	MutationFailed
+	// The jobs of a run were loaded or failed to load
+	JobsChanged
+	// Repos were discovered through the patterns of the config, or their discovery failed
+	ReposChanged
+)
+
+// Event tells subscribers which part of the store changed. Query the store for the new state
+type Event struct {
+	Kind       EventKind
+	Repo       appconfig.Repo
+	WorkflowId string
+	RunId      string
+	// The attempt of the run whose jobs changed. Zero is the latest attempt
+	Attempt int
+	Err     error
+}
+
+// RepoState is the loading state and workflows of a single repo
+type RepoState struct {
+	Repo      appconfig.Repo
+	Status    Status
+	Workflows []response.Workflow
+	Err       error
+}
+
+// RunsState is the loading state and recent runs of a single workflow
+type RunsState struct {
+	Status Status
+	Runs   []response.Run
+	Err    error
+}
+
+// JobsState is the loading state and jobs of a single attempt of a run
+type JobsState struct {
+	Status Status
+	Jobs   []response.Job
+	Err    error
+}
+
+// Orchestrator sits between the UI and the API consumer. It owns an in-memory store
+// of repos, workflows and runs, and tells its subscribers whenever the store changes
+type Orchestrator struct {
+	api     consumer.Consumer
+	loaders *pool.Pool
+	lock    sync.RWMutex
+	repos   []RepoState
+	runs    map[string]RunsState
+	jobs    map[string]JobsState
+	// Workflow files by the commit they were read at, which makes them immutable
+	definitions map[string]definition.Definition
+	subscribers map[chan Event]struct{}
+	// The repos of the config standing for several repos, and the repos they were expanded to
+	patterns   []appconfig.Repo
+	discovered map[string]bool
+	// Whether the patterns have been expanded at least once
+	discoveredOnce bool
+	// Counts the loads started per repo, workflow and run, such that only the latest load stores its result
+	generations map[string]int
+}
+
+// New returns an orchestrator for the repos in the given config. Nothing is loaded until Load is called
+func New(api consumer.Consumer, conf appconfig.AppConfig) *Orchestrator {
+	named, patterns := discovery.Split(conf.Repos)
+	repos := make([]RepoState, len(named))
+	for i, repo := range named {
+		repos[i] = RepoState{Repo: repo, Status: Loading}
+	}
+
+	return &Orchestrator{
+		api:         api,
+		loaders:     pool.New(conf.Concurrency),
+		repos:       repos,
+		runs:        make(map[string]RunsState),
+		jobs:        make(map[string]JobsState),
+		definitions: make(map[string]definition.Definition),
+		subscribers: make(map[chan Event]struct{}),
+		patterns:    patterns,
+		discovered:  make(map[string]bool),
+		generations: make(map[string]int),
+	}
+}
+
+// Subscribe returns a channel of events about changes to the store, and a function to stop receiving them
+func (o *Orchestrator) Subscribe() (<-chan Event, func()) {
+	events := make(chan Event, subscriberBuffer)
+
+	o.lock.Lock()
+	o.subscribers[events] = struct{}{}
+	o.lock.Unlock()
+
+	unsubscribe := func() {
+		o.lock.Lock()
+		defer o.lock.Unlock()
+
+		if _, ok := o.subscribers[events]; ok {
+			delete(o.subscribers, events)
+			close(events)
+		}
+	}
+
+	return events, unsubscribe
+}
+
+// Repos returns a snapshot of every repo in the store, in configuration order
+func (o *Orchestrator) Repos() []RepoState {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	repos := make([]RepoState, len(o.repos))
+	for i, state := range o.repos {
+		repos[i] = state
+		repos[i].Workflows = append([]response.Workflow{}, state.Workflows...)
+	}
+
+	return repos
+}
+
+// Workflow returns a single workflow from the store
+func (o *Orchestrator) Workflow(repo appconfig.Repo, id string) (response.Workflow, bool) {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	index := o.indexOf(repo)
+	if index < 0 {
+		return response.Workflow{}, false
+	}
+
+	for _, workflow := range o.repos[index].Workflows {
+		if workflow.Id.String() == id {
+			return workflow, true
+		}
+	}
+
+	return response.Workflow{}, false
+}
+
+// Runs returns the recent runs of a workflow from the store
+func (o *Orchestrator) Runs(repo appconfig.Repo, workflowId string) RunsState {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	state, ok := o.runs[storeKey(repo, workflowId)]
+	if !ok {
+		return RunsState{Status: Loading}
+	}
+
+	state.Runs = append([]response.Run{}, state.Runs...)
+	return state
+}
+
+// Jobs returns the jobs of an attempt of a run from the store. Attempt zero is the latest attempt
+func (o *Orchestrator) Jobs(repo appconfig.Repo, runId string, attempt int) JobsState {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	state, ok := o.jobs[jobsKey(repo, runId, attempt)]
+	if !ok {
+		return JobsState{Status: Loading}
+	}
+
+	state.Jobs = append([]response.Job{}, state.Jobs...)
+	return state
+}
+
+// RateLimit returns the last known request budget of the token of the repo
+func (o *Orchestrator) RateLimit(repo appconfig.Repo) (response.RateLimit, bool) {
+	return o.api.RateLimit(repo)
+}
+
+// Load fetches the workflows of every repo that isn't loaded yet, or whose load was cancelled.
+// Repos are loaded concurrently and an event is published as each page of workflows arrives and as each repo completes.
+// It returns once every load is done
+func (o *Orchestrator) Load(ctx context.Context) {
+	o.lock.RLock()
+	discover := len(o.patterns) > 0 && !o.discoveredOnce
+	o.lock.RUnlock()
+
+	// Discovery errors are published, the repos configured by name are still loaded
+	if discover {
+		o.Discover(ctx)
+	}
+
+	o.lock.Lock()
+	pending := []appconfig.Repo{}
+	for i, state := range o.repos {
+		if state.Status == Loading || state.Status == Cancelled {
+			o.repos[i].Status = Loading
+			pending = append(pending, state.Repo)
+		}
+	}
+	o.lock.Unlock()
+
+	o.loadRepos(ctx, pending)
+}
+
+// HasPatterns reports whether the config has repos to discover, which should be discovered again now and then
+func (o *Orchestrator) HasPatterns() bool {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	return len(o.patterns) > 0
+}
+
+// Reconfigure applies the repos of a changed config to the store. Repos that were added are loaded by the
+// next Load, as are repos whose settings changed. Repos that were removed are dropped along with what was
+// loaded for them. The repos that stay keep their place, such that the UI doesn't jump around
+func (o *Orchestrator) Reconfigure(conf appconfig.AppConfig) {
+	named, patterns := discovery.Split(conf.Repos)
+
+	o.lock.Lock()
+	wanted := map[string]appconfig.Repo{}
+	for _, repo := range named {
+		wanted[discovery.Key(repo)] = repo
+	}
+
+	kept := []RepoState{}
+	for _, state := range o.repos {
+		key := discovery.Key(state.Repo)
+		repo, isNamed := wanted[key]
+		switch {
+		case isNamed:
+			// A discovered repo may be configured by name now
+			delete(o.discovered, key)
+			if !reflect.DeepEqual(repo, state.Repo) {
+				o.forget(state.Repo)
+				state = RepoState{Repo: repo, Status: Loading}
+			}
+		case o.discovered[key] && len(patterns) > 0:
+			// Discovering the changed patterns again decides whether it stays
+		default:
+			delete(o.discovered, key)
+			o.forget(state.Repo)
+			continue
+		}
+		kept = append(kept, state)
+	}
+	o.repos = kept
+
+	for _, repo := range named {
+		if o.indexOf(repo) < 0 {
+			o.repos = append(o.repos, RepoState{Repo: repo, Status: Loading})
+		}
+	}
+
+	if !reflect.DeepEqual(patterns, o.patterns) {
+		o.patterns = patterns
+		o.discoveredOnce = false
+	}
+	o.lock.Unlock()
+
+	o.publish(Event{Kind: ReposChanged})
+}
+
+// Discover expands the patterns of the config into repos. Repos that showed up are added to the store
+// for the next Load, and repos that are gone or had Actions disabled are removed. When discovery fails,
+// nothing is removed
+func (o *Orchestrator) Discover(ctx context.Context) error {
+	o.lock.RLock()
+	patterns := o.patterns
+	o.lock.RUnlock()
+
+	found, err := discovery.Expand(ctx, o.api, patterns)
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	o.lock.Lock()
+	o.discoveredOnce = true
+
+	foundKeys := map[string]bool{}
+	for _, repo := range found {
+		foundKeys[discovery.Key(repo)] = true
+	}
+
+	if err == nil {
+		kept := []RepoState{}
+		for _, state := range o.repos {
+			key := discovery.Key(state.Repo)
+			if o.discovered[key] && !foundKeys[key] {
+				delete(o.discovered, key)
+				continue
+			}
+			kept = append(kept, state)
+		}
+		o.repos = kept
+	}
+
+	for _, repo := range found {
+		index := o.indexOf(repo)
+		switch {
+		case index < 0:
+			o.repos = append(o.repos, RepoState{Repo: repo, Status: Loading})
+			o.discovered[discovery.Key(repo)] = true
+		case o.discovered[discovery.Key(repo)] && !reflect.DeepEqual(o.repos[index].Repo, repo):
+			// The settings of its pattern changed, e.g. its token
+			o.forget(o.repos[index].Repo)
+			o.repos[index] = RepoState{Repo: repo, Status: Loading}
+		}
+	}
+	o.lock.Unlock()
+
+	o.publish(Event{Kind: ReposChanged, Err: err})
+	return err
+}
+
+// Refresh fetches the workflows of every repo again
+func (o *Orchestrator) Refresh(ctx context.Context) {
+	o.lock.RLock()
+	repos := make([]appconfig.Repo, len(o.repos))
+	for i, state := range o.repos {
+		repos[i] = state.Repo
+	}
+	o.lock.RUnlock()
+
+	o.loadRepos(ctx, repos)
+}
+
+// LoadRuns fetches the most recent runs of a workflow
+func (o *Orchestrator) LoadRuns(ctx context.Context, repo appconfig.Repo, workflowId string, limit int) {
+	key := storeKey(repo, workflowId)
+	generation := o.startLoad("runs:" + key)
+	runs, err := o.api.ListRuns(ctx, repo, workflowId, request.RunFilter{Limit: limit})
+
+	o.lock.Lock()
+	if !o.isLatest("runs:"+key, generation) {
+		o.lock.Unlock()
+		return
+	}
+	state := o.runs[key]
+	switch {
+	case errors.Is(err, context.Canceled):
+		state.Status = Cancelled
+	case err != nil:
+		state.Status = Failed
+		state.Err = err
+	default:
+		state = RunsState{Status: Loaded, Runs: runs}
+	}
+	o.runs[key] = state
+	o.lock.Unlock()
+
+	o.publish(Event{Kind: RunsChanged, Repo: repo, WorkflowId: workflowId, Err: err})
+}
+
+// LoadJobs fetches the jobs of an attempt of a run. Attempt zero is the latest attempt
+func (o *Orchestrator) LoadJobs(ctx context.Context, repo appconfig.Repo, runId string, attempt int) {
+	key := jobsKey(repo, runId, attempt)
+	generation := o.startLoad("jobs:" + key)
+
+	var jobs []response.Job
+	var err error
+	if attempt == 0 {
+		jobs, err = o.api.ListJobs(ctx, repo, runId)
+	} else {
+		jobs, err = o.api.ListAttemptJobs(ctx, repo, runId, attempt)
+	}
+
+	o.lock.Lock()
+	if !o.isLatest("jobs:"+key, generation) {
+		o.lock.Unlock()
+		return
+	}
+	state := o.jobs[key]
+	switch {
+	case errors.Is(err, context.Canceled):
+		state.Status = Cancelled
+	case err != nil:
+		state.Status = Failed
+		state.Err = err
+	default:
+		state = JobsState{Status: Loaded, Jobs: jobs}
+	}
+	o.jobs[key] = state
+	o.lock.Unlock()
+
+	o.publish(Event{Kind: JobsChanged, Repo: repo, RunId: runId, Attempt: attempt, Err: err})
+}
+
+// Definition fetches and parses the workflow file at the given path and commit, e.g. the one a run was started from.
+// The API doesn't report the dependencies between jobs, so they are read from the file instead
+func (o *Orchestrator) Definition(ctx context.Context, repo appconfig.Repo, path string, ref string) (definition.Definition, error) {
+	// Runs of reusable workflows carry the ref of the called workflow in their path
+	path = strings.SplitN(path, "@", 2)[0]
+	key := storeKey(repo, path+"@"+ref)
+
+	o.lock.RLock()
+	cached, ok := o.definitions[key]
+	o.lock.RUnlock()
+	if ok {
+		return cached, nil
+	}
+
+	content, err := o.api.GetContent(ctx, repo, path, ref)
+	if err != nil {
+		return definition.Definition{}, err
+	}
+
+	parsed, err := definition.Parse(content)
+	if err != nil {
+		return definition.Definition{}, err
+	}
+
+	o.lock.Lock()
+	o.definitions[key] = parsed
+	o.lock.Unlock()
+
+	return parsed, nil
+}
+
+// DispatchInputs reads the inputs the workflow file declares at the ref it is about to be dispatched on.
+// Unlike Definition the file isn't kept, as the ref is usually a branch that moves on
+func (o *Orchestrator) DispatchInputs(ctx context.Context, repo appconfig.Repo, path string, ref string) (definition.Inputs, error) {
+	content, err := o.api.GetContent(ctx, repo, path, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := definition.Parse(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsed.On.Inputs, nil
+}
+
+// JobLogs fetches the log of a job. Logs are too large to keep in the store, so they are handed straight to the caller
+func (o *Orchestrator) JobLogs(ctx context.Context, repo appconfig.Repo, jobId string) (string, error) {
+	return o.api.JobLogs(ctx, repo, jobId)
+}
+
+// RunLogs fetches the logs of every job of the latest attempt of a run at once, as the files of the run's log archive
+func (o *Orchestrator) RunLogs(ctx context.Context, repo appconfig.Repo, runId string) ([]response.LogFile, error) {
+	return o.api.RunLogs(ctx, repo, runId)
+}
+
+// Dispatch triggers a workflow. A queued run is shown right away and removed again if the dispatch fails
+func (o *Orchestrator) Dispatch(ctx context.Context, repo appconfig.Repo, workflowId string, dispatchRequest request.Dispatch) error {
+	placeholder := response.Run{
+		Name:       "Dispatched " + dispatchRequest.Ref,
+		HeadBranch: dispatchRequest.Ref,
+		Event:      "workflow_dispatch",
+		Status:     "queued",
+		CreatedAt:  time.Now().UTC().Format(time.RFC3339),
+	}
+
+	key := storeKey(repo, workflowId)
+	o.lock.Lock()
+	state := o.runs[key]
+	state.Runs = append([]response.Run{placeholder}, state.Runs...)
+	o.runs[key] = state
+	o.lock.Unlock()
+	o.publish(Event{Kind: RunsChanged, Repo: repo, WorkflowId: workflowId})
+
+	_, err := o.api.Dispatch(ctx, repo, workflowId, dispatchRequest)
+	if err == nil {
+		return nil
+	}
+
+	o.lock.Lock()
+	state = o.runs[key]
+	for i, run := range state.Runs {
+		if run.Id == "" && run.CreatedAt == placeholder.CreatedAt && run.HeadBranch == placeholder.HeadBranch {
+			state.Runs = append(state.Runs[:i:i], state.Runs[i+1:]...)
+			break
+		}
+	}
+	o.runs[key] = state
+	o.lock.Unlock()
+	o.publish(Event{Kind: MutationFailed, Repo: repo, WorkflowId: workflowId, Err: err})
+
+	return err
+}
+
+// Enable enables a workflow, showing it as active before the API has confirmed it
+func (o *Orchestrator) Enable(ctx context.Context, repo appconfig.Repo, workflowId string) error {
+	return o.setState(repo, workflowId, "active", func() error {
+		_, err := o.api.Enable(ctx, repo, workflowId)
+		return err
+	})
+}
+
+// Disable disables a workflow, showing it as disabled before the API has confirmed it
+func (o *Orchestrator) Disable(ctx context.Context, repo appconfig.Repo, workflowId string) error {
+	return o.setState(repo, workflowId, "disabled_manually", func() error {
+		_, err := o.api.Disable(ctx, repo, workflowId)
+		return err
+	})
+}
+
+// CancelRun cancels a run, showing it as cancelled before the API has confirmed it.
+// A forced cancel also stops jobs that would otherwise keep running, e.g. through always()
+func (o *Orchestrator) CancelRun(ctx context.Context, repo appconfig.Repo, workflowId string, runId string, force bool) error {
+	return o.setRunStatus(repo, workflowId, runId, "completed", "cancelled", func() error {
+		if force {
+			_, err := o.api.ForceCancelRun(ctx, repo, runId)
+			return err
+		}
+		_, err := o.api.CancelRun(ctx, repo, runId)
+		return err
+	})
+}
+
+// RerunRun starts a new attempt of every job of a run, showing the run as queued before the API has confirmed it
+func (o *Orchestrator) RerunRun(ctx context.Context, repo appconfig.Repo, workflowId string, runId string, rerunRequest request.Rerun) error {
+	return o.setRunStatus(repo, workflowId, runId, "queued", "", func() error {
+		_, err := o.api.RerunRun(ctx, repo, runId, rerunRequest)
+		return err
+	})
+}
+
+// RerunFailedJobs starts a new attempt of the failed jobs of a run, showing the run as queued before the API has confirmed it
+func (o *Orchestrator) RerunFailedJobs(ctx context.Context, repo appconfig.Repo, workflowId string, runId string, rerunRequest request.Rerun) error {
+	return o.setRunStatus(repo, workflowId, runId, "queued", "", func() error {
+		_, err := o.api.RerunFailedJobs(ctx, repo, runId, rerunRequest)
+		return err
+	})
+}
+
+// RerunJob starts a new attempt of a single job of a run, showing the run as queued before the API has confirmed it
+func (o *Orchestrator) RerunJob(ctx context.Context, repo appconfig.Repo, workflowId string, runId string, jobId string, rerunRequest request.Rerun) error {
+	return o.setRunStatus(repo, workflowId, runId, "queued", "", func() error {
+		_, err := o.api.RerunJob(ctx, repo, jobId, rerunRequest)
+		return err
+	})
+}
+
+// Optimistically sets the status of a run, rolling it back if the mutation fails
+func (o *Orchestrator) setRunStatus(repo appconfig.Repo, workflowId string, runId string, status string, conclusion string, mutate func() error) error {
+	previous, ok := o.updateRun(repo, workflowId, runId, func(run *response.Run) {
+		run.Status = status
+		run.Conclusion = conclusion
+	})
+	if ok {
+		o.publish(Event{Kind: RunsChanged, Repo: repo, WorkflowId: workflowId, RunId: runId})
+	}
+
+	err := mutate()
+	if err == nil {
+		return nil
+	}
+
+	if ok {
+		o.updateRun(repo, workflowId, runId, func(run *response.Run) {
+			run.Status = previous.Status
+			run.Conclusion = previous.Conclusion
+		})
+	}
+	o.publish(Event{Kind: MutationFailed, Repo: repo, WorkflowId: workflowId, RunId: runId, Err: err})
+
+	return err
+}
+
+// Changes a run in the store and returns the run as it was before
+func (o *Orchestrator) updateRun(repo appconfig.Repo, workflowId string, runId string, change func(*response.Run)) (response.Run, bool) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	runs := o.runs[storeKey(repo, workflowId)].Runs
+	for i := range runs {
+		if runs[i].Id.String() == runId {
+			previous := runs[i]
+			change(&runs[i])
+			return previous, true
+		}
+	}
+
+	return response.Run{}, false
+}
+
+// Optimistically sets the state of a workflow, rolling it back if the mutation fails
+func (o *Orchestrator) setState(repo appconfig.Repo, workflowId string, newState string, mutate func() error) error {
+	previous, ok := o.updateWorkflowState(repo, workflowId, newState)
+	if ok {
+		o.publish(Event{Kind: WorkflowsChanged, Repo: repo, WorkflowId: workflowId})
+	}
+
+	err := mutate()
+	if err == nil {
+		return nil
+	}
+
+	if ok {
+		o.updateWorkflowState(repo, workflowId, previous)
+	}
+	o.publish(Event{Kind: MutationFailed, Repo: repo, WorkflowId: workflowId, Err: err})
+
+	return err
+}
+
+// Sets the state of a workflow in the store and returns the state it had before
+func (o *Orchestrator) updateWorkflowState(repo appconfig.Repo, workflowId string, newState string) (string, bool) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	index := o.indexOf(repo)
+	if index < 0 {
+		return "", false
+	}
+
+	workflows := o.repos[index].Workflows
+	for i := range workflows {
+		if workflows[i].Id.String() == workflowId {
+			previous := workflows[i].State
+			workflows[i].State = newState
+			return previous, true
+		}
+	}
+
+	return "", false
+}
+
+// Loads the given repos concurrently, bounded by the pool of loaders
+func (o *Orchestrator) loadRepos(ctx context.Context, repos []appconfig.Repo) {
+	wg := sync.WaitGroup{}
+
+	for _, repo := range repos {
+		wg.Add(1)
+		go func(repo appconfig.Repo) {
+			defer wg.Done()
+			o.loadRepo(ctx, repo)
+		}(repo)
+	}
+
+	wg.Wait()
+}
+
+func (o *Orchestrator) loadRepo(ctx context.Context, repo appconfig.Repo) {
+	key := "repo:" + storeKey(repo, "")
+	generation := o.startLoad(key)
+
+	workflows := []response.Workflow{}
+	onPage := func(page []response.Workflow) {
+		workflows = append(workflows, page...)
+		o.storePage(repo, key, generation, workflows)
+	}
+
+	var err error
+	if poolErr := o.loaders.Run(ctx, func() { err = o.api.ListPages(ctx, repo, onPage) }); poolErr != nil {
+		err = poolErr
+	}
+
+	o.lock.Lock()
+	index := o.indexOf(repo)
+	if index < 0 || !o.isLatest(key, generation) {
+		// The repo was removed or loaded again while it was loading
+		o.lock.Unlock()
+		return
+	}
+
+	state := &o.repos[index]
+	switch {
+	case errors.Is(err, context.Canceled):
+		state.Status = Cancelled
+	case err != nil:
+		state.Status = Failed
+		state.Err = err
+	default:
+		state.Status = Loaded
+		state.Workflows = workflows
+		state.Err = nil
+	}
+	o.lock.Unlock()
+
+	o.publish(Event{Kind: WorkflowsChanged, Repo: repo, Err: err})
+}
+
+// Shows the workflows of the pages that arrived so far, while the rest are loading. A refreshed repo keeps
+// its workflows until every page has arrived, rather than shrinking to the first page
+func (o *Orchestrator) storePage(repo appconfig.Repo, key string, generation int, workflows []response.Workflow) {
+	o.lock.Lock()
+	index := o.indexOf(repo)
+	if index < 0 || !o.isLatest(key, generation) || o.repos[index].Status != Loading {
+		o.lock.Unlock()
+		return
+	}
+	o.repos[index].Workflows = append([]response.Workflow{}, workflows...)
+	o.lock.Unlock()
+
+	o.publish(Event{Kind: WorkflowsChanged, Repo: repo})
+}
+
+// Notes that a load of the key started and returns its generation
+func (o *Orchestrator) startLoad(key string) int {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.generations[key]++
+	return o.generations[key]
+}
+
+// Whether no load of the key started after the one of the given generation. The caller must hold the lock
+func (o *Orchestrator) isLatest(key string, generation int) bool {
+	return o.generations[key] == generation
+}
+
+// Sends the event to every subscriber without blocking on slow ones
+func (o *Orchestrator) publish(event Event) {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	for subscriber := range o.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+}
+
+// Drops the runs, jobs and workflow files loaded for a repo. The caller must hold the lock
+func (o *Orchestrator) forget(repo appconfig.Repo) {
+	prefix := storeKey(repo, "")
+	for key := range o.runs {
+		if strings.HasPrefix(key, prefix) {
+			delete(o.runs, key)
+		}
+	}
+	for key := range o.jobs {
+		if strings.HasPrefix(key, prefix) {
+			delete(o.jobs, key)
+		}
+	}
+	for key := range o.definitions {
+		if strings.HasPrefix(key, prefix) {
+			delete(o.definitions, key)
+		}
+	}
+}
+
+// Finds the repo in the store. The caller must hold the lock
+func (o *Orchestrator) indexOf(repo appconfig.Repo) int {
+	for i, state := range o.repos {
+		if strings.EqualFold(state.Repo.Owner, repo.Owner) && strings.EqualFold(state.Repo.Repo, repo.Repo) {
+			return i
+		}
+	}
+	return -1
+}
+
+func storeKey(repo appconfig.Repo, workflowId string) string {
+	return repo.Owner + "/" + repo.Repo + "/" + workflowId
+}
+
+func jobsKey(repo appconfig.Repo, runId string, attempt int) string {
+	return storeKey(repo, runId) + "#" + strconv.Itoa(attempt)
+}
@@ -0,0 +1,388 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/andreaswachs/lazyworkflows/appconfig"
+	"github.com/andreaswachs/lazyworkflows/consumer"
+	"github.com/andreaswachs/lazyworkflows/model/request"
+	"github.com/andreaswachs/lazyworkflows/model/response"
+	"github.com/andreaswachs/lazyworkflows/test_resources"
+)
+
+// A consumer that serves canned workflows. Calls that aren't overridden panic through the nil interface
+type fakeConsumer struct {
+	consumer.Consumer
+	workflows map[string][]response.Workflow
+	failWith  error
+	// The paths and refs of the files requested through GetContent
+	contentRequests []string
+	// The repos of the owner, as discovered through patterns
+	ownerRepos []response.Repository
+	// Serves the runs instead of the canned ones, when set
+	listRuns func(ctx context.Context) ([]response.Run, error)
+	// How many workflows are served per page, all of them when zero, and what happens between two pages
+	pageSize     int
+	betweenPages func()
+}
+
+func (f *fakeConsumer) ListPages(ctx context.Context, repo appconfig.Repo, onPage func([]response.Workflow)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	workflows, ok := f.workflows[repo.Repo]
+	if !ok {
+		return fmt.Errorf("repo %s not found", repo.Repo)
+	}
+
+	size := f.pageSize
+	if size == 0 {
+		size = len(workflows)
+	}
+	for start := 0; start < len(workflows); start += size {
+		if start > 0 && f.betweenPages != nil {
+			f.betweenPages()
+		}
+		end := start + size
+		if end > len(workflows) {
+			end = len(workflows)
+		}
+		onPage(workflows[start:end])
+	}
+	return nil
+}
+
+func (f *fakeConsumer) Enable(ctx context.Context, repo appconfig.Repo, id string) (response.Enable, error) {
+	return response.Enable{Status: 204}, f.failWith
+}
+
+func (f *fakeConsumer) Disable(ctx context.Context, repo appconfig.Repo, id string) (response.Disable, error) {
+	return response.Disable{Status: 204}, f.failWith
+}
+
+func (f *fakeConsumer) Dispatch(ctx context.Context, repo appconfig.Repo, id string, dispatchRequest request.Dispatch) (response.Dispatch, error) {
+	return response.Dispatch{Status: 204}, f.failWith
+}
+
+func (f *fakeConsumer) ListRuns(ctx context.Context, repo appconfig.Repo, id string, filter request.RunFilter) ([]response.Run, error) {
+	if f.listRuns != nil {
+		return f.listRuns(ctx)
+	}
+	return []response.Run{{Id: "10", Status: "completed", Conclusion: "failure"}}, nil
+}
+
+func (f *fakeConsumer) CancelRun(ctx context.Context, repo appconfig.Repo, runId string) (response.Cancel, error) {
+	return response.Cancel{Status: 202}, f.failWith
+}
+
+func (f *fakeConsumer) RerunFailedJobs(ctx context.Context, repo appconfig.Repo, runId string, rerunRequest request.Rerun) (response.Rerun, error) {
+	return response.Rerun{Status: 201}, f.failWith
+}
+
+func (f *fakeConsumer) GetContent(ctx context.Context, repo appconfig.Repo, path string, ref string) (string, error) {
+	f.contentRequests = append(f.contentRequests, path+"@"+ref)
+	return test_resources.WorkflowFile, f.failWith
+}
+
+func (f *fakeConsumer) ListRepos(ctx context.Context, repo appconfig.Repo) ([]response.Repository, error) {
+	return f.ownerRepos, nil
+}
+
+func (f *fakeConsumer) ActionsEnabled(ctx context.Context, repo appconfig.Repo) (bool, error) {
+	return true, nil
+}
+
+func newTestOrchestrator(failWith error) *Orchestrator {
+	api := &fakeConsumer{
+		workflows: map[string][]response.Workflow{
+			"present": {{Id: "1", Name: "CI", State: "active"}},
+		},
+		failWith: failWith,
+	}
+
+	return New(api, appconfig.AppConfig{Repos: []appconfig.Repo{
+		{Owner: "octo", Repo: "present"},
+		{Owner: "octo", Repo: "missing"},
+	}})
+}
+
+func TestLoadStoresWorkflowsAndErrorsPerRepo(t *testing.T) {
+	store := newTestOrchestrator(nil)
+	events, unsubscribe := store.Subscribe()
+	defer unsubscribe()
+
+	store.Load(context.Background())
+
+	repos := store.Repos()
+	if repos[0].Status != Loaded || len(repos[0].Workflows) != 1 {
+		t.Errorf("Expected the first repo to be loaded with 1 workflow, but got %+v", repos[0])
+	}
+	if repos[1].Status != Failed || repos[1].Err == nil {
+		t.Errorf("Expected the second repo to have failed, but got %+v", repos[1])
+	}
+	// The page of the first repo, and the outcome of both
+	if len(events) != 3 {
+		t.Errorf("Expected an event per page and per repo, but got %v", len(events))
+	}
+}
+
+func TestPagesAreStoredAsTheyArrive(t *testing.T) {
+	store := newTestOrchestrator(nil)
+	api := store.api.(*fakeConsumer)
+	api.workflows["present"] = []response.Workflow{{Id: "1"}, {Id: "2"}, {Id: "3"}}
+	api.pageSize = 2
+
+	seen := []Status{}
+	api.betweenPages = func() {
+		state := store.Repos()[0]
+		seen = append(seen, state.Status)
+		if len(state.Workflows) != 2 {
+			t.Errorf("Expected the first page to be stored while the next loads, but got %+v", state.Workflows)
+		}
+	}
+	store.Load(context.Background())
+
+	if len(seen) != 1 || seen[0] != Loading {
+		t.Errorf("Expected the repo to still be loading between the pages, but got %v", seen)
+	}
+	if state := store.Repos()[0]; state.Status != Loaded || len(state.Workflows) != 3 {
+		t.Errorf("Expected every page to be stored once loaded, but got %+v", state)
+	}
+
+	// A refresh keeps the workflows until every page has arrived
+	api.betweenPages = func() {
+		if state := store.Repos()[0]; len(state.Workflows) != 3 {
+			t.Errorf("Expected the refreshed repo to keep its workflows, but got %+v", state.Workflows)
+		}
+	}
+	store.Refresh(context.Background())
+}
+
+func TestCancelledLoadsAreRetried(t *testing.T) {
+	store := newTestOrchestrator(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	store.Load(ctx)
+
+	if status := store.Repos()[0].Status; status != Cancelled {
+		t.Fatalf("Expected the load to be cancelled, but got status %v", status)
+	}
+
+	store.Load(context.Background())
+	if status := store.Repos()[0].Status; status != Loaded {
+		t.Errorf("Expected the repo to be loaded after retrying, but got status %v", status)
+	}
+}
+
+func TestOnlyTheLatestLoadOfRunsIsStored(t *testing.T) {
+	store := newTestOrchestrator(nil)
+	api := store.api.(*fakeConsumer)
+	repo := appconfig.Repo{Owner: "octo", Repo: "present"}
+
+	older, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	api.listRuns = func(ctx context.Context) ([]response.Run, error) {
+		if ctx == older {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return []response.Run{{Id: "11", Status: "queued"}}, nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		store.LoadRuns(older, repo, "1", 10)
+		close(done)
+	}()
+	<-started
+
+	store.LoadRuns(context.Background(), repo, "1", 10)
+	cancel()
+	<-done
+
+	state := store.Runs(repo, "1")
+	if state.Status != Loaded || len(state.Runs) != 1 || state.Runs[0].Id != "11" {
+		t.Errorf("Expected the runs of the latest load to stay, but got %+v", state)
+	}
+}
+
+func TestDisableIsRolledBackWhenRejected(t *testing.T) {
+	store := newTestOrchestrator(fmt.Errorf("forbidden"))
+	store.Load(context.Background())
+	repo := store.Repos()[0].Repo
+
+	events, unsubscribe := store.Subscribe()
+	defer unsubscribe()
+
+	if err := store.Disable(context.Background(), repo, "1"); err == nil {
+		t.Fatalf("Expected disabling to fail")
+	}
+
+	optimistic := <-events
+	if optimistic.Kind != WorkflowsChanged {
+		t.Errorf("Expected the optimistic update to be published first, but got %v", optimistic.Kind)
+	}
+	rollback := <-events
+	if rollback.Kind != MutationFailed || rollback.Err == nil {
+		t.Errorf("Expected the failure to be published, but got %+v", rollback)
+	}
+
+	workflow, _ := store.Workflow(repo, "1")
+	if workflow.State != "active" {
+		t.Errorf("Expected the state to be rolled back to active, but got %v", workflow.State)
+	}
+}
+
+func TestDispatchAddsAQueuedRun(t *testing.T) {
+	store := newTestOrchestrator(nil)
+	store.Load(context.Background())
+	repo := store.Repos()[0].Repo
+
+	if err := store.Dispatch(context.Background(), repo, "1", request.Dispatch{Ref: "main"}); err != nil {
+		t.Fatalf("Expected dispatching to succeed, but got %v", err)
+	}
+
+	runs := store.Runs(repo, "1").Runs
+	if len(runs) != 1 || runs[0].Status != "queued" || runs[0].HeadBranch != "main" {
+		t.Errorf("Expected a queued run on main, but got %+v", runs)
+	}
+}
+
+func TestFailedDispatchRemovesTheQueuedRun(t *testing.T) {
+	store := newTestOrchestrator(fmt.Errorf("unprocessable"))
+	store.Load(context.Background())
+	repo := store.Repos()[0].Repo
+
+	if err := store.Dispatch(context.Background(), repo, "1", request.Dispatch{Ref: "main"}); err == nil {
+		t.Fatalf("Expected dispatching to fail")
+	}
+
+	if runs := store.Runs(repo, "1").Runs; len(runs) != 0 {
+		t.Errorf("Expected the queued run to be removed, but got %+v", runs)
+	}
+}
+
+func TestCancelShowsTheRunAsCancelled(t *testing.T) {
+	store := newTestOrchestrator(nil)
+	repo := appconfig.Repo{Owner: "octo", Repo: "present"}
+	store.LoadRuns(context.Background(), repo, "1", 10)
+
+	if err := store.CancelRun(context.Background(), repo, "1", "10", false); err != nil {
+		t.Fatalf("Expected cancelling to succeed, but got %v", err)
+	}
+
+	if run := store.Runs(repo, "1").Runs[0]; run.Conclusion != "cancelled" {
+		t.Errorf("Expected the run to be shown as cancelled, but got %+v", run)
+	}
+}
+
+func TestFailedRerunRestoresTheRunStatus(t *testing.T) {
+	store := newTestOrchestrator(fmt.Errorf("forbidden"))
+	repo := appconfig.Repo{Owner: "octo", Repo: "present"}
+	store.LoadRuns(context.Background(), repo, "1", 10)
+
+	if err := store.RerunFailedJobs(context.Background(), repo, "1", "10", request.Rerun{}); err == nil {
+		t.Fatalf("Expected re-running to fail")
+	}
+
+	if run := store.Runs(repo, "1").Runs[0]; run.Status != "completed" || run.Conclusion != "failure" {
+		t.Errorf("Expected the run status to be rolled back, but got %+v", run)
+	}
+}
+
+func TestDefinitionIsFetchedOncePerCommit(t *testing.T) {
+	store := newTestOrchestrator(nil)
+	api := store.api.(*fakeConsumer)
+	repo := appconfig.Repo{Owner: "octo", Repo: "present"}
+
+	for i := 0; i < 2; i++ {
+		parsed, err := store.Definition(context.Background(), repo, ".github/workflows/ci.yml@refs/heads/main", "f83a356")
+		if err != nil {
+			t.Fatalf("Expected the definition to load, but got %v", err)
+		}
+		if len(parsed.Jobs["deploy"].Needs) != 2 {
+			t.Fatalf("Expected deploy to need 2 jobs, but got %v", parsed.Jobs["deploy"].Needs)
+		}
+	}
+
+	if len(api.contentRequests) != 1 || api.contentRequests[0] != ".github/workflows/ci.yml@f83a356" {
+		t.Errorf("Expected a single request for the file at the run's commit, but got %v", api.contentRequests)
+	}
+}
+
+func TestDiscoveredReposComeAndGo(t *testing.T) {
+	api := &fakeConsumer{
+		workflows: map[string][]response.Workflow{
+			"present":    {{Id: "1", Name: "CI", State: "active"}},
+			"discovered": {{Id: "2", Name: "Deploy", State: "active"}},
+		},
+		ownerRepos: []response.Repository{{Name: "present"}, {Name: "discovered"}},
+	}
+	store := New(api, appconfig.AppConfig{Repos: []appconfig.Repo{
+		{Owner: "octo", Repo: "present"},
+		{Owner: "octo", Repo: "*"},
+	}})
+
+	store.Load(context.Background())
+
+	repos := store.Repos()
+	if len(repos) != 2 || repos[1].Repo.Repo != "discovered" || repos[1].Status != Loaded {
+		t.Fatalf("Expected the discovered repo to be loaded after the named one, but got %+v", repos)
+	}
+
+	api.ownerRepos = []response.Repository{{Name: "present"}}
+	if err := store.Discover(context.Background()); err != nil {
+		t.Fatalf("Expected discovery to succeed, but got %v", err)
+	}
+
+	repos = store.Repos()
+	if len(repos) != 1 || repos[0].Repo.Repo != "present" {
+		t.Errorf("Expected only the named repo to be left, but got %+v", repos)
+	}
+}
+
+func TestReconfigureKeepsTheReposThatStay(t *testing.T) {
+	api := &fakeConsumer{
+		workflows: map[string][]response.Workflow{
+			"present": {{Id: "1", Name: "CI", State: "active"}},
+			"removed": {{Id: "2", Name: "Deploy", State: "active"}},
+			"added":   {{Id: "3", Name: "Release", State: "active"}},
+		},
+	}
+	store := New(api, appconfig.AppConfig{Repos: []appconfig.Repo{
+		{Owner: "octo", Repo: "removed"},
+		{Owner: "octo", Repo: "present", Token: "old"},
+	}})
+	store.Load(context.Background())
+	store.LoadRuns(context.Background(), appconfig.Repo{Owner: "octo", Repo: "removed"}, "2", 5)
+
+	store.Reconfigure(appconfig.AppConfig{Repos: []appconfig.Repo{
+		{Owner: "octo", Repo: "added"},
+		{Owner: "octo", Repo: "present", Token: "old"},
+	}})
+
+	repos := store.Repos()
+	if len(repos) != 2 || repos[0].Repo.Repo != "present" || repos[0].Status != Loaded || repos[1].Repo.Repo != "added" || repos[1].Status != Loading {
+		t.Fatalf("Expected the loaded repo to stay and the added one to follow it, but got %+v", repos)
+	}
+	if runs := store.Runs(appconfig.Repo{Owner: "octo", Repo: "removed"}, "2"); runs.Status != Loading || len(runs.Runs) != 0 {
+		t.Errorf("Expected the runs of the removed repo to be dropped, but got %+v", runs)
+	}
+
+	store.Load(context.Background())
+	if repos := store.Repos(); repos[1].Status != Loaded {
+		t.Errorf("Expected the added repo to be loaded, but got %+v", repos[1])
+	}
+
+	store.Reconfigure(appconfig.AppConfig{Repos: []appconfig.Repo{
+		{Owner: "octo", Repo: "added"},
+		{Owner: "octo", Repo: "present", Token: "new"},
+	}})
+	if repos := store.Repos(); repos[0].Status != Loading || repos[0].Repo.Token != "new" {
+		t.Errorf("Expected the repo with a new token to be loaded again, but got %+v", repos[0])
+	}
+}
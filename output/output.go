@@ -0,0 +1,215 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The formats items can be written in. Anything else containing {{ is taken as a Go template
+const (
+	Table  = "table"
+	Json   = "json"
+	Ndjson = "ndjson"
+	Yaml   = "yaml"
+	Csv    = "csv"
+)
+
+// Formats lists the named formats, for help texts
+var Formats = []string{Table, Json, Ndjson, Yaml, Csv}
+
+// Column is a single column of the table and CSV formats
+type Column[T any] struct {
+	Header string
+	Value  func(T) string
+}
+
+// Writer writes items of a single type in the format picked by the user
+type Writer[T any] struct {
+	format   string
+	columns  []Column[T]
+	template *template.Template
+}
+
+// New returns a writer for the given format. The columns are used by the table and CSV formats.
+// The JSON based formats use the json tags of the items, which match the fields of the GitHub API
+func New[T any](format string, columns []Column[T]) (*Writer[T], error) {
+	writer := &Writer[T]{format: format, columns: columns}
+
+	switch format {
+	case "":
+		writer.format = Table
+	case Table, Json, Ndjson, Yaml, Csv:
+	default:
+		if !strings.Contains(format, "{{") {
+			return nil, fmt.Errorf("unknown format %q, expected one of %s or a Go template", format, strings.Join(Formats, ", "))
+		}
+
+		parsed, err := template.New("format").Funcs(templateFuncs).Parse(format)
+		if err != nil {
+			return nil, fmt.Errorf("invalid format template: %w", err)
+		}
+		writer.template = parsed
+	}
+
+	return writer, nil
+}
+
+// Functions available to format templates, on top of the ones built into Go templates
+var templateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// Write writes a list of items. JSON and YAML write a single array, the other formats an entry per item
+func (w *Writer[T]) Write(out io.Writer, items []T) error {
+	if w.template != nil {
+		for _, item := range items {
+			if err := w.executeTemplate(out, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch w.format {
+	case Json:
+		return writeJson(out, items)
+	case Ndjson:
+		encoder := json.NewEncoder(out)
+		for _, item := range items {
+			if err := encoder.Encode(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case Yaml:
+		return writeYaml(out, items)
+	case Csv:
+		return w.writeCsv(out, items)
+	default:
+		return w.writeTable(out, items)
+	}
+}
+
+// WriteOne writes a single item. JSON and YAML write it as an object rather than an array of one
+func (w *Writer[T]) WriteOne(out io.Writer, item T) error {
+	switch {
+	case w.template != nil:
+		return w.executeTemplate(out, item)
+	case w.format == Json:
+		return writeJson(out, item)
+	case w.format == Yaml:
+		return writeYaml(out, item)
+	default:
+		return w.Write(out, []T{item})
+	}
+}
+
+func (w *Writer[T]) executeTemplate(out io.Writer, item T) error {
+	if err := w.template.Execute(out, item); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, "\n")
+	return err
+}
+
+func (w *Writer[T]) writeTable(out io.Writer, items []T) error {
+	table := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	headers := make([]string, len(w.columns))
+	for i, column := range w.columns {
+		headers[i] = column.Header
+	}
+	fmt.Fprintln(table, strings.Join(headers, "\t"))
+
+	for _, item := range items {
+		fmt.Fprintln(table, strings.Join(w.row(item), "\t"))
+	}
+
+	return table.Flush()
+}
+
+func (w *Writer[T]) writeCsv(out io.Writer, items []T) error {
+	writer := csv.NewWriter(out)
+
+	headers := make([]string, len(w.columns))
+	for i, column := range w.columns {
+		headers[i] = strings.ToLower(column.Header)
+	}
+	if err := writer.Write(headers); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if err := writer.Write(w.row(item)); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func (w *Writer[T]) row(item T) []string {
+	values := make([]string, len(w.columns))
+	for i, column := range w.columns {
+		// Tabs and newlines would break the alignment of the table and the rows of the CSV
+		values[i] = strings.Join(strings.Fields(column.Value(item)), " ")
+	}
+	return values
+}
+
+func writeJson(out io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// Writes the value as YAML with the same keys, in the same order, as its JSON
+func writeYaml(out io.Writer, value interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	// JSON is valid YAML, so decoding it into a node keeps the keys in the order of the struct fields
+	node := yaml.Node{}
+	if err := yaml.Unmarshal(encoded, &node); err != nil {
+		return err
+	}
+	clearStyle(&node)
+
+	buffer := bytes.Buffer{}
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+
+	_, err = out.Write(buffer.Bytes())
+	return err
+}
+
+// Drops the flow style and quotes taken over from JSON, such that the node is written as block YAML
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
@@ -0,0 +1,88 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/andreaswachs/lazyworkflows/model/response"
+)
+
+var workflowColumns = []Column[response.Workflow]{
+	{"ID", func(w response.Workflow) string { return w.Id.String() }},
+	{"NAME", func(w response.Workflow) string { return w.Name }},
+	{"STATE", func(w response.Workflow) string { return w.State }},
+}
+
+var workflows = []response.Workflow{
+	{Id: "161335", Name: "CI", State: "active", Path: ".github/workflows/ci.yml"},
+	{Id: "269289", Name: "Deploy, nightly", State: "disabled_manually", Path: ".github/workflows/deploy.yml"},
+}
+
+func write(t *testing.T, format string, items []response.Workflow) string {
+	writer, err := New(format, workflowColumns)
+	if err != nil {
+		t.Fatalf("Expected format %v to be accepted, but got %v", format, err)
+	}
+
+	out := bytes.Buffer{}
+	if err := writer.Write(&out, items); err != nil {
+		t.Fatalf("Expected writing to succeed, but got %v", err)
+	}
+	return out.String()
+}
+
+func TestTableAlignsColumns(t *testing.T) {
+	expected := "ID      NAME             STATE\n" +
+		"161335  CI               active\n" +
+		"269289  Deploy, nightly  disabled_manually\n"
+
+	if out := write(t, Table, workflows); out != expected {
+		t.Fatalf("Expected table to be\n%v\nbut got\n%v", expected, out)
+	}
+}
+
+func TestCsvQuotesValues(t *testing.T) {
+	expected := "id,name,state\n161335,CI,active\n269289,\"Deploy, nightly\",disabled_manually\n"
+
+	if out := write(t, Csv, workflows); out != expected {
+		t.Fatalf("Expected CSV to be\n%v\nbut got\n%v", expected, out)
+	}
+}
+
+func TestNdjsonWritesALinePerItemWithApiKeys(t *testing.T) {
+	out := write(t, Ndjson, workflows[:1])
+
+	expected := `{"id":161335,"node_id":"","name":"CI","path":".github/workflows/ci.yml","state":"active","created_at":"","updated_at":"","url":"","html_url":"","badge_url":""}` + "\n"
+	if out != expected {
+		t.Fatalf("Expected NDJSON to be\n%v\nbut got\n%v", expected, out)
+	}
+}
+
+func TestYamlKeepsTheJsonKeysInOrder(t *testing.T) {
+	writer, _ := New(Yaml, workflowColumns)
+	out := bytes.Buffer{}
+	writer.WriteOne(&out, response.Workflow{Id: "161335", Name: "CI", State: "123"})
+
+	expected := "id: 161335\nnode_id: \"\"\nname: CI\npath: \"\"\nstate: \"123\"\ncreated_at: \"\"\nupdated_at: \"\"\nurl: \"\"\nhtml_url: \"\"\nbadge_url: \"\"\n"
+	if out.String() != expected {
+		t.Fatalf("Expected YAML to be\n%v\nbut got\n%v", expected, out.String())
+	}
+}
+
+func TestTemplateIsExecutedPerItem(t *testing.T) {
+	out := write(t, "{{.Name}} {{.State | upper}}", workflows)
+
+	expected := "CI ACTIVE\nDeploy, nightly DISABLED_MANUALLY\n"
+	if out != expected {
+		t.Fatalf("Expected template output to be\n%v\nbut got\n%v", expected, out)
+	}
+}
+
+func TestUnknownFormatsAreRejected(t *testing.T) {
+	if _, err := New("xml", workflowColumns); err == nil {
+		t.Fatalf("Expected an unknown format to be rejected")
+	}
+	if _, err := New("{{.Name", workflowColumns); err == nil {
+		t.Fatalf("Expected a broken template to be rejected")
+	}
+}
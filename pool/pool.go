@@ -0,0 +1,35 @@
+// Package pool bounds how many loads talk to the API at the same time
+package pool
+
+import "context"
+
+// How many loads run at the same time when no size is given
+const defaultSize = 4
+
+// Pool hands out a fixed number of slots to the loads running through it
+type Pool struct {
+	slots chan struct{}
+}
+
+// New returns a pool that runs at most size loads at the same time. Zero or less uses a sensible default
+func New(size int) *Pool {
+	if size <= 0 {
+		size = defaultSize
+	}
+
+	return &Pool{slots: make(chan struct{}, size)}
+}
+
+// Run waits for a free slot and runs the load in it. When the context is done before a slot
+// frees up, the load is skipped and the error of the context is returned
+func (p *Pool) Run(ctx context.Context, load func()) error {
+	select {
+	case p.slots <- struct{}{}:
+		defer func() { <-p.slots }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	load()
+	return nil
+}
@@ -0,0 +1,74 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestRunBoundsTheLoadsRunningAtTheSameTime(t *testing.T) {
+	p := New(2)
+	release := make(chan struct{})
+	started := make(chan struct{}, 3)
+	lock := sync.Mutex{}
+	running, most := 0, 0
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Run(context.Background(), func() {
+				lock.Lock()
+				running++
+				if running > most {
+					most = running
+				}
+				lock.Unlock()
+
+				started <- struct{}{}
+				<-release
+
+				lock.Lock()
+				running--
+				lock.Unlock()
+			})
+		}()
+	}
+
+	<-started
+	<-started
+	close(release)
+	wg.Wait()
+
+	if most != 2 {
+		t.Errorf("Expected at most 2 loads at the same time, but got %v", most)
+	}
+}
+
+func TestRunSkipsTheLoadWhenCancelledWhileWaiting(t *testing.T) {
+	p := New(1)
+	held, release := make(chan struct{}), make(chan struct{})
+	go p.Run(context.Background(), func() {
+		close(held)
+		<-release
+	})
+	defer close(release)
+	<-held
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ran := false
+	err := p.Run(ctx, func() { ran = true })
+
+	if !errors.Is(err, context.Canceled) || ran {
+		t.Errorf("Expected the load to be skipped with the context's error, but got %v (ran: %v)", err, ran)
+	}
+}
+
+func TestNewUsesTheDefaultSizeWithoutOne(t *testing.T) {
+	if size := cap(New(0).slots); size != defaultSize {
+		t.Errorf("Expected %v slots but got %v", defaultSize, size)
+	}
+}
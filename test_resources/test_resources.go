@@ -1,8 +1,19 @@
 package test_resources
 
 const (
-	Workflow1         = `{"id":161335,"node_id":"MDg6V29ya2Zsb3cxNjEzMzU=","name":"CI","path":".github/workflows/blank.yaml","state":"active","created_at":"2020-01-08T23:48:37.000-08:00","updated_at":"2020-01-08T23:50:21.000-08:00","url":"https://api.github.com/repos/octo-org/octo-repo/actions/workflows/161335","html_url":"https://github.com/octo-org/octo-repo/blob/master/.github/workflows/161335","badge_url":"https://github.com/octo-org/octo-repo/workflows/CI/badge.svg"}`
-	Workflow2         = `{"id":20,"node_id":"MDg6V29ya2Zsb3cxNjEzMzU=","name":"CD","path":".github/workflows/other.yaml","state":"disabled","created_at":"2020-01-08T23:48:37.000-08:00","updated_at":"2020-01-08T23:50:21.000-08:00","url":"https://api.github.com/repos/octo-org/octo-repo/actions/workflows/161335","html_url":"https://github.com/octo-org/octo-repo/blob/master/.github/workflows/161335","badge_url":"https://github.com/octo-org/octo-repo/workflows/CI/badge.svg"}`
-	Status200Response = `{"status": 200}`
-	ListResponse      = "{\"total_count\":1,\"workflows\":[{\"id\":33451598,\"node_id\":\"W_kwDOH0TxRs4B_m5O\",\"name\":\"Unit Tests on Push\",\"path\":\".github/workflows/unit-tests-on-push.yml\",\"state\":\"active\",\"created_at\":\"2022-08-28T08:55:14.000+02:00\",\"updated_at\":\"2022-08-28T10:26:51.000+02:00\",\"url\":\"https://api.github.com/repos/andreaswachs/lazyworkflows/actions/workflows/33451598\",\"html_url\":\"https://github.com/andreaswachs/lazyworkflows/blob/main/.github/workflows/unit-tests-on-push.yml\",\"badge_url\":\"https://github.com/andreaswachs/lazyworkflows/workflows/Unit%20Tests%20on%20Push/badge.svg\"}]}"
+	Workflow1                  = `{"id":161335,"node_id":"MDg6V29ya2Zsb3cxNjEzMzU=","name":"CI","path":".github/workflows/blank.yaml","state":"active","created_at":"2020-01-08T23:48:37.000-08:00","updated_at":"2020-01-08T23:50:21.000-08:00","url":"https://api.github.com/repos/octo-org/octo-repo/actions/workflows/161335","html_url":"https://github.com/octo-org/octo-repo/blob/master/.github/workflows/161335","badge_url":"https://github.com/octo-org/octo-repo/workflows/CI/badge.svg"}`
+	Workflow2                  = `{"id":20,"node_id":"MDg6V29ya2Zsb3cxNjEzMzU=","name":"CD","path":".github/workflows/other.yaml","state":"disabled","created_at":"2020-01-08T23:48:37.000-08:00","updated_at":"2020-01-08T23:50:21.000-08:00","url":"https://api.github.com/repos/octo-org/octo-repo/actions/workflows/161335","html_url":"https://github.com/octo-org/octo-repo/blob/master/.github/workflows/161335","badge_url":"https://github.com/octo-org/octo-repo/workflows/CI/badge.svg"}`
+	Status200Response          = `{"status": 200}`
+	ListResponse               = "{\"total_count\":1,\"workflows\":[{\"id\":33451598,\"node_id\":\"W_kwDOH0TxRs4B_m5O\",\"name\":\"Unit Tests on Push\",\"path\":\".github/workflows/unit-tests-on-push.yml\",\"state\":\"active\",\"created_at\":\"2022-08-28T08:55:14.000+02:00\",\"updated_at\":\"2022-08-28T10:26:51.000+02:00\",\"url\":\"https://api.github.com/repos/andreaswachs/lazyworkflows/actions/workflows/33451598\",\"html_url\":\"https://github.com/andreaswachs/lazyworkflows/blob/main/.github/workflows/unit-tests-on-push.yml\",\"badge_url\":\"https://github.com/andreaswachs/lazyworkflows/workflows/Unit%20Tests%20on%20Push/badge.svg\"}]}"
+	DispatchValidationResponse = `{"message":"Required input 'environment' not provided","documentation_url":"https://docs.github.com/rest/reference/actions#create-a-workflow-dispatch-event"}`
+	NotFoundResponse           = `{"message":"Not Found","documentation_url":"https://docs.github.com/rest/reference/actions#get-a-workflow"}`
+	ValidationFailedResponse   = `{"message":"Validation Failed","errors":[{"resource":"WorkflowDispatch","field":"ref","code":"missing_field"},"Unexpected inputs provided"],"documentation_url":"https://docs.github.com/rest"}`
+	SecondaryRateLimitResponse = `{"message":"You have exceeded a secondary rate limit. Please wait a few minutes before you try again.","documentation_url":"https://docs.github.com/rest/overview/resources-in-the-rest-api#secondary-rate-limits"}`
+	Run1                       = `{"id":30433642,"name":"Build","node_id":"MDEyOldvcmtmbG93IFJ1bjI2OTI4OQ==","head_branch":"master","head_sha":"acb5820ced9479c074f688cc328bf03f341a511d","path":".github/workflows/build.yml@main","display_title":"Update README.md","run_number":562,"event":"push","status":"completed","conclusion":"success","workflow_id":159038,"run_attempt":1,"actor":{"login":"octocat","id":1,"type":"User","html_url":"https://github.com/octocat"},"triggering_actor":{"login":"octocat","id":1,"type":"User","html_url":"https://github.com/octocat"},"created_at":"2020-01-22T19:33:08Z","updated_at":"2020-01-22T19:33:08Z","run_started_at":"2020-01-22T19:33:08Z","url":"https://api.github.com/repos/octo-org/octo-repo/actions/runs/30433642","html_url":"https://github.com/octo-org/octo-repo/actions/runs/30433642","jobs_url":"https://api.github.com/repos/octo-org/octo-repo/actions/runs/30433642/jobs","logs_url":"https://api.github.com/repos/octo-org/octo-repo/actions/runs/30433642/logs","cancel_url":"https://api.github.com/repos/octo-org/octo-repo/actions/runs/30433642/cancel","rerun_url":"https://api.github.com/repos/octo-org/octo-repo/actions/runs/30433642/rerun","workflow_url":"https://api.github.com/repos/octo-org/octo-repo/actions/workflows/159038"}`
+	Job1                       = `{"id":399444496,"run_id":29679449,"run_url":"https://api.github.com/repos/octo-org/octo-repo/actions/runs/29679449","node_id":"MDEyOldvcmtmbG93IEpvYjM5OTQ0NDQ5Ng==","head_sha":"f83a356604ae3c5d03e1b46ef4d1ca77d64a90b0","url":"https://api.github.com/repos/octo-org/octo-repo/actions/jobs/399444496","html_url":"https://github.com/octo-org/octo-repo/runs/399444496","status":"completed","conclusion":"success","started_at":"2020-01-20T17:42:40Z","completed_at":"2020-01-20T17:44:39Z","name":"build","run_attempt":1,"workflow_name":"CI","labels":["ubuntu-latest"],"runner_name":"GitHub Actions 2","steps":[{"name":"Set up job","status":"completed","conclusion":"success","number":1,"started_at":"2020-01-20T17:42:40Z","completed_at":"2020-01-20T17:42:41Z"},{"name":"Run actions/checkout@v3","status":"completed","conclusion":"success","number":2,"started_at":"2020-01-20T17:42:41Z","completed_at":"2020-01-20T17:44:39Z"}]}`
+	JobListResponse            = `{"total_count":1,"jobs":[` + Job1 + `]}`
+	JobLog                     = "2020-01-20T17:42:40.1234567Z ##[group]Run actions/checkout@v3\n2020-01-20T17:42:40.2234567Z with:\n2020-01-20T17:42:40.3234567Z ##[endgroup]\n2020-01-20T17:42:41.1234567Z Syncing repository: octo-org/octo-repo\n"
+	WorkflowFile               = "name: CI\non: push\njobs:\n  build:\n    runs-on: ubuntu-latest\n    steps:\n      - run: make\n  test:\n    name: Unit tests\n    needs: build\n    runs-on: ubuntu-latest\n    steps:\n      - run: make test\n  deploy:\n    needs: [build, test]\n    runs-on: ubuntu-latest\n    steps:\n      - run: make deploy\n"
+	WorkflowFileContent        = `{"name":"blank.yaml","path":".github/workflows/blank.yaml","sha":"3d21ec53a331a6f037a91c368710b99387d012c1","encoding":"base64","content":"bmFtZTogQ0kKb246IHB1c2gKam9iczoKICBidWlsZDoKICAgIHJ1bnMtb246\nIHVidW50dS1sYXRlc3QKICAgIHN0ZXBzOgogICAgICAtIHJ1bjogbWFrZQog\nIHRlc3Q6CiAgICBuYW1lOiBVbml0IHRlc3RzCiAgICBuZWVkczogYnVpbGQK\nICAgIHJ1bnMtb246IHVidW50dS1sYXRlc3QKICAgIHN0ZXBzOgogICAgICAt\nIHJ1bjogbWFrZSB0ZXN0CiAgZGVwbG95OgogICAgbmVlZHM6IFtidWlsZCwg\ndGVzdF0KICAgIHJ1bnMtb246IHVidW50dS1sYXRlc3QKICAgIHN0ZXBzOgog\nICAgICAtIHJ1bjogbWFrZSBkZXBsb3kK"}`
+	RunListResponse            = `{"total_count":1,"workflow_runs":[` + Run1 + `]}`
 )
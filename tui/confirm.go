@@ -0,0 +1,158 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/andreaswachs/lazyworkflows/model/request"
+	"github.com/andreaswachs/lazyworkflows/model/response"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Asks to confirm an action on a run before it is carried out
+type confirmDialog struct {
+	active   bool
+	question string
+	// Whether the action can turn on debug logging, which re-runs can
+	offerDebug bool
+	debug      bool
+	// Which button is selected. The dialog starts on No, such that enter alone can't do any harm
+	yesFocused bool
+	confirm    func(debug bool) tea.Cmd
+}
+
+// Handles the keys that act on a run, shared by the runs table of the workflow tab and the run tab.
+// The job is the job selected in the run tab, if any. It reports whether the key was one of them
+func (m *model) runActionKeys(key string, target workflowTarget, run response.Run, job *response.Job) (tea.Cmd, bool) {
+	store := m.store
+	runId := run.Id.String()
+	name := fmt.Sprintf("run #%d", run.RunNumber)
+
+	switch key {
+	case "c", "C":
+		if run.Status == "completed" {
+			m.setStatus(fmt.Sprintf("Can't cancel %s, it has already completed", name), true)
+			return nil, true
+		}
+
+		force := key == "C"
+		question := fmt.Sprintf("Cancel %s?", name)
+		if force {
+			question = fmt.Sprintf("Force cancel %s? Jobs running with always() are stopped too", name)
+		}
+		m.openDialog(question, false, func(bool) tea.Cmd {
+			return func() tea.Msg {
+				err := store.CancelRun(context.Background(), target.repo, target.workflowId, runId, force)
+				return mutationDoneMsg{message: fmt.Sprintf("Cancelled %s", name), err: err, reload: &target}
+			}
+		})
+	case "R", "F":
+		if run.Status != "completed" {
+			m.setStatus(fmt.Sprintf("Can't re-run %s before it has completed", name), true)
+			return nil, true
+		}
+
+		failedOnly := key == "F"
+		question := fmt.Sprintf("Re-run every job of %s?", name)
+		if failedOnly {
+			question = fmt.Sprintf("Re-run the failed jobs of %s?", name)
+		}
+		m.openDialog(question, true, func(debug bool) tea.Cmd {
+			return func() tea.Msg {
+				rerunRequest := request.Rerun{EnableDebugLogging: debug}
+				if failedOnly {
+					err := store.RerunFailedJobs(context.Background(), target.repo, target.workflowId, runId, rerunRequest)
+					return mutationDoneMsg{message: fmt.Sprintf("Re-running the failed jobs of %s", name), err: err, reload: &target}
+				}
+				err := store.RerunRun(context.Background(), target.repo, target.workflowId, runId, rerunRequest)
+				return mutationDoneMsg{message: fmt.Sprintf("Re-running %s", name), err: err, reload: &target}
+			}
+		})
+	case "J":
+		if job == nil {
+			return nil, false
+		}
+		if job.Status != "completed" {
+			m.setStatus(fmt.Sprintf("Can't re-run %s before it has completed", job.Name), true)
+			return nil, true
+		}
+
+		jobId := job.Id.String()
+		jobName := job.Name
+		m.openDialog(fmt.Sprintf("Re-run %s of %s?", jobName, name), true, func(debug bool) tea.Cmd {
+			return func() tea.Msg {
+				err := store.RerunJob(context.Background(), target.repo, target.workflowId, runId, jobId, request.Rerun{EnableDebugLogging: debug})
+				return mutationDoneMsg{message: fmt.Sprintf("Re-running %s of %s", jobName, name), err: err, reload: &target}
+			}
+		})
+	default:
+		return nil, false
+	}
+
+	return nil, true
+}
+
+func (m *model) openDialog(question string, offerDebug bool, confirm func(debug bool) tea.Cmd) {
+	m.dialog = confirmDialog{
+		active:     true,
+		question:   question,
+		offerDebug: offerDebug,
+		confirm:    confirm,
+	}
+}
+
+// Handles the keys while the confirmation dialog is open
+func (m *model) updateDialog(msg tea.KeyMsg) tea.Cmd {
+	dialog := &m.dialog
+
+	switch msg.String() {
+	case "esc", "n", "q":
+		dialog.active = false
+	case "y":
+		dialog.active = false
+		return dialog.confirm(dialog.debug)
+	case "enter", " ":
+		dialog.active = false
+		if dialog.yesFocused {
+			return dialog.confirm(dialog.debug)
+		}
+	case "h", "l", "left", "right", "tab", "shift+tab":
+		dialog.yesFocused = !dialog.yesFocused
+	case "d":
+		if dialog.offerDebug {
+			dialog.debug = !dialog.debug
+		}
+	}
+
+	return nil
+}
+
+func renderDialog(builder *strings.Builder, dialog confirmDialog) {
+	question := lipgloss.NewStyle().Width(50).Align(lipgloss.Center).Render(dialog.question)
+
+	yesButton, noButton := buttonStyle.Render("Yes"), activeButtonStyle.Render("No")
+	if dialog.yesFocused {
+		yesButton, noButton = activeButtonStyle.Render("Yes"), buttonStyle.Render("No")
+	}
+	buttons := lipgloss.JoinHorizontal(lipgloss.Top, yesButton, "  ", noButton)
+
+	parts := []string{question}
+	if dialog.offerDebug {
+		checkbox := "[ ]"
+		if dialog.debug {
+			checkbox = "[x]"
+		}
+		parts = append(parts, "", checkbox+" Enable debug logging (d)")
+	}
+	parts = append(parts, buttons)
+
+	ui := lipgloss.JoinVertical(lipgloss.Center, parts...)
+	builder.WriteString(lipgloss.Place(width, 11,
+		lipgloss.Center, lipgloss.Center,
+		dialogBoxStyle.Render(ui),
+		lipgloss.WithWhitespaceChars(" "),
+	))
+	builder.WriteString("\n\ny: yes • n: no • ←/→: select • enter: confirm selection\n")
+}
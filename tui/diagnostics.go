@@ -0,0 +1,72 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/andreaswachs/lazyworkflows/orchestrator"
+	"github.com/muesli/reflow/truncate"
+)
+
+// The API used by repos that don't configure their own
+const defaultApiUrl = "https://api.github.com"
+
+// Renders how every repo is set up and how it is doing: where its token came from,
+// which API it talks to, whether its workflows loaded and what is left of its rate limit
+func renderDiagnostics(builder *strings.Builder, m *model) {
+	builder.WriteString(listHeader("Repos"))
+	builder.WriteString("\n")
+
+	table := bytes.Buffer{}
+	writer := tabwriter.NewWriter(&table, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "REPO\tTOKEN\tAPI\tWORKFLOWS\tRATE LIMIT")
+
+	for _, state := range m.store.Repos() {
+		apiUrl := state.Repo.ApiUrl
+		if apiUrl == "" {
+			apiUrl = defaultApiUrl
+		}
+
+		name := state.Repo.Owner + "/" + state.Repo.Repo
+		if state.Repo.Checkout != "" {
+			name += " (checkout)"
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n",
+			name,
+			state.Repo.TokenSource,
+			apiUrl,
+			diagnoseWorkflows(state),
+			diagnoseRateLimit(m, state))
+	}
+	writer.Flush()
+
+	for _, line := range strings.Split(strings.TrimRight(table.String(), "\n"), "\n") {
+		builder.WriteString(truncate.String(line, uint(width-2)))
+		builder.WriteString("\n")
+	}
+
+	builder.WriteString("\nTokens are taken from the repo, then the defaults of the config, then $GITHUB_TOKEN or $GH_TOKEN\n")
+	builder.WriteString("The repo of the git checkout you started in is shown as well, with the token of a repo on the same host\n")
+}
+
+func diagnoseWorkflows(state orchestrator.RepoState) string {
+	switch state.Status {
+	case orchestrator.Loaded:
+		return fmt.Sprintf("%d loaded", len(state.Workflows))
+	case orchestrator.Failed:
+		return "✗ " + state.Err.Error()
+	default:
+		return "loading"
+	}
+}
+
+func diagnoseRateLimit(m *model, state orchestrator.RepoState) string {
+	rateLimit, ok := m.store.RateLimit(state.Repo)
+	if !ok {
+		return "unknown"
+	}
+	return fmt.Sprintf("%d/%d left until %s", rateLimit.Remaining, rateLimit.Limit, rateLimit.Reset.Format("15:04"))
+}
@@ -0,0 +1,141 @@
+package tui
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/andreaswachs/lazyworkflows/orchestrator"
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// How often the repos of the patterns in the config are discovered again when nothing is configured
+const defaultDiscoveryInterval = 15 * time.Minute
+
+// Sent whenever the orchestrator's store changed
+type storeChangedMsg struct {
+	event orchestrator.Event
+}
+
+// Waits for the next change of the store. It must be issued again after each storeChangedMsg
+func waitForStoreChange(events <-chan orchestrator.Event) tea.Cmd {
+	return func() tea.Msg {
+		event, ok := <-events
+		if !ok {
+			return nil
+		}
+		return storeChangedMsg{event: event}
+	}
+}
+
+// Loads every repo that isn't loaded yet. Progress arrives as storeChangedMsgs
+func loadRepos(ctx context.Context, store *orchestrator.Orchestrator) tea.Cmd {
+	return func() tea.Msg {
+		store.Load(ctx)
+		return nil
+	}
+}
+
+// Sent when the repos of the patterns in the config should be discovered again
+type discoverTickMsg struct{}
+
+func discoverTick(interval time.Duration) tea.Cmd {
+	if interval <= 0 {
+		interval = defaultDiscoveryInterval
+	}
+	return tea.Tick(interval, func(time.Time) tea.Msg {
+		return discoverTickMsg{}
+	})
+}
+
+// Discovers the repos of the patterns again and loads the ones that showed up.
+// It isn't tied to a tab, such that switching tabs doesn't abort it
+func discoverRepos(store *orchestrator.Orchestrator) tea.Cmd {
+	return func() tea.Msg {
+		ctx := context.Background()
+		if err := store.Discover(ctx); err == nil {
+			store.Load(ctx)
+		}
+		return nil
+	}
+}
+
+// Whether any repo is still waiting for its workflows
+func (m *model) isLoading() bool {
+	for _, state := range m.store.Repos() {
+		if state.Status == orchestrator.Loading {
+			return true
+		}
+	}
+	return false
+}
+
+// Rebuilds the rows of the overview table from the store. The cursor stays on the row it was on,
+// even when repos were added or removed above it
+func (m *model) refreshRows() {
+	var previous *workflowTarget
+	if cursor := m.fullTable.Cursor(); cursor >= 0 && cursor < len(m.rowTargets) {
+		previous = &m.rowTargets[cursor]
+	}
+
+	rows := []table.Row{}
+	targets := []workflowTarget{}
+
+	for _, state := range m.store.Repos() {
+		owner, repo := state.Repo.Owner, state.Repo.Repo
+
+		switch state.Status {
+		case orchestrator.Loading, orchestrator.Cancelled:
+			// The pages that arrived already are shown while the rest are loading
+			for _, workflow := range state.Workflows {
+				rows = append(rows, table.Row{owner, repo, workflow.Name})
+				targets = append(targets, workflowTarget{repo: state.Repo, workflowId: workflow.Id.String()})
+			}
+			label := " Loading workflows..."
+			if len(state.Workflows) > 0 {
+				label = " Loading more workflows..."
+			}
+			rows = append(rows, table.Row{owner, repo, m.spinner.View() + label})
+			targets = append(targets, workflowTarget{repo: state.Repo})
+		case orchestrator.Failed:
+			rows = append(rows, table.Row{owner, repo, "✗ " + state.Err.Error()})
+			targets = append(targets, workflowTarget{repo: state.Repo})
+		case orchestrator.Loaded:
+			if len(state.Workflows) == 0 {
+				rows = append(rows, table.Row{owner, repo, "No workflows"})
+				targets = append(targets, workflowTarget{repo: state.Repo})
+			}
+			for _, workflow := range state.Workflows {
+				rows = append(rows, table.Row{owner, repo, workflow.Name})
+				targets = append(targets, workflowTarget{repo: state.Repo, workflowId: workflow.Id.String()})
+			}
+		}
+	}
+
+	m.fullTable.SetRows(rows)
+	m.rowTargets = targets
+
+	if previous != nil {
+		if index := indexOfTarget(targets, *previous); index >= 0 {
+			m.fullTable.SetCursor(index)
+		}
+	}
+}
+
+// Finds the row of the workflow, or the first row of its repo when the workflow is gone or still loading
+func indexOfTarget(targets []workflowTarget, wanted workflowTarget) int {
+	sameRepo := -1
+	for i, target := range targets {
+		if !strings.EqualFold(target.repo.Owner, wanted.repo.Owner) || !strings.EqualFold(target.repo.Repo, wanted.repo.Repo) {
+			continue
+		}
+		if target.workflowId == wanted.workflowId {
+			return i
+		}
+		if sameRepo < 0 {
+			sameRepo = i
+		}
+	}
+	return sameRepo
+}
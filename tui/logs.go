@@ -0,0 +1,636 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andreaswachs/lazyworkflows/appconfig"
+	"github.com/andreaswachs/lazyworkflows/model/response"
+	"github.com/andreaswachs/lazyworkflows/orchestrator"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/muesli/reflow/truncate"
+)
+
+// How often the log of a job that is still running is fetched again in follow mode
+const followInterval = 3 * time.Second
+
+var (
+	logTimestampPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?Z `)
+	ansiPattern         = regexp.MustCompile("\x1b\\[[0-9;]*[A-Za-z]")
+)
+
+// A single line of a job log
+type logLine struct {
+	timestamp string
+	text      string
+	// The index of the group the line belongs to, or -1 outside of groups
+	group int
+	// Whether the line is the title of its group
+	header bool
+}
+
+// A section of the log between ##[group] and ##[endgroup] markers
+type logGroup struct {
+	folded bool
+}
+
+// The log pane shows the log of one job of a run at a time, or of every job of the run at once
+type logPane struct {
+	repo    appconfig.Repo
+	runId   string
+	attempt int
+	title   string
+	job     response.Job
+	// Whether the logs of every job are shown instead of the job's
+	wholeRun bool
+
+	lines  []logLine
+	groups []logGroup
+	// Indices of the lines not hidden by a folded group
+	visible []int
+	loaded  bool
+	err     error
+
+	// Positions within the visible lines
+	cursor int
+	offset int
+
+	follow         bool
+	showTimestamps bool
+
+	search    textinput.Model
+	searching bool
+	query     string
+}
+
+// Sent when the log of a job, or of the whole run, has been fetched, or failed to be
+type logLoadedMsg struct {
+	jobId    string
+	wholeRun bool
+	content  string
+	err      error
+}
+
+// Sent when a followed job log should be fetched again
+type followTickMsg struct {
+	jobId string
+}
+
+// Opens the log of the given job of a run. Without a job id, a job is picked once the jobs of the run are loaded
+func newLogPane(repo appconfig.Repo, run response.Run, attempt int, job response.Job) *logPane {
+	search := textinput.New()
+	search.Prompt = "/"
+
+	title := run.DisplayTitle
+	if title == "" {
+		title = run.Name
+	}
+
+	follow := run.Status != "completed"
+	if job.Id != "" {
+		follow = job.Status != "completed"
+	}
+
+	return &logPane{
+		repo:    repo,
+		runId:   run.Id.String(),
+		attempt: attempt,
+		title:   fmt.Sprintf("#%d %s", run.RunNumber, title),
+		job:     job,
+		search:  search,
+		follow:  follow,
+	}
+}
+
+// Fetches the jobs of an attempt of a run. Progress arrives as storeChangedMsgs
+func loadJobs(ctx context.Context, store *orchestrator.Orchestrator, repo appconfig.Repo, runId string, attempt int) tea.Cmd {
+	return func() tea.Msg {
+		store.LoadJobs(ctx, repo, runId, attempt)
+		return nil
+	}
+}
+
+// Fetches the log of a single job
+func loadJobLog(ctx context.Context, store *orchestrator.Orchestrator, repo appconfig.Repo, jobId string) tea.Cmd {
+	return func() tea.Msg {
+		content, err := store.JobLogs(ctx, repo, jobId)
+		return logLoadedMsg{jobId: jobId, content: content, err: err}
+	}
+}
+
+// Fetches the logs of every job of the run, joined in the order the jobs ran
+func loadRunLog(ctx context.Context, store *orchestrator.Orchestrator, repo appconfig.Repo, runId string) tea.Cmd {
+	return func() tea.Msg {
+		files, err := store.RunLogs(ctx, repo, runId)
+		return logLoadedMsg{wholeRun: true, content: joinRunLogs(files), err: err}
+	}
+}
+
+// Fetches what the pane shows again
+func (p *logPane) reload(ctx context.Context, store *orchestrator.Orchestrator) tea.Cmd {
+	if p.wholeRun {
+		return loadRunLog(ctx, store, p.repo, p.runId)
+	}
+	return loadJobLog(ctx, store, p.repo, p.job.Id.String())
+}
+
+// Picks a job once the jobs of the run are known, and keeps the shown job's status up to date
+func (m *model) handleJobsChanged(event orchestrator.Event) tea.Cmd {
+	if m.logs == nil || event.Kind != orchestrator.JobsChanged || event.RunId != m.logs.runId || event.Attempt != m.logs.attempt {
+		return nil
+	}
+
+	jobs := m.store.Jobs(m.logs.repo, m.logs.runId, m.logs.attempt).Jobs
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	if m.logs.job.Id == "" {
+		m.logs.job = preferredJob(jobs)
+		return loadJobLog(m.viewCtx, m.store, m.logs.repo, m.logs.job.Id.String())
+	}
+
+	for _, job := range jobs {
+		if job.Id == m.logs.job.Id {
+			m.logs.job = job
+		}
+	}
+	return nil
+}
+
+// Stores a fetched log and schedules the next fetch when following a running job
+func (m *model) handleLogLoaded(msg logLoadedMsg) tea.Cmd {
+	pane := m.logs
+	if pane == nil || msg.wholeRun != pane.wholeRun || (!msg.wholeRun && msg.jobId != pane.job.Id.String()) {
+		return nil
+	}
+
+	pane.loaded = true
+	pane.err = msg.err
+	if msg.err == nil {
+		pane.setContent(msg.content)
+	}
+
+	if pane.follow && !pane.wholeRun && pane.job.Status != "completed" {
+		jobId := msg.jobId
+		return tea.Tick(followInterval, func(time.Time) tea.Msg {
+			return followTickMsg{jobId: jobId}
+		})
+	}
+	return nil
+}
+
+// Fetches the followed job and its log again, unless the pane was left in the meantime
+func (m *model) handleFollowTick(msg followTickMsg) tea.Cmd {
+	if m.selectedTab != logs || m.logs == nil || !m.logs.follow || m.logs.wholeRun || msg.jobId != m.logs.job.Id.String() {
+		return nil
+	}
+
+	return tea.Batch(
+		loadJobs(m.viewCtx, m.store, m.logs.repo, m.logs.runId, m.logs.attempt),
+		loadJobLog(m.viewCtx, m.store, m.logs.repo, msg.jobId),
+	)
+}
+
+// Handles the keys of the logs tab
+func (m *model) updateLogs(msg tea.KeyMsg) tea.Cmd {
+	pane := m.logs
+	if pane == nil {
+		return nil
+	}
+
+	if pane.searching {
+		return pane.updateSearch(msg)
+	}
+
+	pageSize := logPaneHeight()
+
+	switch msg.String() {
+	case "esc":
+		return m.switchTab(runView)
+	case "j", "down":
+		pane.moveCursor(1)
+	case "k", "up":
+		pane.moveCursor(-1)
+	case "ctrl+d", "pgdown":
+		pane.moveCursor(pageSize)
+	case "ctrl+u", "pgup":
+		pane.moveCursor(-pageSize)
+	case "g", "home":
+		pane.moveCursor(-len(pane.visible))
+	case "G", "end":
+		pane.moveCursor(len(pane.visible))
+	case "enter", " ":
+		pane.toggleGroup()
+	case "z":
+		pane.toggleAllGroups()
+	case "t":
+		pane.showTimestamps = !pane.showTimestamps
+	case "/":
+		pane.searching = true
+		pane.search.SetValue(pane.query)
+		return pane.search.Focus()
+	case "n":
+		pane.findMatch(1)
+	case "N":
+		pane.findMatch(-1)
+	case "f":
+		// The log archive of a run is only complete once the run is, so there is nothing to follow
+		if pane.wholeRun {
+			return nil
+		}
+		pane.follow = !pane.follow
+		if pane.follow {
+			pane.moveCursor(len(pane.visible))
+			return pane.reload(m.viewCtx, m.store)
+		}
+	case "r":
+		return pane.reload(m.viewCtx, m.store)
+	case "[":
+		return m.switchJob(-1)
+	case "]":
+		return m.switchJob(1)
+	}
+
+	return nil
+}
+
+// Shows the previous or next job of the run. The whole run follows the last job, for the latest attempt only
+// as the log archive holds the logs of that attempt
+func (m *model) switchJob(direction int) tea.Cmd {
+	jobs := m.store.Jobs(m.logs.repo, m.logs.runId, m.logs.attempt).Jobs
+	entries := len(jobs)
+	if m.logs.attempt == 0 {
+		entries++
+	}
+	if entries < 2 {
+		return nil
+	}
+
+	current := len(jobs)
+	if !m.logs.wholeRun {
+		for i, job := range jobs {
+			if job.Id == m.logs.job.Id {
+				current = i
+			}
+		}
+	}
+
+	next := (current + direction + entries) % entries
+	m.logs.loaded = false
+	m.logs.err = nil
+	m.logs.lines = nil
+	m.logs.groups = nil
+	m.logs.visible = nil
+	m.logs.cursor = 0
+	m.logs.offset = 0
+
+	if next == len(jobs) {
+		m.logs.wholeRun = true
+		m.logs.follow = false
+		return loadRunLog(m.viewCtx, m.store, m.logs.repo, m.logs.runId)
+	}
+
+	m.logs.wholeRun = false
+	m.logs.job = jobs[next]
+	m.logs.follow = jobs[next].Status != "completed"
+
+	return loadJobLog(m.viewCtx, m.store, m.logs.repo, jobs[next].Id.String())
+}
+
+func (p *logPane) updateSearch(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc":
+		p.searching = false
+		p.search.Blur()
+		return nil
+	case "enter":
+		p.searching = false
+		p.search.Blur()
+		p.query = p.search.Value()
+		p.findMatch(0)
+		return nil
+	}
+
+	var cmd tea.Cmd
+	p.search, cmd = p.search.Update(msg)
+	return cmd
+}
+
+// Parses the log, keeping folds and the cursor where they were when the same job is reloaded
+func (p *logPane) setContent(content string) {
+	atEnd := p.cursor >= len(p.visible)-1
+	lines, groups := parseLog(content)
+
+	for i := range groups {
+		if i < len(p.groups) {
+			groups[i].folded = p.groups[i].folded
+		}
+	}
+
+	p.lines = lines
+	p.groups = groups
+	p.refreshVisible()
+
+	if p.follow && atEnd {
+		p.moveCursor(len(p.visible))
+	} else {
+		p.moveCursor(0)
+	}
+}
+
+func (p *logPane) refreshVisible() {
+	p.visible = p.visible[:0]
+	for i, line := range p.lines {
+		if line.group >= 0 && !line.header && p.groups[line.group].folded {
+			continue
+		}
+		p.visible = append(p.visible, i)
+	}
+}
+
+// Moves the cursor by the given number of lines, scrolling to keep it in view
+func (p *logPane) moveCursor(delta int) {
+	p.cursor += delta
+	if p.cursor >= len(p.visible) {
+		p.cursor = len(p.visible) - 1
+	}
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+
+	height := logPaneHeight()
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+height {
+		p.offset = p.cursor - height + 1
+	}
+}
+
+// Folds or unfolds the group the cursor is in
+func (p *logPane) toggleGroup() {
+	if len(p.visible) == 0 {
+		return
+	}
+
+	line := p.lines[p.visible[p.cursor]]
+	if line.group < 0 {
+		return
+	}
+
+	p.groups[line.group].folded = !p.groups[line.group].folded
+	p.refreshVisible()
+	p.moveCursorToLine(p.headerOf(line.group))
+}
+
+// Unfolds every group, or folds them all if none is folded
+func (p *logPane) toggleAllGroups() {
+	anyFolded := false
+	for _, group := range p.groups {
+		anyFolded = anyFolded || group.folded
+	}
+
+	for i := range p.groups {
+		p.groups[i].folded = !anyFolded
+	}
+
+	current := 0
+	if len(p.visible) > 0 {
+		current = p.visible[p.cursor]
+	}
+	p.refreshVisible()
+	p.moveCursorToLine(current)
+}
+
+// Moves the cursor to the next (1) or previous (-1) line matching the search, or the first match from the cursor (0).
+// Groups hiding a match are unfolded
+func (p *logPane) findMatch(direction int) {
+	if p.query == "" || len(p.lines) == 0 {
+		return
+	}
+
+	start := 0
+	if len(p.visible) > 0 {
+		start = p.visible[p.cursor]
+	}
+
+	step := direction
+	if step == 0 {
+		step = 1
+		start--
+	}
+
+	query := strings.ToLower(p.query)
+	for i := 1; i <= len(p.lines); i++ {
+		index := ((start+step*i)%len(p.lines) + len(p.lines)) % len(p.lines)
+		if !strings.Contains(strings.ToLower(stripAnsi(p.lines[index].text)), query) {
+			continue
+		}
+
+		if group := p.lines[index].group; group >= 0 {
+			p.groups[group].folded = false
+			p.refreshVisible()
+		}
+		p.moveCursorToLine(index)
+		return
+	}
+}
+
+// Moves the cursor onto the given line, or the closest visible line before it
+func (p *logPane) moveCursorToLine(line int) {
+	for i := len(p.visible) - 1; i >= 0; i-- {
+		if p.visible[i] <= line {
+			p.moveCursor(i - p.cursor)
+			return
+		}
+	}
+	p.moveCursor(-p.cursor)
+}
+
+func (p *logPane) headerOf(group int) int {
+	for i, line := range p.lines {
+		if line.group == group && line.header {
+			return i
+		}
+	}
+	return 0
+}
+
+func renderLogs(builder *strings.Builder, m *model) {
+	pane := m.logs
+	if pane == nil {
+		builder.WriteString("Select a job in the Run tab and press enter to read its log here\n")
+		return
+	}
+
+	jobs := m.store.Jobs(pane.repo, pane.runId, pane.attempt)
+	jobName := statusIcon(pane.job.Status, pane.job.Conclusion) + " " + pane.job.Name
+	if pane.wholeRun {
+		jobName = "Whole run"
+	} else if pane.job.Name == "" {
+		jobName = statusIcon(pane.job.Status, pane.job.Conclusion) + " ..."
+	}
+	follow := "off"
+	if pane.follow {
+		follow = "on"
+	}
+
+	builder.WriteString(fmt.Sprintf("%s/%s %s %s %s %s %s %s follow %s\n\n",
+		pane.repo.Owner, pane.repo.Repo, divider,
+		pane.title, divider,
+		jobName,
+		fmt.Sprintf("(%d jobs)", len(jobs.Jobs)), divider, follow))
+
+	height := logPaneHeight()
+	switch {
+	case jobs.Status == orchestrator.Failed:
+		builder.WriteString("✗ " + jobs.Err.Error() + "\n")
+	case pane.err != nil:
+		builder.WriteString("✗ " + pane.err.Error() + "\n")
+	case !pane.loaded:
+		builder.WriteString(m.spinner.View() + " Loading logs...\n")
+	default:
+		query := strings.ToLower(pane.query)
+		for row := pane.offset; row < pane.offset+height && row < len(pane.visible); row++ {
+			builder.WriteString(pane.renderLine(row, query))
+			builder.WriteString("\n")
+		}
+	}
+
+	builder.WriteString("\n")
+	if pane.searching {
+		builder.WriteString(pane.search.View())
+		builder.WriteString("\n")
+		return
+	}
+	builder.WriteString("space: fold • z: fold all • /: search • n/N: next/prev match • f: follow • t: timestamps • [/]: switch job or whole run • esc: back\n")
+}
+
+// Renders a visible line with a gutter marking the cursor and search matches.
+// Colours in the log are passed through as they are
+func (p *logPane) renderLine(row int, query string) string {
+	line := p.lines[p.visible[row]]
+
+	gutter := "  "
+	if row == p.cursor {
+		gutter = chevron
+	} else if query != "" && strings.Contains(strings.ToLower(stripAnsi(line.text)), query) {
+		gutter = "* "
+	}
+
+	fold := ""
+	switch {
+	case line.header && p.groups[line.group].folded:
+		fold = "▸ "
+	case line.header:
+		fold = "▾ "
+	case line.group >= 0:
+		fold = "  "
+	}
+
+	text := line.text
+	if p.showTimestamps && line.timestamp != "" {
+		text = line.timestamp + " " + text
+	}
+
+	return truncate.String(gutter+fold+text, uint(width-2)) + "\x1b[0m"
+}
+
+// Splits a job log into lines and groups. Groups start folded, like they do on GitHub
+func parseLog(content string) ([]logLine, []logGroup) {
+	lines := []logLine{}
+	groups := []logGroup{}
+	currentGroup := -1
+
+	content = strings.TrimPrefix(content, "\ufeff")
+	for _, raw := range strings.Split(strings.TrimRight(content, "\n"), "\n") {
+		raw = strings.TrimSuffix(raw, "\r")
+
+		timestamp := ""
+		if match := logTimestampPattern.FindString(raw); match != "" {
+			timestamp = strings.TrimSpace(match)
+			raw = raw[len(match):]
+		}
+
+		switch {
+		case strings.HasPrefix(raw, "##[group]"):
+			groups = append(groups, logGroup{folded: true})
+			currentGroup = len(groups) - 1
+			lines = append(lines, logLine{
+				timestamp: timestamp,
+				text:      strings.TrimPrefix(raw, "##[group]"),
+				group:     currentGroup,
+				header:    true,
+			})
+		case strings.HasPrefix(raw, "##[endgroup]"):
+			currentGroup = -1
+		default:
+			lines = append(lines, logLine{timestamp: timestamp, text: raw, group: currentGroup})
+		}
+	}
+
+	return lines, groups
+}
+
+// Joins the full job logs of a run's log archive, in the order of the number their names start with.
+// Every job starts with a line naming it outside of any group. The logs of the single steps are left out, as the job logs hold them
+func joinRunLogs(files []response.LogFile) string {
+	type jobLog struct {
+		number  int
+		name    string
+		content string
+	}
+
+	jobLogs := []jobLog{}
+	for _, file := range files {
+		if strings.Contains(file.Name, "/") {
+			continue
+		}
+		name := strings.TrimSuffix(file.Name, ".txt")
+		number, rest, found := strings.Cut(name, "_")
+		position, err := strconv.Atoi(number)
+		if !found || err != nil {
+			position, rest = -1, name
+		}
+		jobLogs = append(jobLogs, jobLog{number: position, name: rest, content: file.Content})
+	}
+	sort.SliceStable(jobLogs, func(i, j int) bool { return jobLogs[i].number < jobLogs[j].number })
+
+	builder := strings.Builder{}
+	for _, log := range jobLogs {
+		// Closes a group the previous job left open, such that the job's name isn't folded into it
+		builder.WriteString("##[endgroup]\n━━ " + log.name + " ━━\n")
+		builder.WriteString(strings.TrimRight(strings.TrimPrefix(log.content, "\ufeff"), "\n"))
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// The job to show first: one that is running or failed, as that's what you're most likely looking for
+func preferredJob(jobs []response.Job) response.Job {
+	for _, job := range jobs {
+		if job.Status == "in_progress" || job.Conclusion == "failure" {
+			return job
+		}
+	}
+	return jobs[0]
+}
+
+func stripAnsi(text string) string {
+	return ansiPattern.ReplaceAllString(text, "")
+}
+
+// How many log lines fit on the screen, leaving room for the tabs, header and help
+func logPaneHeight() int {
+	if height-12 < 5 {
+		return 5
+	}
+	return height - 12
+}
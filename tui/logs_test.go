@@ -0,0 +1,171 @@
+package tui
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/andreaswachs/lazyworkflows/model/response"
+)
+
+func TestParseLogGroupsLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		// The text and group of every line, and which lines are the titles of their groups
+		texts   []string
+		groups  []int
+		headers []int
+		// How many groups were found
+		groupCount int
+	}{
+		{
+			name:       "plain lines",
+			content:    "one\ntwo\n",
+			texts:      []string{"one", "two"},
+			groups:     []int{-1, -1},
+			groupCount: 0,
+		},
+		{
+			name:       "timestamps and carriage returns are stripped",
+			content:    "\ufeff2023-01-02T03:04:05.1234567Z one\r\n2023-01-02T03:04:06Z two\r\n",
+			texts:      []string{"one", "two"},
+			groups:     []int{-1, -1},
+			groupCount: 0,
+		},
+		{
+			name:       "a group between lines",
+			content:    "before\n##[group]Run make\nmake\n##[endgroup]\nafter",
+			texts:      []string{"before", "Run make", "make", "after"},
+			groups:     []int{-1, 0, 0, -1},
+			headers:    []int{1},
+			groupCount: 1,
+		},
+		{
+			name:       "a group opened inside a group starts a new one",
+			content:    "##[group]outer\na\n##[group]inner\nb\n##[endgroup]\nc",
+			texts:      []string{"outer", "a", "inner", "b", "c"},
+			groups:     []int{0, 0, 1, 1, -1},
+			headers:    []int{0, 2},
+			groupCount: 2,
+		},
+		{
+			name:       "an unterminated group runs to the end",
+			content:    "##[group]Run tests\nok\nstill running",
+			texts:      []string{"Run tests", "ok", "still running"},
+			groups:     []int{0, 0, 0},
+			headers:    []int{0},
+			groupCount: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lines, groups := parseLog(test.content)
+
+			if len(groups) != test.groupCount {
+				t.Errorf("Expected %v groups but got %v", test.groupCount, len(groups))
+			}
+			for _, group := range groups {
+				if !group.folded {
+					t.Errorf("Expected groups to start folded")
+				}
+			}
+
+			if len(lines) != len(test.texts) {
+				t.Fatalf("Expected %v lines but got %+v", len(test.texts), lines)
+			}
+			for i, line := range lines {
+				if line.text != test.texts[i] || line.group != test.groups[i] {
+					t.Errorf("Expected line %v to be %q in group %v, but got %q in group %v",
+						i, test.texts[i], test.groups[i], line.text, line.group)
+				}
+			}
+
+			headers := []int{}
+			for i, line := range lines {
+				if line.header {
+					headers = append(headers, i)
+				}
+			}
+			if fmt.Sprint(headers) != fmt.Sprint(test.headers) {
+				t.Errorf("Expected the headers %v but got %v", test.headers, headers)
+			}
+		})
+	}
+}
+
+func TestFindMatchMovesThroughMatchesAndUnfoldsGroups(t *testing.T) {
+	content := "start\n##[group]Build\ncompiling\nerror: missing semicolon\n##[endgroup]\n##[group]Test\nERROR: 1 test failed\n##[endgroup]\ndone"
+
+	tests := []struct {
+		name      string
+		query     string
+		direction int
+		// The index into all lines the cursor should end on, and whether its group should be unfolded
+		line     int
+		unfolded bool
+	}{
+		{name: "first match from the top", query: "error", direction: 0, line: 3, unfolded: true},
+		{name: "matching is case insensitive", query: "Test Failed", direction: 0, line: 5, unfolded: true},
+		{name: "no match keeps the cursor", query: "warning", direction: 0, line: 0},
+		{name: "previous match wraps around", query: "error", direction: -1, line: 5, unfolded: true},
+		{name: "next match skips the current line", query: "start", direction: 1, line: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pane := &logPane{}
+			pane.setContent(content)
+			pane.query = test.query
+
+			pane.findMatch(test.direction)
+
+			line := pane.lines[pane.visible[pane.cursor]]
+			if pane.visible[pane.cursor] != test.line {
+				t.Errorf("Expected the cursor on line %v but got %v (%q)", test.line, pane.visible[pane.cursor], line.text)
+			}
+			if line.group >= 0 && pane.groups[line.group].folded == test.unfolded {
+				t.Errorf("Expected the group of the match to be unfolded: %v", test.unfolded)
+			}
+		})
+	}
+}
+
+func TestToggleGroupFoldsTheGroupOfTheCursor(t *testing.T) {
+	pane := &logPane{}
+	pane.setContent("##[group]Build\ncompiling\nlinking\n##[endgroup]\ndone")
+
+	if len(pane.visible) != 2 {
+		t.Fatalf("Expected the folded group to hide its lines, but got %v visible", len(pane.visible))
+	}
+
+	pane.toggleGroup()
+	if len(pane.visible) != 4 || pane.groups[0].folded {
+		t.Errorf("Expected the group to unfold, but got %v visible", len(pane.visible))
+	}
+
+	pane.moveCursor(2)
+	pane.toggleGroup()
+	if len(pane.visible) != 2 || pane.cursor != 0 {
+		t.Errorf("Expected the group to fold with the cursor on its header, but got %v visible and cursor %v", len(pane.visible), pane.cursor)
+	}
+}
+
+func TestJoinRunLogsOrdersTheJobsAndSkipsSteps(t *testing.T) {
+	files := []response.LogFile{
+		{Name: "1_test.txt", Content: "\ufefftesting\n"},
+		{Name: "build/1_Set up job.txt", Content: "preparing"},
+		{Name: "0_build.txt", Content: "##[group]Run make\nmake\n"},
+	}
+
+	lines, _ := parseLog(joinRunLogs(files))
+
+	texts := []string{}
+	for _, line := range lines {
+		texts = append(texts, fmt.Sprintf("%v:%s", line.group, line.text))
+	}
+	expected := []string{"-1:━━ build ━━", "0:Run make", "0:make", "-1:━━ test ━━", "-1:testing"}
+	if fmt.Sprint(texts) != fmt.Sprint(expected) {
+		t.Errorf("Expected the lines %q but got %q", expected, texts)
+	}
+}
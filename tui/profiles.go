@@ -0,0 +1,131 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andreaswachs/lazyworkflows/appconfig"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Lists the profiles of the config to switch to another one while the UI runs. The first entry goes
+// back to the repos at the top of the config
+type profilePicker struct {
+	active bool
+	names  []string
+	cursor int
+}
+
+// Sent once the config was read again with another profile
+type profileSwitchedMsg struct {
+	name string
+	conf appconfig.AppConfig
+	err  error
+}
+
+func (m *model) openProfilePicker() {
+	names := m.conf.ProfileNames()
+	if len(names) == 0 {
+		m.setStatus("The config has no profiles to switch between", true)
+		return
+	}
+
+	names = append([]string{appconfig.NoProfile}, names...)
+	cursor := 0
+	for i, name := range names {
+		if m.inUse(name) {
+			cursor = i
+		}
+	}
+	m.picker = profilePicker{active: true, names: names, cursor: cursor}
+}
+
+// Whether the profile is the one in use. Without a profile in use, the repos at the top of the config are
+func (m *model) inUse(name string) bool {
+	return name == m.conf.Profile || (name == appconfig.NoProfile && m.conf.Profile == "")
+}
+
+func profileLabel(name string) string {
+	if name == appconfig.NoProfile {
+		return "(no profile)"
+	}
+	return name
+}
+
+// Handles the keys while the profile picker is open
+func (m *model) updateProfilePicker(msg tea.KeyMsg) tea.Cmd {
+	picker := &m.picker
+
+	switch msg.String() {
+	case "esc", "q", "P":
+		picker.active = false
+	case "j", "down":
+		if picker.cursor < len(picker.names)-1 {
+			picker.cursor++
+		}
+	case "k", "up":
+		if picker.cursor > 0 {
+			picker.cursor--
+		}
+	case "enter":
+		picker.active = false
+		if name := picker.names[picker.cursor]; !m.inUse(name) {
+			return switchProfile(m.conf, name)
+		}
+	}
+
+	return nil
+}
+
+// Reads the config again with another profile. The current profile stays when that fails
+func switchProfile(conf appconfig.AppConfig, name string) tea.Cmd {
+	return func() tea.Msg {
+		switched, err := conf.Reload(name)
+		return profileSwitchedMsg{name: name, conf: *switched, err: err}
+	}
+}
+
+func (m *model) handleProfileSwitched(msg profileSwitchedMsg) tea.Cmd {
+	if msg.err != nil {
+		m.setStatus(fmt.Sprintf("Could not switch to profile %s: %v", profileLabel(msg.name), msg.err), true)
+		return nil
+	}
+
+	// Reloads of the config keep to the profile picked here
+	m.profile = msg.name
+	m.setStatus(fmt.Sprintf("Switched to profile %s", profileLabel(msg.name)), false)
+
+	// The workflow, run and logs shown may be of a repo the profile doesn't have
+	m.selected = nil
+	m.runPane = nil
+	m.logs = nil
+	var switched tea.Cmd
+	if m.selectedTab == workflow || m.selectedTab == runView || m.selectedTab == logs {
+		switched = m.switchTab(overview)
+	}
+	return tea.Batch(switched, m.applyConfig(msg.conf))
+}
+
+func renderProfilePicker(builder *strings.Builder, m *model) {
+	lines := []string{"Switch to profile", ""}
+	for i, name := range m.picker.names {
+		label := profileLabel(name)
+		if m.inUse(name) {
+			label += " (in use)"
+		}
+		if i == m.picker.cursor {
+			lines = append(lines, activeButtonStyle.Render(label))
+		} else {
+			lines = append(lines, buttonStyle.Render(label))
+		}
+	}
+
+	ui := lipgloss.JoinVertical(lipgloss.Center, lines...)
+	builder.WriteString(lipgloss.Place(width, len(lines)+6,
+		lipgloss.Center, lipgloss.Center,
+		dialogBoxStyle.Render(ui),
+		lipgloss.WithWhitespaceChars(" "),
+	))
+	builder.WriteString("\n\nj/k: select • enter: switch • esc: cancel\n")
+}
@@ -0,0 +1,103 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/andreaswachs/lazyworkflows/appconfig"
+	"github.com/andreaswachs/lazyworkflows/meta"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// How often the config files are checked for changes
+const configPollInterval = 2 * time.Second
+
+// Sent when the config files should be checked for changes
+type configTickMsg struct{}
+
+// Sent once the config files were checked. The config is only read when they changed
+type configCheckedMsg struct {
+	stamp   string
+	changed bool
+	conf    appconfig.AppConfig
+	err     error
+}
+
+func configTick() tea.Cmd {
+	return tea.Tick(configPollInterval, func(time.Time) tea.Msg {
+		return configTickMsg{}
+	})
+}
+
+// Identifies which config files are read and when they last changed. A project config showing up or
+// going away changes it as well
+func configStamp() string {
+	parts := []string{}
+	for _, path := range appconfig.Paths() {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s@%d@%d", path, info.ModTime().UnixNano(), info.Size()))
+	}
+	return strings.Join(parts, "|")
+}
+
+// Reads the config again with the profile picked in the UI, if any, if its files changed since the stamp was taken
+func checkConfig(stamp string, conf appconfig.AppConfig, profile string) tea.Cmd {
+	return func() tea.Msg {
+		current := configStamp()
+		if current == stamp {
+			return configCheckedMsg{stamp: stamp}
+		}
+
+		reloaded, err := conf.Reload(profile)
+		return configCheckedMsg{stamp: current, changed: true, conf: *reloaded, err: err}
+	}
+}
+
+// Applies a changed config to the store. An invalid config is shown in a banner and the previous one is
+// kept until the files are fixed
+func (m *model) handleConfigChecked(msg configCheckedMsg) tea.Cmd {
+	if !msg.changed {
+		return nil
+	}
+
+	m.configStamp = msg.stamp
+	if msg.err != nil {
+		m.configErr = msg.err
+		return nil
+	}
+
+	m.configErr = nil
+	m.setStatus("The config was reloaded", false)
+	return m.applyConfig(msg.conf)
+}
+
+// Hands the repos of a new config to the store and loads the ones that were added
+func (m *model) applyConfig(conf appconfig.AppConfig) tea.Cmd {
+	m.conf = conf
+	m.store.Reconfigure(conf)
+	m.refreshRows()
+
+	cmds := []tea.Cmd{loadRepos(m.viewCtx, m.store)}
+	if m.store.HasPatterns() && !m.discovering {
+		m.discovering = true
+		cmds = append(cmds, discoverTick(m.conf.DiscoveryInterval))
+	}
+	return tea.Batch(cmds...)
+}
+
+// Tells that the config on disk is invalid and the previous one is still used
+func renderConfigBanner(builder *strings.Builder, m *model) {
+	lines := strings.Split(m.configErr.Error(), "\n")
+
+	message := "The config was not reloaded: " + lines[0]
+	if len(lines) > 1 {
+		message += fmt.Sprintf(" (and %d more, see %s config validate)", len(lines)-1, meta.AppName)
+	}
+	builder.WriteString(errorMessageStyle(message))
+	builder.WriteString("\n")
+}
@@ -0,0 +1,415 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andreaswachs/lazyworkflows/appconfig"
+	"github.com/andreaswachs/lazyworkflows/model/definition"
+	"github.com/andreaswachs/lazyworkflows/model/response"
+	"github.com/andreaswachs/lazyworkflows/orchestrator"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/truncate"
+)
+
+// The run pane shows the jobs of one attempt of a run ordered by their dependencies,
+// and the steps of the selected job
+type runPane struct {
+	repo       appconfig.Repo
+	workflowId string
+	run        response.Run
+	attempt    int
+	// Position of the selected job when the jobs are read column by column
+	cursor int
+
+	// The workflow file the run was started from, which holds the needs of every job
+	definition       definition.Definition
+	definitionLoaded bool
+	definitionErr    error
+
+	// Whether a refresh of the jobs is already scheduled
+	ticking bool
+	// Whether an earlier attempt was picked. Otherwise new attempts, e.g. from a re-run, are shown as they start
+	pinned bool
+}
+
+// Sent when the workflow file of a run has been fetched, or failed to be
+type definitionLoadedMsg struct {
+	runId      string
+	definition definition.Definition
+	err        error
+}
+
+// Sent when the jobs of a run in progress should be fetched again
+type runTickMsg struct {
+	runId string
+}
+
+func newRunPane(repo appconfig.Repo, workflowId string, run response.Run) *runPane {
+	attempt := run.RunAttempt
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	return &runPane{
+		repo:       repo,
+		workflowId: workflowId,
+		run:        run,
+		attempt:    attempt,
+	}
+}
+
+// Fetches the workflow file at the commit the run was started from
+func loadDefinition(ctx context.Context, store *orchestrator.Orchestrator, repo appconfig.Repo, run response.Run) tea.Cmd {
+	return func() tea.Msg {
+		parsed, err := store.Definition(ctx, repo, run.Path, run.HeadSha)
+		return definitionLoadedMsg{runId: run.Id.String(), definition: parsed, err: err}
+	}
+}
+
+// Opens the run selected in the runs table of the workflow tab
+func (m *model) openSelectedRun() tea.Cmd {
+	runs := m.store.Runs(m.selected.repo, m.selected.workflowId).Runs
+	cursor := m.runsTable.Cursor()
+	if cursor < 0 || cursor >= len(runs) || runs[cursor].Id == "" {
+		return nil
+	}
+
+	m.runPane = newRunPane(m.selected.repo, m.selected.workflowId, runs[cursor])
+	return m.switchTab(runView)
+}
+
+// Fetches what the run tab shows. The workflow file never changes for a run, so it is only fetched once
+func (m *model) loadRun() tea.Cmd {
+	pane := m.runPane
+	cmds := []tea.Cmd{loadJobs(m.viewCtx, m.store, pane.repo, pane.run.Id.String(), pane.attempt)}
+	if !pane.definitionLoaded {
+		cmds = append(cmds, loadDefinition(m.viewCtx, m.store, pane.repo, pane.run))
+	}
+	if !pane.ticking {
+		pane.ticking = true
+		cmds = append(cmds, runTick(pane.run.Id.String()))
+	}
+	return tea.Batch(cmds...)
+}
+
+func runTick(runId string) tea.Cmd {
+	return tea.Tick(followInterval, func(time.Time) tea.Msg {
+		return runTickMsg{runId: runId}
+	})
+}
+
+func (m *model) handleDefinitionLoaded(msg definitionLoadedMsg) {
+	if m.runPane == nil || msg.runId != m.runPane.run.Id.String() {
+		return
+	}
+
+	// A cancelled fetch is tried again when the tab is opened the next time
+	if msg.err != nil && m.viewCtx.Err() != nil {
+		return
+	}
+
+	m.runPane.definition = msg.definition
+	m.runPane.definitionErr = msg.err
+	m.runPane.definitionLoaded = true
+}
+
+// Moves on to the latest attempt of the shown run when a re-run has started a new one
+func (m *model) followLatestAttempt() tea.Cmd {
+	pane := m.runPane
+	if pane == nil || pane.pinned || m.selectedTab != runView {
+		return nil
+	}
+
+	latest := m.currentRun().RunAttempt
+	if latest <= pane.attempt {
+		return nil
+	}
+
+	pane.attempt = latest
+	pane.cursor = 0
+	return m.loadRun()
+}
+
+// Keeps fetching the jobs while the run tab is open and the attempt is still in progress
+func (m *model) handleRunTick(msg runTickMsg) tea.Cmd {
+	pane := m.runPane
+	if pane == nil || msg.runId != pane.run.Id.String() {
+		return nil
+	}
+
+	pane.ticking = false
+	if m.selectedTab != runView || !m.runInProgress() {
+		return nil
+	}
+
+	pane.ticking = true
+	return tea.Batch(
+		loadJobs(m.viewCtx, m.store, pane.repo, msg.runId, pane.attempt),
+		runTick(msg.runId),
+	)
+}
+
+// Whether the shown attempt still has jobs that haven't completed
+func (m *model) runInProgress() bool {
+	jobs := m.store.Jobs(m.runPane.repo, m.runPane.run.Id.String(), m.runPane.attempt)
+	if jobs.Status != orchestrator.Loaded || len(jobs.Jobs) == 0 {
+		return m.currentRun().Status != "completed"
+	}
+
+	for _, job := range jobs.Jobs {
+		if job.Status != "completed" {
+			return true
+		}
+	}
+	return false
+}
+
+// The shown run as it is in the store, which is newer than the copy the pane was opened with
+func (m *model) currentRun() response.Run {
+	for _, run := range m.store.Runs(m.runPane.repo, m.runPane.workflowId).Runs {
+		if run.Id == m.runPane.run.Id {
+			return run
+		}
+	}
+	return m.runPane.run
+}
+
+// Handles the keys of the run tab
+func (m *model) updateRun(msg tea.KeyMsg) tea.Cmd {
+	pane := m.runPane
+	if pane == nil {
+		return nil
+	}
+
+	jobs := pane.orderedJobs(m.store.Jobs(pane.repo, pane.run.Id.String(), pane.attempt).Jobs)
+
+	var selectedJob *response.Job
+	if pane.cursor < len(jobs) {
+		selectedJob = &jobs[pane.cursor]
+	}
+	target := workflowTarget{repo: pane.repo, workflowId: pane.workflowId}
+	if cmd, ok := m.runActionKeys(msg.String(), target, m.currentRun(), selectedJob); ok {
+		return cmd
+	}
+
+	switch msg.String() {
+	case "esc":
+		return m.switchTab(workflow)
+	case "j", "down":
+		if pane.cursor < len(jobs)-1 {
+			pane.cursor++
+		}
+	case "k", "up":
+		if pane.cursor > 0 {
+			pane.cursor--
+		}
+	case "enter":
+		if pane.cursor < len(jobs) {
+			m.logs = newLogPane(pane.repo, m.currentRun(), pane.attempt, jobs[pane.cursor])
+			return m.switchTab(logs)
+		}
+	case "[":
+		return m.switchAttempt(pane.attempt - 1)
+	case "]":
+		return m.switchAttempt(pane.attempt + 1)
+	case "r":
+		return loadJobs(m.viewCtx, m.store, pane.repo, pane.run.Id.String(), pane.attempt)
+	}
+
+	return nil
+}
+
+// Shows another attempt of the run. Attempts are numbered from 1 up to the latest one
+func (m *model) switchAttempt(attempt int) tea.Cmd {
+	if attempt < 1 || attempt > m.currentRun().RunAttempt {
+		return nil
+	}
+
+	m.runPane.attempt = attempt
+	m.runPane.cursor = 0
+	m.runPane.pinned = attempt != m.currentRun().RunAttempt
+	return loadJobs(m.viewCtx, m.store, m.runPane.repo, m.runPane.run.Id.String(), attempt)
+}
+
+// Groups the jobs into columns by the needs in the workflow file, such that every job comes after the jobs it needs.
+// Jobs that can't be matched with the file, e.g. because it couldn't be fetched, are put in a column at the end
+func (p *runPane) columns(jobs []response.Job) [][]response.Job {
+	if !p.definitionLoaded || p.definitionErr != nil || len(p.definition.Jobs) == 0 {
+		return [][]response.Job{jobs}
+	}
+
+	levels := p.definition.Levels()
+	levelOf := make(map[string]int)
+	for i, level := range levels {
+		for _, id := range level {
+			levelOf[id] = i
+		}
+	}
+
+	columns := make([][]response.Job, len(levels))
+	unmatched := []response.Job{}
+	for _, job := range jobs {
+		id, ok := p.definition.JobId(job.Name)
+		if !ok {
+			unmatched = append(unmatched, job)
+			continue
+		}
+		columns[levelOf[id]] = append(columns[levelOf[id]], job)
+	}
+
+	nonEmpty := [][]response.Job{}
+	for _, column := range append(columns, unmatched) {
+		if len(column) > 0 {
+			nonEmpty = append(nonEmpty, column)
+		}
+	}
+	return nonEmpty
+}
+
+// The jobs in the order the cursor moves through them: column by column, top to bottom
+func (p *runPane) orderedJobs(jobs []response.Job) []response.Job {
+	ordered := []response.Job{}
+	for _, column := range p.columns(jobs) {
+		ordered = append(ordered, column...)
+	}
+	return ordered
+}
+
+// The ids of the jobs the given job needs, as declared in the workflow file
+func (p *runPane) needs(job response.Job) []string {
+	id, ok := p.definition.JobId(job.Name)
+	if !ok {
+		return nil
+	}
+	return p.definition.Jobs[id].Needs
+}
+
+func renderRun(builder *strings.Builder, m *model) {
+	pane := m.runPane
+	if pane == nil {
+		builder.WriteString("Select a run in the Workflow tab and press enter to see its jobs here\n")
+		return
+	}
+
+	current := m.currentRun()
+	title := current.DisplayTitle
+	if title == "" {
+		title = current.Name
+	}
+	builder.WriteString(fmt.Sprintf("%s/%s %s #%d %s %s %s %s %s attempt %d of %d\n\n",
+		pane.repo.Owner, pane.repo.Repo, divider,
+		current.RunNumber, title, divider,
+		statusIcon(current.Status, current.Conclusion), current.Status, divider,
+		pane.attempt, current.RunAttempt))
+
+	jobs := m.store.Jobs(pane.repo, pane.run.Id.String(), pane.attempt)
+	switch {
+	case jobs.Status == orchestrator.Failed:
+		builder.WriteString("✗ " + jobs.Err.Error() + "\n")
+	case len(jobs.Jobs) == 0 && jobs.Status != orchestrator.Loaded:
+		builder.WriteString(m.spinner.View() + " Loading jobs...\n")
+	case len(jobs.Jobs) == 0:
+		builder.WriteString("No jobs in this attempt\n")
+	default:
+		if pane.definitionErr != nil {
+			builder.WriteString(fmt.Sprintf("Jobs are not ordered by their needs: %s\n\n", pane.definitionErr))
+		}
+		renderJobGraph(builder, pane, jobs.Jobs)
+		builder.WriteString("\n")
+		renderSteps(builder, pane, jobs.Jobs)
+	}
+
+	builder.WriteString("\nenter: read job log • [/]: previous/next attempt • r: refresh • esc: back\n")
+	builder.WriteString("c/C: cancel/force cancel • R: re-run • F: re-run failed jobs • J: re-run job\n")
+}
+
+// Renders the columns of jobs side by side, with arrows pointing from the jobs needed to the jobs needing them
+func renderJobGraph(builder *strings.Builder, pane *runPane, jobs []response.Job) {
+	columns := pane.columns(jobs)
+
+	columnWidth := (width-4)/len(columns) - 3
+	if columnWidth > 40 {
+		columnWidth = 40
+	}
+	if columnWidth < 16 {
+		columnWidth = 16
+	}
+
+	parts := []string{}
+	index := 0
+	for i, column := range columns {
+		if i > 0 {
+			parts = append(parts, " → ")
+		}
+
+		lines := []string{}
+		for _, job := range column {
+			marker := "  "
+			if index == pane.cursor {
+				marker = chevron
+			}
+			index++
+
+			label := fmt.Sprintf("%s %s %s", statusIcon(job.Status, job.Conclusion), job.Name, spanDuration(job.Status, job.StartedAt, job.CompletedAt))
+			lines = append(lines, marker+truncate.String(label, uint(columnWidth-2)))
+		}
+
+		parts = append(parts, lipgloss.NewStyle().Width(columnWidth).Render(strings.Join(lines, "\n")))
+	}
+
+	builder.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, parts...))
+	builder.WriteString("\n")
+}
+
+// Renders the steps of the selected job
+func renderSteps(builder *strings.Builder, pane *runPane, jobs []response.Job) {
+	ordered := pane.orderedJobs(jobs)
+	if pane.cursor >= len(ordered) {
+		return
+	}
+	job := ordered[pane.cursor]
+
+	builder.WriteString(listHeader(fmt.Sprintf("%s %s", job.Name, spanDuration(job.Status, job.StartedAt, job.CompletedAt))))
+	builder.WriteString("\n")
+	if needs := pane.needs(job); len(needs) > 0 {
+		builder.WriteString(fmt.Sprintf("Needs   %s\n", strings.Join(needs, ", ")))
+	}
+	if job.RunnerName != "" || len(job.Labels) > 0 {
+		builder.WriteString(fmt.Sprintf("Runner  %s %s\n", job.RunnerName, strings.Join(job.Labels, ", ")))
+	}
+
+	if len(job.Steps) == 0 {
+		builder.WriteString("No steps have started yet\n")
+		return
+	}
+
+	for _, step := range job.Steps {
+		builder.WriteString(listItem(fmt.Sprintf("%s %2d. %s %s",
+			statusIcon(step.Status, step.Conclusion), step.Number, step.Name,
+			spanDuration(step.Status, step.StartedAt, step.CompletedAt))))
+		builder.WriteString("\n")
+	}
+}
+
+// How long a job or step took, or has been running for so far
+func spanDuration(status string, startedAt string, completedAt string) string {
+	started, err := time.Parse(time.RFC3339, startedAt)
+	if err != nil {
+		return ""
+	}
+
+	ended := time.Now()
+	if status == "completed" {
+		completed, err := time.Parse(time.RFC3339, completedAt)
+		if err != nil {
+			return ""
+		}
+		ended = completed
+	}
+
+	return formatDuration(ended.Sub(started))
+}
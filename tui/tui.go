@@ -1,13 +1,15 @@
 package tui
 
-// TODO: UI is unresponsive.. check out https://github.com/charmbracelet/bubbletea/blob/79c76c680b1a6bae9cd9bc918c1d8eb336ee4ceb/examples/list-fancy/main.go
-// to see what we're not doing right
 import (
+	"context"
+	"fmt"
 	"math"
 	"strings"
 
 	"github.com/andreaswachs/lazyworkflows/appconfig"
 	"github.com/andreaswachs/lazyworkflows/consumer"
+	"github.com/andreaswachs/lazyworkflows/orchestrator"
+	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/table"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -18,6 +20,9 @@ type tabState uint8
 const (
 	overview tabState = iota
 	workflow
+	runView
+	logs
+	diagnostics
 )
 
 type model struct {
@@ -25,6 +30,39 @@ type model struct {
 	selectedTab tabState
 	cursorPos   map[tabState]int
 	fullTable   table.Model
+	// Requests made on behalf of the selected tab. They are cancelled when leaving it
+	viewCtx    context.Context
+	cancelView context.CancelFunc
+	// The UI only renders what the store holds and asks it to make changes
+	store       *orchestrator.Orchestrator
+	events      <-chan orchestrator.Event
+	unsubscribe func()
+	spinner     spinner.Model
+	// The workflow each overview row points at, if any
+	rowTargets []workflowTarget
+	// The workflow shown in the workflow tab
+	selected     *workflowTarget
+	runsTable    table.Model
+	runsFocused  bool
+	dispatchForm dispatchForm
+	// The run shown in the run tab
+	runPane *runPane
+	// The job whose log is shown in the logs tab
+	logs *logPane
+	// Asks before cancelling or re-running a run
+	dialog confirmDialog
+	// Switches between the profiles of the config
+	picker profilePicker
+	// The profile picked in the UI, kept when the config is reloaded. Empty until one is picked
+	profile string
+	// A message about the last action, shown above the help line
+	statusMessage string
+	statusIsError bool
+	// When the config files were read, and why the last change to them couldn't be applied
+	configStamp string
+	configErr   error
+	// Whether the repos of the patterns in the config are discovered again now and then
+	discovering bool
 }
 
 // InitialModel returns an inital model to bootstrap the UI
@@ -44,82 +82,225 @@ func InitialModel(appconfig appconfig.AppConfig) model {
 		{Title: "Name", Width: 80}, // This width needs to be dynamic
 	}
 
-	rows := []table.Row{}
-	api := consumer.New()
+	// Every repo starts out loading. The workflows are fetched once the program runs
+	store := orchestrator.New(consumer.New(appconfig), appconfig)
+	events, unsubscribe := store.Subscribe()
 
-	// This should not be the responsibility of the UI to handle workflows
-	for _, repo := range appconfig.Repos {
-		workflows, err := api.List(repo)
-		if err != nil {
-			panic(err)
-		}
-
-		for _, workflow := range workflows {
-			rows = append(rows, table.Row{repo.Owner, repo.Repo, workflow.Name})
-		}
-	}
+	viewCtx, cancelView := context.WithCancel(context.Background())
 
 	fullTable := table.New(
 		table.WithColumns(columns),
-		table.WithRows(rows),
 		table.WithFocused(true),
 		table.WithHeight(10),
 	)
 
 	fullTable.SetStyles(tableStyle)
 
-	return model{conf: appconfig, selectedTab: workflow, cursorPos: cursorPos, fullTable: fullTable}
+	m := model{
+		conf:        appconfig,
+		selectedTab: overview,
+		cursorPos:   cursorPos,
+		fullTable:   fullTable,
+		viewCtx:     viewCtx,
+		cancelView:  cancelView,
+		store:       store,
+		events:      events,
+		unsubscribe: unsubscribe,
+		spinner:     spinner.New(spinner.WithSpinner(spinner.Dot)),
+		runsTable:   newRunsTable(),
+		configStamp: configStamp(),
+		discovering: store.HasPatterns(),
+	}
+	m.refreshRows()
+
+	return m
 }
 
 func (m model) Init() tea.Cmd {
-	return nil
+	cmds := []tea.Cmd{
+		m.spinner.Tick,
+		waitForStoreChange(m.events),
+		loadRepos(m.viewCtx, m.store),
+		configTick(),
+	}
+	if m.discovering {
+		cmds = append(cmds, discoverTick(m.conf.DiscoveryInterval))
+	}
+	return tea.Batch(cmds...)
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 
+	case storeChangedMsg:
+		if msg.event.Kind == orchestrator.ReposChanged && msg.event.Err != nil {
+			m.setStatus(msg.event.Err.Error(), true)
+		}
+		m.refreshRows()
+		m.refreshRuns()
+		return m, tea.Batch(m.handleJobsChanged(msg.event), m.followLatestAttempt(), waitForStoreChange(m.events))
+	case mutationDoneMsg:
+		return m, m.handleMutationDone(msg)
+	case dispatchPreparedMsg:
+		return m, m.handleDispatchPrepared(msg)
+	case reloadRunsMsg:
+		if m.selectedTab == runView && m.runPane != nil {
+			return m, tea.Batch(loadRuns(m.viewCtx, m.store, msg.target), m.loadRun())
+		}
+		return m, loadRuns(m.viewCtx, m.store, msg.target)
+	case discoverTickMsg:
+		// The patterns may have been removed from the config since
+		if !m.store.HasPatterns() {
+			m.discovering = false
+			return m, nil
+		}
+		return m, tea.Batch(discoverRepos(m.store), discoverTick(m.conf.DiscoveryInterval))
+	case configTickMsg:
+		return m, checkConfig(m.configStamp, m.conf, m.profile)
+	case configCheckedMsg:
+		return m, tea.Batch(m.handleConfigChecked(msg), configTick())
+	case profileSwitchedMsg:
+		return m, m.handleProfileSwitched(msg)
+	case definitionLoadedMsg:
+		m.handleDefinitionLoaded(msg)
+		return m, nil
+	case runTickMsg:
+		return m, m.handleRunTick(msg)
+	case logLoadedMsg:
+		return m, m.handleLogLoaded(msg)
+	case followTickMsg:
+		return m, m.handleFollowTick(msg)
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		if m.isLoading() {
+			m.refreshRows()
+		}
+		return m, cmd
+	// Text typed into the dispatch form or log search must not trigger any shortcuts
+	case tea.KeyMsg:
+		if m.dialog.active && msg.String() != "ctrl+c" {
+			return m, m.updateDialog(msg)
+		}
+		if m.picker.active && msg.String() != "ctrl+c" {
+			return m, m.updateProfilePicker(msg)
+		}
+		if m.selectedTab == workflow && m.dispatchForm.active && msg.String() != "ctrl+c" {
+			return m, m.updateWorkflow(msg)
+		}
+		if m.selectedTab == logs && m.logs != nil && m.logs.searching && msg.String() != "ctrl+c" {
+			return m, m.updateLogs(msg)
+		}
+		return m.updateKeys(msg)
 	case tea.WindowSizeMsg:
 		width = msg.Width
+		height = msg.Height
 		m.fullTable.SetWidth(msg.Width - 2)
 		return m, nil
-	// Is it a key press?
-	case tea.KeyMsg:
+	}
+	return m, nil
+}
 
+// Handles the keys shared by every tab, and hands the rest to the selected tab
+func (m model) updateKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		m.cancelView()
+		m.unsubscribe()
+		return m, tea.Quit
+	case "h", "left":
+		return m, m.switchTab(previousTab(m.selectedTab))
+	case "l", "right":
+		return m, m.switchTab(nextTab(m.selectedTab))
+	case "P":
+		m.openProfilePicker()
+		return m, nil
+	}
+
+	switch m.selectedTab {
+	case overview:
 		switch msg.String() {
-		case "ctrl+c", "q":
-			return m, tea.Quit
 		case "j", "down":
 			m.fullTable.MoveDown(1)
-			return m, nil
 		case "k", "up":
 			m.fullTable.MoveUp(1)
-			return m, nil
-		case "h", "left":
-			m.selectedTab = previousTab(m.selectedTab)
-			return m, nil
-		case "l", "right":
-			m.selectedTab = nextTab(m.selectedTab)
-			return m, nil
+		case "enter":
+			return m, m.openSelectedWorkflow()
 		}
-
-		// Return the updated model to the Bubble Tea runtime for processing.
-		// Note that we're not returning a command.
+	case workflow:
+		return m, m.updateWorkflow(msg)
+	case runView:
+		return m, m.updateRun(msg)
+	case logs:
+		return m, m.updateLogs(msg)
 	}
+
 	return m, nil
 }
 
+func (m *model) setStatus(message string, isError bool) {
+	m.statusMessage = message
+	m.statusIsError = isError
+}
+
+// Selects another tab, aborting whatever the previous tab was still waiting for
+func (m *model) switchTab(selectedTab tabState) tea.Cmd {
+	if selectedTab == m.selectedTab {
+		return nil
+	}
+
+	m.cancelView()
+	m.viewCtx, m.cancelView = context.WithCancel(context.Background())
+	m.selectedTab = selectedTab
+
+	// Picks up the loads that were aborted when the tab was left
+	switch {
+	case selectedTab == overview:
+		return loadRepos(m.viewCtx, m.store)
+	case selectedTab == workflow && m.selected != nil:
+		return loadRuns(m.viewCtx, m.store, *m.selected)
+	case selectedTab == runView && m.runPane != nil:
+		return m.loadRun()
+	case selectedTab == logs && m.logs != nil && m.logs.job.Id != "":
+		return tea.Batch(
+			loadJobs(m.viewCtx, m.store, m.logs.repo, m.logs.runId, m.logs.attempt),
+			loadJobLog(m.viewCtx, m.store, m.logs.repo, m.logs.job.Id.String()),
+		)
+	case selectedTab == logs && m.logs != nil:
+		return loadJobs(m.viewCtx, m.store, m.logs.repo, m.logs.runId, m.logs.attempt)
+	}
+
+	return nil
+}
+
 func (m model) View() string {
 	builder := strings.Builder{}
 
 	renderTabs(&builder, &m)
 	builder.WriteString("\n")
+	if m.configErr != nil {
+		renderConfigBanner(&builder, &m)
+	}
 	renderBody(&builder, &m)
 
 	builder.WriteString("\n")
 	builder.WriteString("\n")
 	builder.WriteString("\n")
+	if m.statusMessage != "" {
+		if m.statusIsError {
+			builder.WriteString(errorMessageStyle(m.statusMessage))
+		} else {
+			builder.WriteString(statusMessageStyle(m.statusMessage))
+		}
+	}
 	builder.WriteString("\n")
 	builder.WriteString("Press q or ctrl+c to quit")
+	switch {
+	case m.conf.Profile != "":
+		builder.WriteString(fmt.Sprintf(" • P: switch profile (%s)", m.conf.Profile))
+	case len(m.conf.Profiles) > 0:
+		builder.WriteString(" • P: switch profile")
+	}
 
 	return builder.String()
 }
@@ -129,6 +310,9 @@ func renderTabs(builder *strings.Builder, m *model) {
 		lipgloss.Top,
 		renderSingleTab(overview, m.selectedTab),
 		renderSingleTab(workflow, m.selectedTab),
+		renderSingleTab(runView, m.selectedTab),
+		renderSingleTab(logs, m.selectedTab),
+		renderSingleTab(diagnostics, m.selectedTab),
 	)
 	gap := tabGap.Render(strings.Repeat(" ", int(math.Abs(float64(width-len(row)-2)))))
 	row = lipgloss.JoinHorizontal(lipgloss.Bottom, row, gap)
@@ -145,11 +329,26 @@ func renderSingleTab(currentTab tabState, selectedTab tabState) string {
 }
 
 func renderBody(builder *strings.Builder, m *model) {
+	if m.dialog.active {
+		renderDialog(builder, m.dialog)
+		return
+	}
+	if m.picker.active {
+		renderProfilePicker(builder, m)
+		return
+	}
+
 	switch m.selectedTab {
 	case overview:
 		renderOverview(builder, m)
 	case workflow:
 		renderWorkflow(builder, m)
+	case runView:
+		renderRun(builder, m)
+	case logs:
+		renderLogs(builder, m)
+	case diagnostics:
+		renderDiagnostics(builder, m)
 	}
 }
 
@@ -157,16 +356,18 @@ func renderOverview(builder *strings.Builder, m *model) {
 	builder.WriteString(baseStyle.Render(m.fullTable.View()))
 }
 
-func renderWorkflow(builder *strings.Builder, m *model) {
-	builder.WriteString("TODO\n")
-}
-
 func tabStateToTab(selectedTab tabState) string {
 	switch selectedTab {
 	case overview:
 		return "Overview"
 	case workflow:
 		return "Workflow"
+	case runView:
+		return "Run"
+	case logs:
+		return "Logs"
+	case diagnostics:
+		return "Diagnostics"
 	}
 	return ""
 }
@@ -176,6 +377,12 @@ func nextTab(selectedTab tabState) tabState {
 	case overview:
 		return workflow
 	case workflow:
+		return runView
+	case runView:
+		return logs
+	case logs:
+		return diagnostics
+	case diagnostics:
 		return overview
 	}
 	return overview
@@ -184,35 +391,32 @@ func nextTab(selectedTab tabState) tabState {
 func previousTab(selectedTab tabState) tabState {
 	switch selectedTab {
 	case overview:
-		return workflow
+		return diagnostics
 	case workflow:
 		return overview
+	case runView:
+		return workflow
+	case logs:
+		return runView
+	case diagnostics:
+		return logs
 	}
 	return overview
 }
 
+// Moves the cursor through the actions of the workflow tab. The overview table keeps its own cursor
 func moveCursorDown(m *model) {
-	if m.selectedTab == overview {
-		if m.cursorPos[m.selectedTab] < len(m.conf.Repos)-1 {
-			m.cursorPos[m.selectedTab]++
-		} else {
-			m.cursorPos[m.selectedTab] = 0
-		}
+	if m.cursorPos[workflow] < len(m.workflowActions())-1 {
+		m.cursorPos[workflow]++
 	} else {
-		// TODO: Implement cursor movement for other tabs
-		m.cursorPos[m.selectedTab]++
+		m.cursorPos[workflow] = 0
 	}
 }
 
 func moveCursorUp(m *model) {
-	if m.selectedTab == overview {
-		if m.cursorPos[m.selectedTab] > 0 {
-			m.cursorPos[m.selectedTab]--
-		} else {
-			m.cursorPos[m.selectedTab] = len(m.conf.Repos) - 1
-		}
+	if m.cursorPos[workflow] > 0 {
+		m.cursorPos[workflow]--
 	} else {
-		// TODO: Implement bounds check for other tabs
-		m.cursorPos[m.selectedTab]--
+		m.cursorPos[workflow] = len(m.workflowActions()) - 1
 	}
 }
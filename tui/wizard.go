@@ -0,0 +1,553 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"github.com/andreaswachs/lazyworkflows/appconfig"
+	"github.com/andreaswachs/lazyworkflows/consumer"
+	"github.com/andreaswachs/lazyworkflows/meta"
+	"github.com/andreaswachs/lazyworkflows/model/response"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// How long verifying a token or repo may take
+const verifyTimeout = 15 * time.Second
+
+type wizardStep uint8
+
+const (
+	stepApiUrl wizardStep = iota
+	stepToken
+	stepVerifyingToken
+	stepRepos
+	// Asking for the token of a single repo, which the token of the config can't access or shouldn't be used for
+	stepRepoToken
+	stepWriting
+	stepDone
+)
+
+// Sets up the config file on the first run, checking the token and repos against the API on the way
+type wizard struct {
+	path    string
+	api     consumer.Consumer
+	step    wizardStep
+	spinner spinner.Model
+	input   textinput.Model
+	// The API of a GitHub Enterprise Server instance. It is empty for github.com
+	apiUrl string
+	// The token typed in. It is empty when the token is taken from the environment
+	token string
+	// The token from the environment, offered when nothing is typed in
+	envToken  string
+	envSource string
+	user      response.User
+	notes     []string
+	repos     []wizardRepo
+	// The repo whose own token is asked for
+	pending  appconfig.Repo
+	checking bool
+	// A problem with the last input, shown below it
+	message string
+	written bool
+	err     error
+}
+
+// A repo added in the wizard, and what was found out about it. Its token is set when it has its own
+type wizardRepo struct {
+	repo appconfig.Repo
+	note string
+}
+
+type tokenCheckedMsg struct {
+	user response.User
+	err  error
+}
+
+type repoCheckedMsg struct {
+	repo       appconfig.Repo
+	repository response.Repository
+	// The user of the repo's own token, when it has one
+	user response.User
+	err  error
+}
+
+type configWrittenMsg struct {
+	err error
+}
+
+// RunWizard asks for the API, a token and repos, verifies them against the API and writes the config file to the path.
+// It reports whether the config was written, which it isn't when the wizard is left early
+func RunWizard(path string) (bool, error) {
+	initStyles()
+
+	// Responses aren't cached, the scopes of the token are only part of fresh ones
+	api := consumer.New(appconfig.AppConfig{Cache: appconfig.CacheNone})
+
+	final, err := tea.NewProgram(newWizard(path, api)).Run()
+	if err != nil {
+		return false, err
+	}
+
+	done := final.(wizard)
+	return done.written, done.err
+}
+
+func newWizard(path string, api consumer.Consumer) wizard {
+	envToken, envSource := appconfig.TokenFromEnv()
+
+	w := wizard{
+		path:      path,
+		api:       api,
+		spinner:   spinner.New(spinner.WithSpinner(spinner.Dot)),
+		envToken:  envToken,
+		envSource: envSource,
+	}
+	w.input = textinput.New()
+	w.input.Prompt = "API:   "
+	w.input.Placeholder = "leave empty for github.com"
+	w.input.Focus()
+	return w
+}
+
+func (w wizard) tokenInput() textinput.Model {
+	input := textinput.New()
+	input.Prompt = "Token: "
+	input.EchoMode = textinput.EchoPassword
+	input.Placeholder = "ghp_..."
+	if w.envToken != "" {
+		input.Placeholder = "leave empty to use " + w.envSource
+	}
+	input.Focus()
+	return input
+}
+
+func (w wizard) repoInput() textinput.Model {
+	input := textinput.New()
+	input.Prompt = "Repo:  "
+	input.Placeholder = "owner/name, or owner/* for every repo of the owner"
+	input.Focus()
+	return input
+}
+
+func (w wizard) repoTokenInput() textinput.Model {
+	input := textinput.New()
+	input.Prompt = "Token: "
+	input.EchoMode = textinput.EchoPassword
+	input.Placeholder = "leave empty to skip the repo"
+	input.Focus()
+	return input
+}
+
+func (w wizard) Init() tea.Cmd {
+	return tea.Batch(textinput.Blink, w.spinner.Tick)
+}
+
+func (w wizard) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tokenCheckedMsg:
+		return w, w.handleTokenChecked(msg)
+	case repoCheckedMsg:
+		w.handleRepoChecked(msg)
+		return w, nil
+	case configWrittenMsg:
+		if msg.err != nil {
+			w.err = msg.err
+			return w, tea.Quit
+		}
+		w.written = true
+		w.step = stepDone
+		return w, nil
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		w.spinner, cmd = w.spinner.Update(msg)
+		return w, cmd
+	case tea.KeyMsg:
+		return w.updateKeys(msg)
+	}
+	return w, nil
+}
+
+func (w wizard) updateKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "esc":
+		return w, tea.Quit
+	case "enter":
+		switch w.step {
+		case stepApiUrl:
+			w.submitApiUrl()
+		case stepToken:
+			return w, w.submitToken()
+		case stepRepos:
+			return w, w.submitRepo(false)
+		case stepRepoToken:
+			return w, w.submitRepoToken()
+		case stepDone:
+			return w, tea.Quit
+		}
+		return w, nil
+	case "tab":
+		if w.step == stepRepos {
+			return w, w.submitRepo(true)
+		}
+		return w, nil
+	}
+
+	if w.step != stepApiUrl && w.step != stepToken && w.step != stepRepos && w.step != stepRepoToken {
+		return w, nil
+	}
+
+	var cmd tea.Cmd
+	w.input, cmd = w.input.Update(msg)
+	return w, cmd
+}
+
+// Takes the API the config is for. Only the host of a GitHub Enterprise Server instance is needed
+func (w *wizard) submitApiUrl() {
+	apiUrl, err := parseApiUrl(w.input.Value())
+	if err != nil {
+		w.message = err.Error()
+		return
+	}
+
+	w.message = ""
+	w.apiUrl = apiUrl
+	w.step = stepToken
+	w.input = w.tokenInput()
+}
+
+// Reads the API URL typed in, which is empty for github.com. The scheme defaults to https
+func parseApiUrl(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	if !strings.Contains(value, "://") {
+		value = "https://" + value
+	}
+
+	parsed, err := neturl.Parse(value)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+		return "", fmt.Errorf("%q doesn't look like the URL of a GitHub Enterprise Server instance", value)
+	}
+	if host := strings.ToLower(parsed.Host); host == "github.com" || host == "api.github.com" {
+		return "", nil
+	}
+	return strings.TrimSuffix(value, "/"), nil
+}
+
+func (w *wizard) submitToken() tea.Cmd {
+	w.token = strings.TrimSpace(w.input.Value())
+	token := w.token
+	if token == "" {
+		token = w.envToken
+	}
+	if token == "" {
+		w.message = "A token is needed to talk to the GitHub API"
+		return nil
+	}
+
+	w.message = ""
+	w.step = stepVerifyingToken
+	api := w.api
+	apiUrl := w.apiUrl
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), verifyTimeout)
+		defer cancel()
+
+		user, err := api.CurrentUser(ctx, appconfig.Repo{Token: token, ApiUrl: apiUrl})
+		return tokenCheckedMsg{user: user, err: err}
+	}
+}
+
+func (w *wizard) handleTokenChecked(msg tokenCheckedMsg) tea.Cmd {
+	if msg.err != nil {
+		w.step = stepToken
+		w.message = "The token was not accepted: " + describeApiError(msg.err)
+		w.input = w.tokenInput()
+		return nil
+	}
+
+	w.user = msg.user
+	w.notes = tokenNotes(msg.user)
+	w.step = stepRepos
+	w.input = w.repoInput()
+	return nil
+}
+
+// What the scopes of a token mean for what can be done with it
+func tokenNotes(user response.User) []string {
+	if len(user.Scopes) == 0 {
+		return []string{"The token reports no scopes, as fine-grained tokens do. Its access is checked for every repo below."}
+	}
+
+	notes := []string{"Scopes: " + strings.Join(user.Scopes, ", ")}
+	if !hasScope(user.Scopes, "repo") {
+		if hasScope(user.Scopes, "public_repo") {
+			notes = append(notes, "Without the repo scope only public repos can be used.")
+		} else {
+			notes = append(notes, "Without the repo scope workflows can be read from public repos, but not started, cancelled or re-run.")
+		}
+	}
+	return notes
+}
+
+func hasScope(scopes []string, wanted string) bool {
+	for _, scope := range scopes {
+		if scope == wanted {
+			return true
+		}
+	}
+	return false
+}
+
+// Adds the repo typed in, checking it with the token of the config, or asks for its own token first
+func (w *wizard) submitRepo(ownToken bool) tea.Cmd {
+	if w.checking {
+		return nil
+	}
+
+	value := strings.TrimSpace(w.input.Value())
+	if value == "" && ownToken {
+		return nil
+	}
+	if value == "" {
+		if len(w.repos) == 0 {
+			w.message = "Add at least one repo"
+			return nil
+		}
+		return w.writeConfig()
+	}
+
+	owner, name, found := strings.Cut(value, "/")
+	if !found || owner == "" || name == "" || strings.Contains(name, "/") {
+		w.message = fmt.Sprintf("%q doesn't look like owner/name", value)
+		return nil
+	}
+	for _, added := range w.repos {
+		if strings.EqualFold(added.repo.Owner, owner) && strings.EqualFold(added.repo.Repo, name) {
+			w.message = value + " is added already"
+			return nil
+		}
+	}
+
+	w.message = ""
+	w.input.SetValue("")
+	repo := appconfig.Repo{Owner: owner, Repo: name}
+
+	if ownToken {
+		w.askRepoToken(repo)
+		return nil
+	}
+
+	// The repos of a pattern are only known once they are discovered
+	if repo.IsPattern() {
+		w.repos = append(w.repos, wizardRepo{repo: repo, note: "every matching repo with Actions enabled is shown"})
+		return nil
+	}
+
+	w.checking = true
+	return w.checkRepo(repo, w.tokenValue())
+}
+
+func (w *wizard) askRepoToken(repo appconfig.Repo) {
+	w.pending = repo
+	w.step = stepRepoToken
+	w.input = w.repoTokenInput()
+}
+
+// Checks the repo's own token and its access to the repo. An empty token skips the repo
+func (w *wizard) submitRepoToken() tea.Cmd {
+	if w.checking {
+		return nil
+	}
+
+	token := strings.TrimSpace(w.input.Value())
+	if token == "" {
+		w.message = ""
+		w.step = stepRepos
+		w.input = w.repoInput()
+		return nil
+	}
+
+	w.checking = true
+	repo := w.pending
+	repo.Token = token
+	return w.checkRepo(repo, token)
+}
+
+// Verifies the token and the repo against the API. A repo with its own token also has its token checked
+func (w *wizard) checkRepo(repo appconfig.Repo, token string) tea.Cmd {
+	api := w.api
+	withToken := repo
+	withToken.Token = token
+	withToken.ApiUrl = w.apiUrl
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), verifyTimeout)
+		defer cancel()
+
+		user := response.User{}
+		if repo.Token != "" {
+			var err error
+			if user, err = api.CurrentUser(ctx, withToken); err != nil {
+				return repoCheckedMsg{repo: repo, err: err}
+			}
+		}
+
+		// The scopes of a pattern's token are all that can be checked, its repos are only known once discovered
+		if repo.IsPattern() {
+			return repoCheckedMsg{repo: repo, user: user}
+		}
+
+		repository, err := api.GetRepo(ctx, withToken)
+		return repoCheckedMsg{repo: repo, repository: repository, user: user, err: err}
+	}
+}
+
+func (w *wizard) handleRepoChecked(msg repoCheckedMsg) {
+	w.checking = false
+
+	if msg.err != nil {
+		w.message = fmt.Sprintf("%s/%s can't be used: %s", msg.repo.Owner, msg.repo.Repo, describeApiError(msg.err))
+		if msg.repo.Token == "" {
+			w.message += ". Enter a token for it, or leave it empty to skip the repo"
+		}
+		// A repo the token of the config can't use may be usable with a token of its own
+		w.askRepoToken(msg.repo)
+		return
+	}
+
+	note := "workflows can be started, cancelled and re-run"
+	if msg.repo.IsPattern() {
+		note = "every matching repo with Actions enabled is shown"
+	} else if permissions := msg.repository.Permissions; permissions != nil && !permissions.Push {
+		note = "read only, workflows can't be started, cancelled or re-run with this token"
+	}
+	if msg.repo.Token != "" {
+		note = fmt.Sprintf("with its own token of %s, %s", msg.user.Login, note)
+		if notes := tokenNotes(msg.user); len(notes) > 1 {
+			note += ". " + strings.Join(notes[1:], " ")
+		}
+	}
+
+	w.message = ""
+	w.repos = append(w.repos, wizardRepo{repo: msg.repo, note: note})
+	if w.step == stepRepoToken {
+		w.step = stepRepos
+		w.input = w.repoInput()
+	}
+}
+
+func (w *wizard) writeConfig() tea.Cmd {
+	conf := appconfig.AppConfig{Token: w.token, ApiUrl: w.apiUrl, Cache: appconfig.CacheMemory}
+	for _, added := range w.repos {
+		conf.Repos = append(conf.Repos, added.repo)
+	}
+
+	w.step = stepWriting
+	path := w.path
+	return func() tea.Msg {
+		return configWrittenMsg{err: appconfig.Write(path, conf)}
+	}
+}
+
+// The token the API is called with, typed in or taken from the environment
+func (w *wizard) tokenValue() string {
+	if w.token != "" {
+		return w.token
+	}
+	return w.envToken
+}
+
+// Explains the usual reasons for the API turning down a token or repo
+func describeApiError(err error) string {
+	var apiError *response.ApiError
+	if errors.As(err, &apiError) {
+		switch apiError.StatusCode {
+		case http.StatusUnauthorized:
+			return "the token is invalid or expired"
+		case http.StatusForbidden:
+			return "the token may not access it"
+		case http.StatusNotFound:
+			return "it doesn't exist or the token can't see it"
+		}
+	}
+	return err.Error()
+}
+
+func (w wizard) View() string {
+	builder := strings.Builder{}
+
+	builder.WriteString(listHeader(fmt.Sprintf("Welcome to %s", meta.AppName)))
+	builder.WriteString("\n\n")
+	builder.WriteString(fmt.Sprintf("There is no config yet. Answer a few questions to create %s\n\n", w.path))
+
+	if w.step != stepApiUrl && w.apiUrl != "" {
+		builder.WriteString(fmt.Sprintf("%sGitHub Enterprise Server at %s\n\n", checkMark, w.apiUrl))
+	}
+
+	switch w.step {
+	case stepApiUrl:
+		builder.WriteString("Enter the URL of your GitHub Enterprise Server, e.g. github.example.com, or leave it empty\n")
+		builder.WriteString("to use github.com.\n\n")
+		builder.WriteString(w.input.View())
+		builder.WriteString("\n")
+	case stepToken:
+		builder.WriteString("Paste a personal access token. Classic tokens need the repo scope, fine-grained ones\n")
+		builder.WriteString("need read and write access to Actions of the repos.\n\n")
+		builder.WriteString(w.input.View())
+		builder.WriteString("\n")
+	case stepVerifyingToken:
+		builder.WriteString(w.spinner.View() + " Checking the token...\n")
+	default:
+		builder.WriteString(fmt.Sprintf("%sThe token belongs to %s\n", checkMark, w.user.Login))
+		for _, note := range w.notes {
+			builder.WriteString(listItem(note))
+			builder.WriteString("\n")
+		}
+		builder.WriteString("\n")
+
+		for _, added := range w.repos {
+			builder.WriteString(fmt.Sprintf("%s%s/%s: %s\n", checkMark, added.repo.Owner, added.repo.Repo, added.note))
+		}
+	}
+
+	switch w.step {
+	case stepRepos:
+		builder.WriteString("\nAdd the repos to show, one at a time. Press enter on an empty line when done.\n")
+		builder.WriteString("Press tab instead of enter to give a repo its own token.\n\n")
+		builder.WriteString(w.input.View())
+		builder.WriteString("\n")
+		if w.checking {
+			builder.WriteString(w.spinner.View() + " Checking the repo...\n")
+		}
+	case stepRepoToken:
+		builder.WriteString(fmt.Sprintf("\nPaste the token to use for %s/%s.\n\n", w.pending.Owner, w.pending.Repo))
+		builder.WriteString(w.input.View())
+		builder.WriteString("\n")
+		if w.checking {
+			builder.WriteString(w.spinner.View() + " Checking the token and the repo...\n")
+		}
+	case stepWriting:
+		builder.WriteString("\n" + w.spinner.View() + " Writing the config...\n")
+	case stepDone:
+		builder.WriteString(fmt.Sprintf("\nThe config was written to %s\n", w.path))
+		builder.WriteString("Press enter to start\n")
+	}
+
+	if w.message != "" {
+		builder.WriteString("\n" + errorMessageStyle(w.message) + "\n")
+	}
+
+	if w.step != stepDone {
+		builder.WriteString("\nesc: quit without writing a config\n")
+	}
+	return appStyle.Render(builder.String())
+}
@@ -0,0 +1,243 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andreaswachs/lazyworkflows/appconfig"
+	"github.com/andreaswachs/lazyworkflows/consumer"
+	"github.com/andreaswachs/lazyworkflows/model/response"
+)
+
+// A consumer that knows one user, some repos and a workflow file. Calls that aren't overridden panic through the nil interface
+type fakeConsumer struct {
+	consumer.Consumer
+	user    response.User
+	content string
+	// The repos by their owner. Other repos aren't found
+	repos map[string][]response.Repository
+	// The repos, with their tokens and APIs, that the user or repo was looked up for
+	lookups []appconfig.Repo
+}
+
+func (f *fakeConsumer) CurrentUser(ctx context.Context, repo appconfig.Repo) (response.User, error) {
+	f.lookups = append(f.lookups, repo)
+	return f.user, nil
+}
+
+func (f *fakeConsumer) GetRepo(ctx context.Context, repo appconfig.Repo) (response.Repository, error) {
+	f.lookups = append(f.lookups, repo)
+	for _, repository := range f.repos[repo.Owner] {
+		if repository.Name == repo.Repo {
+			return repository, nil
+		}
+	}
+	return response.Repository{}, &response.ApiError{StatusCode: http.StatusNotFound, Message: "Not Found"}
+}
+
+func (f *fakeConsumer) GetContent(ctx context.Context, repo appconfig.Repo, path string, ref string) (string, error) {
+	return f.content, nil
+}
+
+func newTestWizard(t *testing.T, api *fakeConsumer) wizard {
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GH_TOKEN", "")
+	return newWizard(filepath.Join(t.TempDir(), "config.yml"), api)
+}
+
+// Types the value into the wizard's input
+func typeIn(w *wizard, value string) {
+	w.input.SetValue(value)
+}
+
+func TestTokenNotesExplainTheScopes(t *testing.T) {
+	tests := []struct {
+		scopes   []string
+		expected []string
+	}{
+		{nil, []string{"The token reports no scopes, as fine-grained tokens do. Its access is checked for every repo below."}},
+		{[]string{"repo", "workflow"}, []string{"Scopes: repo, workflow"}},
+		{[]string{"public_repo"}, []string{"Scopes: public_repo", "Without the repo scope only public repos can be used."}},
+		{[]string{"read:org"}, []string{"Scopes: read:org", "Without the repo scope workflows can be read from public repos, but not started, cancelled or re-run."}},
+	}
+
+	for _, test := range tests {
+		notes := tokenNotes(response.User{Scopes: test.scopes})
+		if strings.Join(notes, "\n") != strings.Join(test.expected, "\n") {
+			t.Errorf("Expected the notes %q for the scopes %v, but got %q", test.expected, test.scopes, notes)
+		}
+	}
+}
+
+func TestSubmitRepoParsesOwnerAndName(t *testing.T) {
+	tests := []struct {
+		value string
+		// Whether the repo is checked against the API, and the problem shown otherwise
+		checked bool
+		message string
+	}{
+		{value: "octo/repo", checked: true},
+		{value: "  octo/repo  ", checked: true},
+		{value: "octo", message: `"octo" doesn't look like owner/name`},
+		{value: "/repo", message: `"/repo" doesn't look like owner/name`},
+		{value: "octo/", message: `"octo/" doesn't look like owner/name`},
+		{value: "octo/repo/extra", message: `"octo/repo/extra" doesn't look like owner/name`},
+		{value: "Octo/Added", message: "Octo/Added is added already"},
+		{value: "octo/*"},
+	}
+
+	for _, test := range tests {
+		w := newTestWizard(t, &fakeConsumer{})
+		w.step = stepRepos
+		w.repos = []wizardRepo{{repo: appconfig.Repo{Owner: "octo", Repo: "added"}}}
+		typeIn(&w, test.value)
+
+		cmd := w.submitRepo(false)
+
+		if (cmd != nil) != test.checked || w.checking != test.checked {
+			t.Errorf("Expected %q to be checked: %v", test.value, test.checked)
+		}
+		if w.message != test.message {
+			t.Errorf("Expected the message %q for %q, but got %q", test.message, test.value, w.message)
+		}
+	}
+}
+
+func TestPatternsAreAddedWithoutChecking(t *testing.T) {
+	w := newTestWizard(t, &fakeConsumer{})
+	w.step = stepRepos
+	typeIn(&w, "octo/service-*")
+
+	if cmd := w.submitRepo(false); cmd != nil {
+		t.Errorf("Expected the pattern not to be checked")
+	}
+	if len(w.repos) != 1 || w.repos[0].repo.Repo != "service-*" {
+		t.Errorf("Expected the pattern to be added, but got %+v", w.repos)
+	}
+}
+
+func TestEmptyRepoFinishesOnceReposWereAdded(t *testing.T) {
+	w := newTestWizard(t, &fakeConsumer{})
+	w.step = stepRepos
+
+	if cmd := w.submitRepo(false); cmd != nil || w.message != "Add at least one repo" {
+		t.Errorf("Expected a repo to be asked for, but got %q", w.message)
+	}
+
+	w.repos = []wizardRepo{{repo: appconfig.Repo{Owner: "octo", Repo: "repo"}}}
+	if cmd := w.submitRepo(false); cmd == nil || w.step != stepWriting {
+		t.Errorf("Expected the config to be written, but the wizard is at step %v", w.step)
+	}
+}
+
+func TestDescribeApiErrorExplainsTheStatus(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{&response.ApiError{StatusCode: http.StatusUnauthorized, Message: "Bad credentials"}, "the token is invalid or expired"},
+		{&response.ApiError{StatusCode: http.StatusForbidden, Message: "Forbidden"}, "the token may not access it"},
+		{&response.ApiError{StatusCode: http.StatusNotFound, Message: "Not Found"}, "it doesn't exist or the token can't see it"},
+		{&response.ApiError{StatusCode: http.StatusBadGateway, Message: "Bad Gateway"}, "GitHub API responded with 502: Bad Gateway"},
+		{errors.New("dial tcp: lookup github.example.com: no such host"), "dial tcp: lookup github.example.com: no such host"},
+	}
+
+	for _, test := range tests {
+		if described := describeApiError(test.err); described != test.expected {
+			t.Errorf("Expected %q but got %q", test.expected, described)
+		}
+	}
+}
+
+func TestParseApiUrl(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+		valid    bool
+	}{
+		{"", "", true},
+		{"github.com", "", true},
+		{"https://api.github.com/", "", true},
+		{"github.example.com", "https://github.example.com", true},
+		{"http://github.example.com/api/v3/", "http://github.example.com/api/v3", true},
+		{"ftp://github.example.com", "", false},
+		{"https://", "", false},
+	}
+
+	for _, test := range tests {
+		apiUrl, err := parseApiUrl(test.value)
+		if (err == nil) != test.valid || apiUrl != test.expected {
+			t.Errorf("Expected %q to give %q (valid: %v), but got %q %v", test.value, test.expected, test.valid, apiUrl, err)
+		}
+	}
+}
+
+func TestTokenAndReposAreVerifiedAgainstTheEnterpriseServer(t *testing.T) {
+	api := &fakeConsumer{
+		user:  response.User{Login: "octocat", Scopes: []string{"repo"}},
+		repos: map[string][]response.Repository{"octo": {{Name: "repo"}}},
+	}
+	w := newTestWizard(t, api)
+
+	typeIn(&w, "github.example.com")
+	w.submitApiUrl()
+	if w.step != stepToken || w.apiUrl != "https://github.example.com" {
+		t.Fatalf("Expected the token to be asked for next, but got step %v and API %q", w.step, w.apiUrl)
+	}
+
+	typeIn(&w, "ghp_default")
+	w.handleTokenChecked(w.submitToken()().(tokenCheckedMsg))
+	typeIn(&w, "octo/repo")
+	w.handleRepoChecked(w.submitRepo(false)().(repoCheckedMsg))
+
+	if len(w.repos) != 1 {
+		t.Fatalf("Expected the repo to be added, but got %q", w.message)
+	}
+	for _, lookup := range api.lookups {
+		if lookup.ApiUrl != "https://github.example.com" || lookup.Token != "ghp_default" {
+			t.Errorf("Expected the lookups to go to the server with the token, but got %+v", lookup)
+		}
+	}
+}
+
+func TestReposTheTokenCantAccessGetTheirOwn(t *testing.T) {
+	api := &fakeConsumer{user: response.User{Login: "octocat", Scopes: []string{"repo"}}}
+	w := newTestWizard(t, api)
+	w.token = "ghp_default"
+	w.step = stepRepos
+
+	typeIn(&w, "work/service")
+	w.handleRepoChecked(w.submitRepo(false)().(repoCheckedMsg))
+	if w.step != stepRepoToken || w.pending.Repo != "service" || len(w.repos) != 0 {
+		t.Fatalf("Expected a token to be asked for the repo, but got step %v", w.step)
+	}
+
+	api.repos = map[string][]response.Repository{"work": {{Name: "service"}}}
+	typeIn(&w, "ghp_work")
+	w.handleRepoChecked(w.submitRepoToken()().(repoCheckedMsg))
+	if w.step != stepRepos || len(w.repos) != 1 || w.repos[0].repo.Token != "ghp_work" {
+		t.Fatalf("Expected the repo to be added with its own token, but got %+v (%q)", w.repos, w.message)
+	}
+
+	typeIn(&w, "octo/other")
+	w.submitRepo(true)
+	typeIn(&w, "")
+	w.submitRepoToken()
+	if w.step != stepRepos || len(w.repos) != 1 {
+		t.Errorf("Expected an empty token to skip the repo, but got %+v", w.repos)
+	}
+
+	msg := w.writeConfig()().(configWrittenMsg)
+	if msg.err != nil {
+		t.Fatalf("Expected the config to be written, but got %v", msg.err)
+	}
+	contents, _ := os.ReadFile(w.path)
+	if !strings.Contains(string(contents), "token: ghp_default") || !strings.Contains(string(contents), "    token: ghp_work") {
+		t.Errorf("Expected both tokens in the config, but got %v", string(contents))
+	}
+}
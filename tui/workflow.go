@@ -0,0 +1,549 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andreaswachs/lazyworkflows/appconfig"
+	"github.com/andreaswachs/lazyworkflows/checkout"
+	"github.com/andreaswachs/lazyworkflows/model/request"
+	"github.com/andreaswachs/lazyworkflows/model/response"
+	"github.com/andreaswachs/lazyworkflows/orchestrator"
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// How many runs are shown for the selected workflow
+const recentRunsLimit = 20
+
+// How long to wait before looking for the run a dispatch created
+const dispatchRefreshDelay = 3 * time.Second
+
+type workflowAction uint8
+
+const (
+	actionDispatch workflowAction = iota
+	actionToggle
+	actionViewRuns
+	actionRefresh
+)
+
+// Points at a single workflow of a repo
+type workflowTarget struct {
+	repo       appconfig.Repo
+	workflowId string
+}
+
+// Asks for the ref and inputs to dispatch the selected workflow with
+type dispatchForm struct {
+	active  bool
+	focused int
+	ref     textinput.Model
+	// One key=value pair per line, such that values may contain commas
+	inputs textarea.Model
+	// Whether the inputs declared by the workflow are being read to type the values
+	preparing bool
+}
+
+// Sent when the inputs of a dispatch have been typed by what the workflow declares, or failed to be
+type dispatchPreparedMsg struct {
+	target  workflowTarget
+	request request.Dispatch
+	err     error
+}
+
+// Sent when a dispatch, enable or disable has completed
+type mutationDoneMsg struct {
+	message string
+	err     error
+	// Runs to reload once the mutation went through
+	reload *workflowTarget
+}
+
+// Sent when the runs of a workflow should be fetched again
+type reloadRunsMsg struct {
+	target workflowTarget
+}
+
+func newRunsTable() table.Model {
+	runsTable := table.New(
+		table.WithColumns([]table.Column{
+			{Title: " ", Width: 2},
+			{Title: "#", Width: 6},
+			{Title: "Title", Width: 40},
+			{Title: "Branch", Width: 20},
+			{Title: "Event", Width: 18},
+			{Title: "Duration", Width: 9},
+			{Title: "Started", Width: 16},
+		}),
+		table.WithHeight(8),
+	)
+	runsTable.SetStyles(tableStyle)
+
+	return runsTable
+}
+
+func newDispatchForm(ref string) dispatchForm {
+	refInput := textinput.New()
+	refInput.Prompt = "Ref:    "
+	refInput.SetValue(ref)
+	refInput.Focus()
+
+	inputsInput := textarea.New()
+	inputsInput.ShowLineNumbers = false
+	inputsInput.SetPromptFunc(len(refInput.Prompt), func(line int) string {
+		if line == 0 {
+			return "Inputs: "
+		}
+		return ""
+	})
+	inputsInput.SetWidth(60)
+	inputsInput.SetHeight(4)
+	inputsInput.Placeholder = "key=value, one per line"
+
+	return dispatchForm{active: true, ref: refInput, inputs: inputsInput}
+}
+
+// Fetches the recent runs of a workflow. Progress arrives as storeChangedMsgs
+func loadRuns(ctx context.Context, store *orchestrator.Orchestrator, target workflowTarget) tea.Cmd {
+	return func() tea.Msg {
+		store.LoadRuns(ctx, target.repo, target.workflowId, recentRunsLimit)
+		return nil
+	}
+}
+
+// Opens the workflow of the selected overview row in the workflow tab
+func (m *model) openSelectedWorkflow() tea.Cmd {
+	cursor := m.fullTable.Cursor()
+	if cursor < 0 || cursor >= len(m.rowTargets) || m.rowTargets[cursor].workflowId == "" {
+		return nil
+	}
+
+	target := m.rowTargets[cursor]
+	m.selected = &target
+	m.cursorPos[workflow] = 0
+	m.runsFocused = false
+	m.runsTable.Blur()
+	m.refreshRuns()
+
+	return m.switchTab(workflow)
+}
+
+// The actions offered for the selected workflow, in the order they are listed
+func (m *model) workflowActions() []workflowAction {
+	return []workflowAction{actionDispatch, actionToggle, actionViewRuns, actionRefresh}
+}
+
+func (m *model) actionLabel(action workflowAction) string {
+	switch action {
+	case actionDispatch:
+		return "Dispatch"
+	case actionToggle:
+		if current, ok := m.selectedWorkflow(); ok && current.State != "active" {
+			return "Enable"
+		}
+		return "Disable"
+	case actionViewRuns:
+		return "View runs"
+	case actionRefresh:
+		return "Refresh runs"
+	}
+	return ""
+}
+
+func (m *model) selectedWorkflow() (response.Workflow, bool) {
+	if m.selected == nil {
+		return response.Workflow{}, false
+	}
+	return m.store.Workflow(m.selected.repo, m.selected.workflowId)
+}
+
+// Handles the keys of the workflow tab
+func (m *model) updateWorkflow(msg tea.KeyMsg) tea.Cmd {
+	if m.selected == nil {
+		return nil
+	}
+
+	if m.dispatchForm.active {
+		return m.updateDispatchForm(msg)
+	}
+
+	if m.runsFocused {
+		runs := m.store.Runs(m.selected.repo, m.selected.workflowId).Runs
+		cursor := m.runsTable.Cursor()
+		if cursor >= 0 && cursor < len(runs) && runs[cursor].Id != "" {
+			if cmd, ok := m.runActionKeys(msg.String(), *m.selected, runs[cursor], nil); ok {
+				return cmd
+			}
+		}
+	}
+
+	switch msg.String() {
+	case "esc":
+		if m.runsFocused {
+			m.focusRuns(false)
+			return nil
+		}
+		return m.switchTab(overview)
+	case "tab":
+		m.focusRuns(!m.runsFocused)
+	case "j", "down":
+		if m.runsFocused {
+			m.runsTable.MoveDown(1)
+		} else {
+			moveCursorDown(m)
+		}
+	case "k", "up":
+		if m.runsFocused {
+			m.runsTable.MoveUp(1)
+		} else {
+			moveCursorUp(m)
+		}
+	case "enter":
+		if m.runsFocused {
+			return m.openSelectedRun()
+		}
+		return m.runAction(m.workflowActions()[m.cursorPos[workflow]])
+	}
+
+	return nil
+}
+
+func (m *model) focusRuns(focused bool) {
+	m.runsFocused = focused
+	if focused {
+		m.runsTable.Focus()
+	} else {
+		m.runsTable.Blur()
+	}
+}
+
+// Performs one of the actions listed for the selected workflow
+func (m *model) runAction(action workflowAction) tea.Cmd {
+	target := *m.selected
+	store := m.store
+
+	switch action {
+	case actionDispatch:
+		m.dispatchForm = newDispatchForm(m.defaultRef())
+		return textinput.Blink
+	case actionToggle:
+		current, _ := m.selectedWorkflow()
+		if current.State != "active" {
+			return func() tea.Msg {
+				err := store.Enable(context.Background(), target.repo, target.workflowId)
+				return mutationDoneMsg{message: fmt.Sprintf("Enabled %s", current.Name), err: err}
+			}
+		}
+		return func() tea.Msg {
+			err := store.Disable(context.Background(), target.repo, target.workflowId)
+			return mutationDoneMsg{message: fmt.Sprintf("Disabled %s", current.Name), err: err}
+		}
+	case actionViewRuns:
+		m.focusRuns(true)
+	case actionRefresh:
+		return loadRuns(m.viewCtx, m.store, target)
+	}
+
+	return nil
+}
+
+// Handles the keys while the dispatch form is open
+func (m *model) updateDispatchForm(msg tea.KeyMsg) tea.Cmd {
+	form := &m.dispatchForm
+	if form.preparing {
+		return nil
+	}
+
+	switch msg.String() {
+	case "esc":
+		form.active = false
+		return nil
+	case "tab", "shift+tab":
+		form.focused = (form.focused + 1) % 2
+		if form.focused == 0 {
+			form.inputs.Blur()
+			return form.ref.Focus()
+		}
+		form.ref.Blur()
+		return form.inputs.Focus()
+	case "ctrl+s":
+		return m.submitDispatch()
+	case "enter":
+		// Enter starts a new line in the inputs
+		if form.focused == 0 {
+			return m.submitDispatch()
+		}
+	}
+
+	var cmd tea.Cmd
+	if form.focused == 0 {
+		form.ref, cmd = form.ref.Update(msg)
+	} else {
+		form.inputs, cmd = form.inputs.Update(msg)
+	}
+	return cmd
+}
+
+// Reads the inputs the workflow declares at the ref to type the values of the form, before dispatching it.
+// The form stays open until then, such that a value of the wrong type can be corrected
+func (m *model) submitDispatch() tea.Cmd {
+	values, err := request.ParseInputs(strings.Split(m.dispatchForm.inputs.Value(), "\n"))
+	if err != nil {
+		m.setStatus(err.Error(), true)
+		return nil
+	}
+
+	dispatchRequest := request.Dispatch{Ref: strings.TrimSpace(m.dispatchForm.ref.Value())}
+	if len(values) == 0 {
+		m.dispatchForm.active = false
+		return m.dispatch(*m.selected, dispatchRequest)
+	}
+
+	m.dispatchForm.preparing = true
+	target := *m.selected
+	store := m.store
+	current, _ := m.selectedWorkflow()
+
+	return func() tea.Msg {
+		inputs, err := store.DispatchInputs(context.Background(), target.repo, current.Path, dispatchRequest.Ref)
+		if err != nil {
+			return dispatchPreparedMsg{err: fmt.Errorf("could not read the inputs of %s on %s: %w", current.Name, dispatchRequest.Ref, err)}
+		}
+
+		dispatchRequest.Inputs, err = inputs.Type(values)
+		return dispatchPreparedMsg{target: target, request: dispatchRequest, err: err}
+	}
+}
+
+// Dispatches once the inputs are typed, or reports why they couldn't be
+func (m *model) handleDispatchPrepared(msg dispatchPreparedMsg) tea.Cmd {
+	m.dispatchForm.preparing = false
+	if msg.err != nil {
+		m.setStatus(msg.err.Error(), true)
+		return nil
+	}
+
+	m.dispatchForm.active = false
+	return m.dispatch(msg.target, msg.request)
+}
+
+func (m *model) dispatch(target workflowTarget, dispatchRequest request.Dispatch) tea.Cmd {
+	store := m.store
+	current, _ := m.store.Workflow(target.repo, target.workflowId)
+
+	return func() tea.Msg {
+		err := store.Dispatch(context.Background(), target.repo, target.workflowId, dispatchRequest)
+		return mutationDoneMsg{
+			message: fmt.Sprintf("Dispatched %s on %s", current.Name, dispatchRequest.Ref),
+			err:     err,
+			reload:  &target,
+		}
+	}
+}
+
+// Reports the outcome of a mutation and schedules reloading the runs it affected
+func (m *model) handleMutationDone(msg mutationDoneMsg) tea.Cmd {
+	if msg.err != nil {
+		m.setStatus(msg.err.Error(), true)
+		return nil
+	}
+
+	m.setStatus(msg.message, false)
+
+	if msg.reload == nil {
+		return nil
+	}
+	target := *msg.reload
+	return tea.Tick(dispatchRefreshDelay, func(time.Time) tea.Msg {
+		return reloadRunsMsg{target: target}
+	})
+}
+
+// The ref suggested when dispatching: the branch checked out in the git checkout of the repo,
+// the branch of the latest run, or main
+func (m *model) defaultRef() string {
+	if branch := checkedOutBranch(m.selected.repo); branch != "" {
+		return branch
+	}
+
+	runs := m.store.Runs(m.selected.repo, m.selected.workflowId).Runs
+	if len(runs) > 0 && runs[0].HeadBranch != "" {
+		return runs[0].HeadBranch
+	}
+	return "main"
+}
+
+// The branch checked out in the git checkout of the repo. It is read again every time, as another
+// branch may have been checked out since the config was read
+func checkedOutBranch(repo appconfig.Repo) string {
+	if repo.Checkout == "" {
+		return ""
+	}
+	found, err := checkout.Find(repo.Checkout)
+	if err != nil {
+		return repo.Branch
+	}
+	return found.Branch
+}
+
+// Rebuilds the rows of the runs table from the store
+func (m *model) refreshRuns() {
+	if m.selected == nil {
+		return
+	}
+
+	rows := []table.Row{}
+	for _, run := range m.store.Runs(m.selected.repo, m.selected.workflowId).Runs {
+		number := ""
+		if run.RunNumber > 0 {
+			number = fmt.Sprintf("%d", run.RunNumber)
+		}
+		title := run.DisplayTitle
+		if title == "" {
+			title = run.Name
+		}
+
+		rows = append(rows, table.Row{
+			statusIcon(run.Status, run.Conclusion),
+			number,
+			title,
+			run.HeadBranch,
+			run.Event,
+			runDuration(run),
+			formatTimestamp(run.RunStartedAt),
+		})
+	}
+
+	m.runsTable.SetRows(rows)
+}
+
+func renderWorkflow(builder *strings.Builder, m *model) {
+	current, ok := m.selectedWorkflow()
+	if !ok {
+		builder.WriteString("Select a workflow in the Overview tab and press enter to see it here\n")
+		return
+	}
+
+	builder.WriteString(fmt.Sprintf("%s/%s %s %s %s %s\n\n", m.selected.repo.Owner, m.selected.repo.Repo, divider, current.Name, divider, current.State))
+	builder.WriteString(fmt.Sprintf("Path     %s\n", current.Path))
+	builder.WriteString(fmt.Sprintf("Created  %s\n", formatTimestamp(current.CreatedAt)))
+	builder.WriteString(fmt.Sprintf("Updated  %s\n", formatTimestamp(current.UpdatedAt)))
+	builder.WriteString(fmt.Sprintf("URL      %s\n", url(current.HtmlUrl)))
+	builder.WriteString(fmt.Sprintf("Badge    %s\n", url(current.BadgeUrl)))
+	builder.WriteString("\n")
+
+	if m.dispatchForm.active {
+		builder.WriteString(listHeader("Dispatch " + current.Name))
+		builder.WriteString("\n")
+		builder.WriteString(m.dispatchForm.ref.View())
+		builder.WriteString("\n")
+		builder.WriteString(m.dispatchForm.inputs.View())
+		if m.dispatchForm.preparing {
+			builder.WriteString("\n\n" + m.spinner.View() + " Reading the inputs of the workflow...\n")
+			return
+		}
+		builder.WriteString("\n\nctrl+s: dispatch • enter: dispatch from the ref, new line in the inputs • tab: next field • esc: cancel\n")
+		return
+	}
+
+	builder.WriteString(listHeader("Actions"))
+	builder.WriteString("\n")
+	for i, action := range m.workflowActions() {
+		if i == m.cursorPos[workflow] && !m.runsFocused {
+			builder.WriteString(listSelected(m.actionLabel(action)))
+		} else {
+			builder.WriteString(listItem(m.actionLabel(action)))
+		}
+		builder.WriteString("\n")
+	}
+	builder.WriteString("\n")
+
+	builder.WriteString(listHeader("Recent runs"))
+	builder.WriteString("\n")
+	runs := m.store.Runs(m.selected.repo, m.selected.workflowId)
+	switch {
+	case runs.Status == orchestrator.Failed:
+		builder.WriteString("✗ " + runs.Err.Error() + "\n")
+	case len(runs.Runs) == 0 && runs.Status != orchestrator.Loaded:
+		builder.WriteString(m.spinner.View() + " Loading runs...\n")
+	case len(runs.Runs) == 0:
+		builder.WriteString("No runs yet\n")
+	default:
+		builder.WriteString(baseStyle.Render(m.runsTable.View()))
+		builder.WriteString("\n")
+	}
+
+	builder.WriteString("\nenter: select action or open run • tab: switch between actions and runs • esc: back\n")
+	if m.runsFocused {
+		builder.WriteString("c/C: cancel/force cancel run • R: re-run • F: re-run failed jobs\n")
+	}
+}
+
+// A single character summarizing the state of a run, job or step
+func statusIcon(status string, conclusion string) string {
+	switch status {
+	case "queued", "waiting", "pending", "requested":
+		return "○"
+	case "in_progress":
+		return "●"
+	}
+
+	switch conclusion {
+	case "success":
+		return "✓"
+	case "failure", "timed_out", "startup_failure":
+		return "✗"
+	case "cancelled":
+		return "⊘"
+	case "skipped", "neutral":
+		return "-"
+	case "action_required":
+		return "!"
+	}
+	return "?"
+}
+
+// How long a run took, or has been going for if it hasn't completed yet
+func runDuration(run response.Run) string {
+	started, err := time.Parse(time.RFC3339, run.RunStartedAt)
+	if err != nil {
+		return ""
+	}
+
+	ended := time.Now()
+	if run.Status == "completed" {
+		if updated, err := time.Parse(time.RFC3339, run.UpdatedAt); err == nil {
+			ended = updated
+		}
+	}
+
+	return formatDuration(ended.Sub(started))
+}
+
+func formatDuration(duration time.Duration) string {
+	if duration < 0 {
+		return ""
+	}
+	duration = duration.Round(time.Second)
+	if duration < time.Minute {
+		return fmt.Sprintf("%ds", int(duration.Seconds()))
+	}
+	if duration < time.Hour {
+		return fmt.Sprintf("%dm%02ds", int(duration.Minutes()), int(duration.Seconds())%60)
+	}
+	return fmt.Sprintf("%dh%02dm", int(duration.Hours()), int(duration.Minutes())%60)
+}
+
+// Shows API timestamps in local time, leaving unparseable ones as they are
+func formatTimestamp(timestamp string) string {
+	parsed, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return timestamp
+	}
+	return parsed.Local().Format("2006-01-02 15:04")
+}
@@ -0,0 +1,58 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/andreaswachs/lazyworkflows/appconfig"
+	"github.com/andreaswachs/lazyworkflows/orchestrator"
+)
+
+func TestCheckedOutBranchFollowsTheCheckout(t *testing.T) {
+	root := t.TempDir()
+	head := filepath.Join(root, ".git", "HEAD")
+	os.MkdirAll(filepath.Dir(head), 0700)
+	os.WriteFile(head, []byte("ref: refs/heads/main\n"), 0600)
+
+	// The branch checked out when the config was read
+	repo := appconfig.Repo{Owner: "octo", Repo: "repo", Checkout: root, Branch: "main"}
+
+	os.WriteFile(head, []byte("ref: refs/heads/feature/login\n"), 0600)
+	if branch := checkedOutBranch(repo); branch != "feature/login" {
+		t.Errorf("Expected the branch checked out since, but got %q", branch)
+	}
+
+	os.WriteFile(head, []byte("f83a356604ae3c5d03e1b46ef4d1ca77d64a90b0\n"), 0600)
+	if branch := checkedOutBranch(repo); branch != "" {
+		t.Errorf("Expected no branch for a detached HEAD, but got %q", branch)
+	}
+
+	if branch := checkedOutBranch(appconfig.Repo{Owner: "octo", Repo: "repo"}); branch != "" {
+		t.Errorf("Expected no branch outside of a checkout, but got %q", branch)
+	}
+}
+
+func TestDispatchFormTypesOneInputPerLine(t *testing.T) {
+	api := &fakeConsumer{content: "on:\n  workflow_dispatch:\n    inputs:\n      tags:\n        type: string\n      replicas:\n        type: number\n"}
+	repo := appconfig.Repo{Owner: "octo", Repo: "repo"}
+	m := model{
+		store:        orchestrator.New(api, appconfig.AppConfig{Repos: []appconfig.Repo{repo}}),
+		selected:     &workflowTarget{repo: repo, workflowId: "ci.yml"},
+		dispatchForm: newDispatchForm("main"),
+	}
+
+	m.dispatchForm.inputs.SetValue("tags=a,b\n\nreplicas=3")
+	msg := m.submitDispatch()().(dispatchPreparedMsg)
+	expected := map[string]interface{}{"tags": "a,b", "replicas": 3.0}
+	if msg.err != nil || !reflect.DeepEqual(msg.request.Inputs, expected) {
+		t.Errorf("Expected the inputs %v, but got %v %v", expected, msg.request.Inputs, msg.err)
+	}
+
+	m.dispatchForm.inputs.SetValue("replicas=many")
+	m.handleDispatchPrepared(m.submitDispatch()().(dispatchPreparedMsg))
+	if !m.dispatchForm.active || !m.statusIsError {
+		t.Errorf("Expected the form to stay open with the problem shown, but got %q", m.statusMessage)
+	}
+}
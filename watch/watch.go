@@ -0,0 +1,239 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andreaswachs/lazyworkflows/appconfig"
+	"github.com/andreaswachs/lazyworkflows/consumer"
+	"github.com/andreaswachs/lazyworkflows/model/request"
+	"github.com/andreaswachs/lazyworkflows/model/response"
+)
+
+// Exit codes for the conclusions of a run. Usage errors are left at 2
+const (
+	ExitSuccess        = 0
+	ExitFailure        = 1
+	ExitCancelled      = 3
+	ExitTimedOut       = 4
+	ExitActionRequired = 5
+	ExitStartupFailure = 6
+)
+
+const (
+	// How long to wait between polls when nothing else is given
+	DefaultInterval = 3 * time.Second
+	// How far the wait between polls backs off while a run doesn't change
+	DefaultMaxInterval = 30 * time.Second
+	// How long a dispatched run may take to show up in the API
+	DefaultResolveTimeout = 2 * time.Minute
+	// How much the clock of GitHub may be behind ours when matching the creation time of a run
+	clockSkew = time.Minute
+	// How many of the latest runs are looked at when resolving a dispatch
+	runsToInspect = 20
+)
+
+// ErrRunNotFound is returned when no run showed up for a dispatch in time
+var ErrRunNotFound = errors.New("no run showed up for the dispatch")
+
+// Options tune the polling. Zero values fall back to the defaults
+type Options struct {
+	Interval       time.Duration
+	MaxInterval    time.Duration
+	ResolveTimeout time.Duration
+}
+
+// Waits for the given duration or until the context is done.
+// It is a global variable and thus able to get mocked by tests
+var sleep = defaultSleep
+
+func defaultSleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// InjectSleep replaces the wait between polls. Nil restores the real one
+func InjectSleep(injectedSleep func(context.Context, time.Duration) error) {
+	if injectedSleep == nil {
+		injectedSleep = defaultSleep
+	}
+	sleep = injectedSleep
+}
+
+// Dispatch triggers the workflow and returns the run it created.
+// The API doesn't return the id of the run, so it is picked among the runs of the workflow by
+// ref, actor and creation time. Runs that existed before the dispatch are never picked
+func Dispatch(ctx context.Context, api consumer.Consumer, repo appconfig.Repo, workflowId string, dispatch request.Dispatch, options Options) (response.Run, error) {
+	options = options.withDefaults()
+
+	// Installation tokens, like the GITHUB_TOKEN of Actions, have no user to look up.
+	// Their dispatches are then matched without an actor
+	login := ""
+	if user, err := api.CurrentUser(ctx, repo); err == nil {
+		login = user.Login
+	}
+
+	filter := request.RunFilter{
+		Branch: branchOf(dispatch.Ref),
+		Event:  "workflow_dispatch",
+		Actor:  login,
+		Limit:  runsToInspect,
+	}
+
+	before, err := api.ListRuns(ctx, repo, workflowId, filter)
+	if err != nil {
+		return response.Run{}, err
+	}
+	existing := map[string]bool{}
+	for _, run := range before {
+		existing[run.Id.String()] = true
+	}
+
+	dispatchedAt := time.Now()
+	if _, err := api.Dispatch(ctx, repo, workflowId, dispatch); err != nil {
+		return response.Run{}, err
+	}
+
+	filter.CreatedAfter = dispatchedAt.Add(-clockSkew).UTC().Truncate(time.Second)
+	deadline := dispatchedAt.Add(options.ResolveTimeout)
+	interval := options.Interval
+	for {
+		if err := sleep(ctx, interval); err != nil {
+			return response.Run{}, err
+		}
+
+		runs, err := api.ListRuns(ctx, repo, workflowId, filter)
+		if err != nil {
+			return response.Run{}, err
+		}
+		if run, ok := pickDispatched(runs, existing, login); ok {
+			return run, nil
+		}
+
+		if time.Now().After(deadline) {
+			return response.Run{}, ErrRunNotFound
+		}
+		interval = backoff(interval, options.MaxInterval)
+	}
+}
+
+// Until polls the run until it completes and returns it in its final state.
+// onChange is called with the first state of the run and every time its status or conclusion changes.
+// The wait between polls grows while the run stays the same and is reset when it changes
+func Until(ctx context.Context, api consumer.Consumer, repo appconfig.Repo, runId string, options Options, onChange func(response.Run)) (response.Run, error) {
+	options = options.withDefaults()
+
+	previous := ""
+	interval := options.Interval
+	for {
+		run, err := api.GetRun(ctx, repo, runId)
+		if err != nil {
+			return response.Run{}, err
+		}
+
+		if state := run.Status + "/" + run.Conclusion; state != previous {
+			onChange(run)
+			previous = state
+			interval = options.Interval
+		} else {
+			interval = backoff(interval, options.MaxInterval)
+		}
+
+		if run.Status == "completed" {
+			return run, nil
+		}
+
+		if err := sleep(ctx, interval); err != nil {
+			return response.Run{}, err
+		}
+	}
+}
+
+// ExitCode maps the conclusion of a completed run to the exit code of the process
+func ExitCode(run response.Run) int {
+	switch run.Conclusion {
+	case "success", "neutral", "skipped":
+		return ExitSuccess
+	case "cancelled":
+		return ExitCancelled
+	case "timed_out":
+		return ExitTimedOut
+	case "action_required":
+		return ExitActionRequired
+	case "startup_failure":
+		return ExitStartupFailure
+	default:
+		return ExitFailure
+	}
+}
+
+func (o Options) withDefaults() Options {
+	if o.Interval <= 0 {
+		o.Interval = DefaultInterval
+	}
+	if o.MaxInterval <= 0 {
+		o.MaxInterval = DefaultMaxInterval
+	}
+	if o.MaxInterval < o.Interval {
+		o.MaxInterval = o.Interval
+	}
+	if o.ResolveTimeout <= 0 {
+		o.ResolveTimeout = DefaultResolveTimeout
+	}
+	return o
+}
+
+func backoff(interval time.Duration, max time.Duration) time.Duration {
+	interval += interval / 2
+	if interval > max {
+		return max
+	}
+	return interval
+}
+
+// Picks the oldest run that wasn't there before the dispatch, such that
+// concurrent dispatches of the same workflow each find their own run first
+func pickDispatched(runs []response.Run, existing map[string]bool, login string) (response.Run, bool) {
+	candidates := []response.Run{}
+	for _, run := range runs {
+		if existing[run.Id.String()] {
+			continue
+		}
+		if run.Event != "" && run.Event != "workflow_dispatch" {
+			continue
+		}
+		if login != "" && !strings.EqualFold(run.Actor.Login, login) && !strings.EqualFold(run.TriggeringActor.Login, login) {
+			continue
+		}
+		candidates = append(candidates, run)
+	}
+
+	if len(candidates) == 0 {
+		return response.Run{}, false
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].CreatedAt < candidates[j].CreatedAt
+	})
+	return candidates[0], true
+}
+
+// The runs API filters on the short name of a branch or tag
+func branchOf(ref string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/tags/"} {
+		if strings.HasPrefix(ref, prefix) {
+			return strings.TrimPrefix(ref, prefix)
+		}
+	}
+	return ref
+}
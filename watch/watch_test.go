@@ -0,0 +1,162 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andreaswachs/lazyworkflows/appconfig"
+	"github.com/andreaswachs/lazyworkflows/consumer"
+	"github.com/andreaswachs/lazyworkflows/model/request"
+	"github.com/andreaswachs/lazyworkflows/model/response"
+)
+
+// A consumer that serves canned runs. Calls that aren't overridden panic through the nil interface
+type fakeConsumer struct {
+	consumer.Consumer
+	// The runs returned by consecutive ListRuns calls. The last one is repeated
+	listed [][]response.Run
+	// The runs returned by consecutive GetRun calls. The last one is repeated
+	runs       []response.Run
+	filters    []request.RunFilter
+	dispatched int
+	// The error looking up the user of the token fails with
+	userErr error
+}
+
+func (f *fakeConsumer) CurrentUser(ctx context.Context, repo appconfig.Repo) (response.User, error) {
+	if f.userErr != nil {
+		return response.User{}, f.userErr
+	}
+	return response.User{Login: "octocat"}, nil
+}
+
+func (f *fakeConsumer) ListRuns(ctx context.Context, repo appconfig.Repo, id string, filter request.RunFilter) ([]response.Run, error) {
+	f.filters = append(f.filters, filter)
+	runs := f.listed[0]
+	if len(f.listed) > 1 {
+		f.listed = f.listed[1:]
+	}
+	return runs, nil
+}
+
+func (f *fakeConsumer) Dispatch(ctx context.Context, repo appconfig.Repo, id string, dispatchRequest request.Dispatch) (response.Dispatch, error) {
+	f.dispatched++
+	return response.Dispatch{Status: 204}, nil
+}
+
+func (f *fakeConsumer) GetRun(ctx context.Context, repo appconfig.Repo, runId string) (response.Run, error) {
+	run := f.runs[0]
+	if len(f.runs) > 1 {
+		f.runs = f.runs[1:]
+	}
+	return run, nil
+}
+
+// Records the waits between polls instead of sleeping
+func recordSleeps(t *testing.T) *[]time.Duration {
+	waits := []time.Duration{}
+	InjectSleep(func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return ctx.Err()
+	})
+	t.Cleanup(func() { InjectSleep(nil) })
+	return &waits
+}
+
+func TestDispatchPicksTheOldestNewRunOfTheUser(t *testing.T) {
+	recordSleeps(t)
+	old := response.Run{Id: "1", Event: "workflow_dispatch", Actor: response.Actor{Login: "octocat"}, CreatedAt: "2022-11-01T10:00:00Z"}
+	api := &fakeConsumer{listed: [][]response.Run{
+		{old},
+		{old},
+		{
+			{Id: "4", Event: "workflow_dispatch", Actor: response.Actor{Login: "octocat"}, CreatedAt: "2022-11-01T10:05:02Z"},
+			{Id: "3", Event: "workflow_dispatch", Actor: response.Actor{Login: "hubot"}, CreatedAt: "2022-11-01T10:05:00Z"},
+			{Id: "2", Event: "workflow_dispatch", Actor: response.Actor{Login: "octocat"}, CreatedAt: "2022-11-01T10:05:01Z"},
+			old,
+		},
+	}}
+
+	run, err := Dispatch(context.Background(), api, appconfig.Repo{}, "ci.yml", request.Dispatch{Ref: "refs/heads/main"}, Options{})
+	if err != nil {
+		t.Fatalf("Expected the run to be found, but got %v", err)
+	}
+
+	if run.Id != "2" {
+		t.Errorf("Expected run 2 to be picked, but got %v", run.Id)
+	}
+	if api.dispatched != 1 {
+		t.Errorf("Expected a single dispatch, but got %v", api.dispatched)
+	}
+	last := api.filters[len(api.filters)-1]
+	if last.Branch != "main" || last.Actor != "octocat" || last.Event != "workflow_dispatch" || last.CreatedAfter.IsZero() {
+		t.Errorf("Expected the runs to be filtered by branch, actor, event and time, but got %+v", last)
+	}
+}
+
+func TestDispatchMatchesAnyActorWhenTheUserIsUnknown(t *testing.T) {
+	recordSleeps(t)
+	api := &fakeConsumer{
+		userErr: errors.New("403 Resource not accessible by integration"),
+		listed: [][]response.Run{
+			{},
+			{{Id: "2", Event: "workflow_dispatch", Actor: response.Actor{Login: "github-actions[bot]"}, CreatedAt: "2022-11-01T10:05:01Z"}},
+		},
+	}
+
+	run, err := Dispatch(context.Background(), api, appconfig.Repo{}, "ci.yml", request.Dispatch{Ref: "main"}, Options{})
+	if err != nil {
+		t.Fatalf("Expected the run to be found, but got %v", err)
+	}
+
+	if run.Id != "2" {
+		t.Errorf("Expected run 2 to be picked, but got %v", run.Id)
+	}
+	if last := api.filters[len(api.filters)-1]; last.Actor != "" {
+		t.Errorf("Expected the runs not to be filtered by actor, but got %v", last.Actor)
+	}
+}
+
+func TestDispatchGivesUpAfterTheResolveTimeout(t *testing.T) {
+	recordSleeps(t)
+	api := &fakeConsumer{listed: [][]response.Run{{}}}
+
+	_, err := Dispatch(context.Background(), api, appconfig.Repo{}, "ci.yml", request.Dispatch{Ref: "main"}, Options{ResolveTimeout: time.Nanosecond})
+	if !errors.Is(err, ErrRunNotFound) {
+		t.Fatalf("Expected ErrRunNotFound, but got %v", err)
+	}
+}
+
+func TestUntilStreamsChangesAndBacksOff(t *testing.T) {
+	waits := recordSleeps(t)
+	api := &fakeConsumer{runs: []response.Run{
+		{Status: "queued"},
+		{Status: "in_progress"},
+		{Status: "in_progress"},
+		{Status: "in_progress"},
+		{Status: "completed", Conclusion: "cancelled"},
+	}}
+
+	changes := []string{}
+	run, err := Until(context.Background(), api, appconfig.Repo{}, "30433642", Options{Interval: 2 * time.Second, MaxInterval: 4 * time.Second}, func(run response.Run) {
+		changes = append(changes, run.Status+run.Conclusion)
+	})
+	if err != nil {
+		t.Fatalf("Expected the run to complete, but got %v", err)
+	}
+
+	if len(changes) != 3 || changes[2] != "completedcancelled" {
+		t.Errorf("Expected a callback per change, but got %v", changes)
+	}
+	expected := []time.Duration{2 * time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second}
+	for i, wait := range expected {
+		if i >= len(*waits) || (*waits)[i] != wait {
+			t.Fatalf("Expected the waits %v, but got %v", expected, *waits)
+		}
+	}
+	if code := ExitCode(run); code != ExitCancelled {
+		t.Errorf("Expected exit code %v, but got %v", ExitCancelled, code)
+	}
+}